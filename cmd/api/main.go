@@ -2,7 +2,8 @@ package main
 
 import (
 	"context"
-	"database/sql"
+	"crypto/rand"
+	"encoding/hex"
 	"log/slog"
 	"net/http"
 	"os"
@@ -11,12 +12,13 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 
 	handler "github.com/bimakw/url-shortener/internal/adapter/inbound/http"
-	"github.com/bimakw/url-shortener/internal/adapter/outbound/postgres"
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
 	redisRepo "github.com/bimakw/url-shortener/internal/adapter/outbound/redis"
 	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
 	"github.com/bimakw/url-shortener/internal/infrastructure"
 	"github.com/bimakw/url-shortener/pkg/geoip"
 )
@@ -31,35 +33,53 @@ func main() {
 
 	cfg := infrastructure.LoadConfig()
 
-	db, err := sql.Open("postgres", cfg.Database.DSN())
-	if err != nil {
-		logger.Error("failed to connect to database", slog.Any("error", err))
-		os.Exit(1)
-	}
-	defer db.Close()
-
-	// Ping database
+	// Connect to database
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	if err := db.PingContext(ctx); err != nil {
-		logger.Error("failed to ping database", slog.Any("error", err))
+	database, err := infrastructure.OpenDatabase(ctx, cfg.Database)
+	if err != nil {
+		logger.Error("failed to connect to database", slog.String("driver", cfg.Database.Driver), slog.Any("error", err))
 		os.Exit(1)
 	}
+	defer database.Close()
 
-	// Run migrations
-	if err := postgres.RunMigrations(ctx, db); err != nil {
-		logger.Error("failed to run migrations", slog.Any("error", err))
-		os.Exit(1)
+	// "migrate up|down|status" manages the schema and exits
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(context.Background(), database, os.Args[2:]); err != nil {
+			logger.Error("migration failed", slog.Any("error", err))
+			os.Exit(1)
+		}
+		return
 	}
 
-	logger.Info("database connected and migrations applied")
+	// Run migrations. They get no deadline, like "migrate up": a replica
+	// waits for another to finish migrating, and a migration that fills in
+	// data can take a while.
+	if cfg.Database.AutoMigrate {
+		migrator, err := database.Migrator()
+		if err == nil {
+			_, err = migrator.Up(context.Background())
+		}
+		if err != nil {
+			logger.Error("failed to run migrations", slog.Any("error", err))
+			os.Exit(1)
+		}
+		logger.Info("database connected and migrations applied", slog.String("driver", cfg.Database.Driver))
+	} else {
+		logger.Info("database connected", slog.String("driver", cfg.Database.Driver))
+	}
 
-	urlRepo := postgres.NewURLRepository(db)
-	clickRepo := postgres.NewClickRepository(db)
-	apiKeyRepo := postgres.NewAPIKeyRepository(db)
+	urlRepo := database.URLs
+	clickRepo := database.Clicks
+	apiKeyRepo := database.APIKeys
+	userRepo := database.Users
+	revisionRepo := database.Revisions
+	workspaceRepo := database.Workspaces
+	auditRepo := database.Audit
 
-	var urlCache *redisRepo.URLCacheRepository
+	var urlCache repository.URLCacheRepository
+	var passwordAttempts repository.PasswordAttemptRepository
 	redisClient, err := redisRepo.NewRedisClient(
 		cfg.Redis.Host,
 		cfg.Redis.Port,
@@ -68,35 +88,166 @@ func main() {
 	)
 	if err != nil {
 		logger.Warn("redis not available, running without cache", slog.Any("error", err))
+		// Wrong passwords are then only counted per replica
+		passwordAttempts = memory.NewPasswordAttemptRepository()
 	} else {
-		urlCache = redisRepo.NewURLCacheRepository(redisClient, cfg.App.CacheTTL)
+		redisCache := redisRepo.NewURLCacheRepository(redisClient, cfg.App.CacheTTL)
+		urlCache = redisCache
+		if cfg.App.LocalCacheSize > 0 {
+			localCache := redisRepo.NewTieredURLCache(redisCache, cfg.App.LocalCacheSize, cfg.App.LocalCacheTTL)
+			defer localCache.Close()
+			urlCache = localCache
+		}
+		passwordAttempts = redisRepo.NewPasswordAttemptRepository(redisClient)
 		logger.Info("redis connected")
 	}
 
 	geoipClient := geoip.NewClient()
 	logger.Info("geoip client initialized")
 
+	// Country targeting needs a lookup fast enough to run on every redirect
+	var countryDB *geoip.CountryDB
+	if cfg.App.GeoIPCountryDB != "" {
+		countryDB, err = geoip.OpenCountryDB(cfg.App.GeoIPCountryDB)
+		if err != nil {
+			logger.Error("failed to load country database", slog.String("path", cfg.App.GeoIPCountryDB), slog.Any("error", err))
+			os.Exit(1)
+		}
+		logger.Info("country database loaded", slog.Int("ranges", countryDB.Len()))
+	}
+
+	clickIngester := usecase.NewClickIngester(usecase.ClickIngesterConfig{
+		URLRepo:        urlRepo,
+		ClickRepo:      clickRepo,
+		GeoIPClient:    geoipClient,
+		URLCache:       urlCache,
+		Workers:        cfg.Clicks.Workers,
+		QueueSize:      cfg.Clicks.QueueSize,
+		BatchSize:      cfg.Clicks.BatchSize,
+		FlushInterval:  cfg.Clicks.FlushInterval,
+		EnqueueTimeout: cfg.Clicks.EnqueueTimeout,
+
+		GeoLookupTimeout: cfg.Clicks.GeoLookupTimeout,
+	})
+
+	// With Redis, click counts are collected there and written back in batches
+	var clickCountFlusher *usecase.ClickCountFlusher
+	if urlCache != nil {
+		clickCountFlusher = usecase.NewClickCountFlusher(usecase.ClickCountFlusherConfig{
+			URLRepo:  urlRepo,
+			URLCache: urlCache,
+			Interval: cfg.Clicks.CountFlushInterval,
+		})
+	}
+
+	authSecret := cfg.Auth.Secret
+	if authSecret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			logger.Error("failed to generate auth secret", slog.Any("error", err))
+			os.Exit(1)
+		}
+		authSecret = hex.EncodeToString(secret)
+		logger.Warn("AUTH_SECRET not set, using a random secret; sessions and link unlocks will not survive restarts")
+	}
+
 	urlUseCase := usecase.NewURLUseCase(usecase.URLUseCaseConfig{
-		URLRepo:     urlRepo,
-		URLCache:    urlCache,
-		ClickRepo:   clickRepo,
-		GeoIPClient: geoipClient,
-		BaseURL:     cfg.App.BaseURL,
-		CodeLength:  cfg.App.ShortCodeLength,
+		URLRepo:       urlRepo,
+		URLCache:      urlCache,
+		ClickRepo:     clickRepo,
+		RevisionRepo:  revisionRepo,
+		WorkspaceRepo: workspaceRepo,
+		AuditRepo:     auditRepo,
+		GeoIPClient:   geoipClient,
+		ClickIngester: clickIngester,
+		NotFoundTTL:   cfg.App.CacheNotFoundTTL,
+		UnlockSecret:  authSecret,
+		UnlockTTL:     cfg.Auth.UnlockTTL,
+		BaseURL:       cfg.App.BaseURL,
+		CodeLength:    cfg.App.ShortCodeLength,
+
+		DefaultRedirectType: cfg.App.DefaultRedirectType,
+		RedirectMaxAge:      cfg.App.RedirectMaxAge,
+		PasswordAttemptRepo: passwordAttempts,
+		PasswordLockout: usecase.PasswordLockoutConfig{
+			FreeAttemptsPerLink: cfg.Auth.PasswordFreeAttemptsPerLink,
+			FreeAttemptsPerIP:   cfg.Auth.PasswordFreeAttemptsPerIP,
+			BaseLockout:         cfg.Auth.PasswordLockoutBase,
+			MaxLockout:          cfg.Auth.PasswordLockoutMax,
+			Window:              cfg.Auth.PasswordAttemptWindow,
+		},
+		CountryDB: countryDB,
 	})
 
-	apiKeyUseCase := usecase.NewAPIKeyUseCase(apiKeyRepo)
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(usecase.APIKeyUseCaseConfig{
+		APIKeyRepo:    apiKeyRepo,
+		WorkspaceRepo: workspaceRepo,
+		AuditRepo:     auditRepo,
+		RotationGrace: cfg.App.KeyRotationGrace,
+		MaxRateLimit:  cfg.App.KeyMaxRateLimit,
+	})
 
 	urlHandler := handler.NewURLHandler(urlUseCase)
 	qrHandler := handler.NewQRHandler(urlUseCase, cfg.App.BaseURL)
+	authUseCase := usecase.NewAuthUseCase(usecase.AuthUseCaseConfig{
+		UserRepo:   userRepo,
+		AuditRepo:  auditRepo,
+		Secret:     authSecret,
+		SessionTTL: cfg.Auth.SessionTTL,
+	})
+
 	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyUseCase)
+	apiKeyMiddleware := middleware.NewAPIKeyMiddleware(apiKeyUseCase)
+	authHandler := handler.NewAuthHandler(authUseCase)
+	authMiddleware := middleware.NewAuthMiddleware(authUseCase)
+
+	workspaceUseCase := usecase.NewWorkspaceUseCase(usecase.WorkspaceUseCaseConfig{
+		WorkspaceRepo: workspaceRepo,
+		UserRepo:      userRepo,
+		AuditRepo:     auditRepo,
+	})
+	workspaceHandler := handler.NewWorkspaceHandler(workspaceUseCase)
+
+	auditUseCase := usecase.NewAuditUseCase(usecase.AuditUseCaseConfig{
+		AuditRepo:     auditRepo,
+		WorkspaceRepo: workspaceRepo,
+	})
+	auditHandler := handler.NewAuditHandler(auditUseCase)
+
+	var rateLimiter *middleware.RateLimiter
+	if cfg.App.RateLimit > 0 {
+		rateLimiter = middleware.NewRateLimiter(cfg.App.RateLimit, cfg.App.RateLimit*2)
+		if cfg.App.RateLimitStore == "redis" {
+			if redisClient != nil {
+				store := middleware.NewRedisRateLimitStore(redisClient)
+				rateLimiter = middleware.NewRateLimiterWithStore(store, cfg.App.RateLimit, cfg.App.RateLimit*2)
+				logger.Info("using redis rate limiter")
+			} else {
+				logger.Warn("redis not available, falling back to in-memory rate limiter")
+			}
+		}
+	}
+
+	trustedProxies, err := middleware.ParseTrustedProxies(cfg.App.TrustedProxies)
+	if err != nil {
+		logger.Error("invalid TRUSTED_PROXIES", slog.Any("error", err))
+		os.Exit(1)
+	}
 
 	router := handler.NewRouter(handler.RouterConfig{
-		URLHandler:    urlHandler,
-		QRHandler:     qrHandler,
-		APIKeyHandler: apiKeyHandler,
-		Logger:        logger,
-		RateLimit:     cfg.App.RateLimit,
+		URLHandler:       urlHandler,
+		QRHandler:        qrHandler,
+		APIKeyHandler:    apiKeyHandler,
+		AuthHandler:      authHandler,
+		WorkspaceHandler: workspaceHandler,
+		AuditHandler:     auditHandler,
+		ClickIngester:    clickIngester,
+		APIKeyMiddleware: apiKeyMiddleware,
+		AuthMiddleware:   authMiddleware,
+		RateLimiter:      rateLimiter,
+		Logger:           logger,
+		RateLimit:        cfg.App.RateLimit,
+		TrustedProxies:   trustedProxies,
 	})
 
 	server := &http.Server{
@@ -129,5 +280,24 @@ func main() {
 		logger.Error("server forced to shutdown", slog.Any("error", err))
 	}
 
+	// Write the clicks still queued, now that no new ones arrive
+	if err := clickIngester.Shutdown(ctx); err != nil {
+		logger.Error("click queue not drained", slog.Any("error", err))
+	}
+	stats := clickIngester.Stats()
+	logger.Info("click ingestion stopped",
+		slog.Int64("written", stats.Written),
+		slog.Int64("failed", stats.Failed),
+		slog.Int64("dropped", stats.Dropped),
+		slog.Int("unwritten", stats.Queued),
+	)
+
+	// The ingester has counted its last clicks, so this flush catches them all
+	if clickCountFlusher != nil {
+		if err := clickCountFlusher.Shutdown(ctx); err != nil {
+			logger.Error("click counts not flushed", slog.Any("error", err))
+		}
+	}
+
 	logger.Info("server exited")
 }
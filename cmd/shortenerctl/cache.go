@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+)
+
+var errNoCache = errors.New("redis is not available")
+
+func (a *app) flushCache(ctx context.Context, args []string) error {
+	if a.urlCache == nil {
+		return errNoCache
+	}
+
+	// Without codes, drop every cached link
+	if len(args) == 0 {
+		removed, err := a.urlCache.Flush(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(a.out, "flushed %d cached links\n", removed)
+		return nil
+	}
+
+	for _, code := range args {
+		if err := a.urlCache.Delete(ctx, code); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(a.out, "flushed %d links\n", len(args))
+	return nil
+}
+
+func (a *app) warmCache(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("cache warm", flag.ContinueOnError)
+	limit := flags.Int("limit", 1000, "number of most clicked links to cache")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	if a.urlCache == nil {
+		return errNoCache
+	}
+
+	urls, err := a.urlRepo.GetMostClicked(ctx, *limit)
+	if err != nil {
+		return err
+	}
+
+	for _, url := range urls {
+		if err := a.urlCache.Set(ctx, url); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(a.out, "cached %d links\n", len(urls))
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func (a *app) createKey(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("keys create", flag.ContinueOnError)
+	userID := flags.String("user", "", "user the key belongs to")
+	name := flags.String("name", "", "key name")
+	scopes := flags.String("scopes", "", "comma separated scopes (default urls:read,urls:write,stats:read)")
+	rateLimit := flags.Int("rate-limit", 0, "requests per second")
+	expiresIn := flags.Int("expires-in", 0, "expiry in days")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *userID == "" || *name == "" {
+		return errUsage
+	}
+
+	req := entity.CreateAPIKeyRequest{
+		Name:      *name,
+		RateLimit: *rateLimit,
+	}
+	if *scopes != "" {
+		req.Scopes = strings.Split(*scopes, ",")
+	}
+	if *expiresIn > 0 {
+		req.ExpiresIn = expiresIn
+	}
+
+	if err := a.validate.Struct(req); err != nil {
+		return err
+	}
+
+	response, err := a.apiKeyUseCase.CreateAPIKey(ctx, *userID, req)
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(response)
+	}
+	fmt.Fprintf(a.out, "created key %s (id %s)\n%s\nStore it safely, it won't be shown again.\n", response.Name, response.ID, response.Key)
+	return nil
+}
+
+func (a *app) listKeys(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("keys list", flag.ContinueOnError)
+	userID := flags.String("user", "", "user whose keys to list")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+	if *userID == "" {
+		return errUsage
+	}
+
+	keys, err := a.apiKeyUseCase.GetUserAPIKeys(ctx, *userID)
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(keys)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tPREFIX\tNAME\tACTIVE\tSCOPES\tLAST USED")
+	for _, key := range keys {
+		lastUsed := "never"
+		if key.LastUsed != nil {
+			lastUsed = key.LastUsed.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%t\t%s\t%s\n",
+			key.ID, key.KeyPrefix, key.Name, key.IsActive, strings.Join(key.Scopes, ","), lastUsed)
+	}
+	return w.Flush()
+}
+
+func (a *app) revokeKey(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	apiKey, err := a.apiKeyRepo.GetByID(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if apiKey == nil {
+		return fmt.Errorf("api key %q not found", args[0])
+	}
+
+	if err := a.apiKeyRepo.Deactivate(ctx, apiKey.ID); err != nil {
+		return err
+	}
+
+	before := *apiKey
+	apiKey.IsActive = false
+	if err := a.recordAudit(ctx, entity.AuditActionAPIKeyRevoke, entity.AuditTargetAPIKey, apiKey.ID, apiKey.WorkspaceID, &before, apiKey); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "revoked %s (%s)\n", apiKey.ID, apiKey.KeyPrefix)
+	return nil
+}
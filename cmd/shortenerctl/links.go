@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/pkg/jsondiff"
+	"github.com/google/uuid"
+)
+
+func (a *app) createLink(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("links create", flag.ContinueOnError)
+	alias := flags.String("alias", "", "custom alias")
+	userID := flags.String("user", "", "owner user ID")
+	expiresIn := flags.Int("expires-in", 0, "expiry in hours")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	req := entity.CreateURLRequest{
+		OriginalURL: rest[0],
+		CustomAlias: *alias,
+		UserID:      *userID,
+	}
+	if *expiresIn > 0 {
+		req.ExpiresIn = expiresIn
+	}
+
+	if err := a.validate.Struct(req); err != nil {
+		return err
+	}
+
+	response, err := a.urlUseCase.CreateShortURL(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(response)
+	}
+	fmt.Fprintf(a.out, "created %s -> %s (id %s)\n", response.ShortURL, response.OriginalURL, response.ID)
+	return nil
+}
+
+func (a *app) listLinks(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("links list", flag.ContinueOnError)
+	userID := flags.String("user", "", "only links of this user")
+	limit := flags.Int("limit", 50, "number of links")
+	offset := flags.Int("offset", 0, "number of links to skip")
+	if _, err := parseFlags(flags, args, 0); err != nil {
+		return err
+	}
+
+	var urls []*entity.URL
+	var err error
+	if *userID != "" {
+		urls, err = a.urlRepo.GetByUserID(ctx, *userID, *limit, *offset)
+	} else {
+		urls, err = a.urlRepo.List(ctx, *limit, *offset)
+	}
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		if urls == nil {
+			urls = []*entity.URL{}
+		}
+		return a.printJSON(urls)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tCODE\tACTIVE\tCLICKS\tCREATED\tURL")
+	for _, url := range urls {
+		fmt.Fprintf(w, "%s\t%s\t%t\t%d\t%s\t%s\n",
+			url.ID, url.ShortCode, url.CanRedirect(), url.ClickCount,
+			url.CreatedAt.Format("2006-01-02 15:04"), url.OriginalURL)
+	}
+	return w.Flush()
+}
+
+func (a *app) setLinkActive(ctx context.Context, args []string, active bool) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	url, err := a.resolveLink(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	var before entity.URL
+	url, err = a.revisionRepo.Edit(ctx, url.ID, entity.RevisionActionUpdate, "", func(url *entity.URL) error {
+		before = *url
+		url.IsActive = active
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if url == nil {
+		return fmt.Errorf("link %q not found", args[0])
+	}
+	if a.urlCache != nil {
+		_ = a.urlCache.Delete(ctx, url.ShortCode)
+	}
+
+	if err := a.recordAudit(ctx, entity.AuditActionURLUpdate, entity.AuditTargetURL, url.ID, url.WorkspaceID, &before, url); err != nil {
+		return err
+	}
+
+	state := "disabled"
+	if active {
+		state = "enabled"
+	}
+	fmt.Fprintf(a.out, "%s %s\n", state, url.ShortCode)
+	return nil
+}
+
+func (a *app) deleteLink(ctx context.Context, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	url, err := a.resolveLink(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	// Only once the row is gone, or a redirect meanwhile caches it again
+	if err := a.urlRepo.Delete(ctx, url.ID); err != nil {
+		return err
+	}
+	if a.urlCache != nil {
+		_ = a.urlCache.Delete(ctx, url.ShortCode)
+	}
+
+	if err := a.recordAudit(ctx, entity.AuditActionURLDelete, entity.AuditTargetURL, url.ID, url.WorkspaceID, url, nil); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "deleted %s\n", url.ShortCode)
+	return nil
+}
+
+// resolveLink looks a link up by ID or, failing that, by short code.
+func (a *app) resolveLink(ctx context.Context, idOrCode string) (*entity.URL, error) {
+	var url *entity.URL
+	var err error
+	if _, parseErr := uuid.Parse(idOrCode); parseErr == nil {
+		url, err = a.urlRepo.GetByID(ctx, idOrCode)
+	} else {
+		url, err = a.urlRepo.GetByShortCode(ctx, idOrCode)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if url == nil {
+		return nil, fmt.Errorf("link %q not found", idOrCode)
+	}
+	return url, nil
+}
+
+// recordAudit logs a change made from the command line. Such events have no
+// actor, which sets them apart from changes made through the API.
+func (a *app) recordAudit(ctx context.Context, action, targetType, targetID, workspaceID string, before, after any) error {
+	event := &entity.AuditEvent{
+		Action:      action,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		WorkspaceID: workspaceID,
+	}
+
+	diff, err := jsondiff.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(diff) > 0 {
+		if event.Changes, err = json.Marshal(diff); err != nil {
+			return err
+		}
+	}
+
+	return a.auditRepo.Create(ctx, event)
+}
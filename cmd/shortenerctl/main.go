@@ -0,0 +1,195 @@
+// Command shortenerctl operates the URL shortener directly through its
+// database and cache, for when the API is not an option.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/joho/godotenv"
+
+	redisRepo "github.com/bimakw/url-shortener/internal/adapter/outbound/redis"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/internal/infrastructure"
+)
+
+const usage = `shortenerctl operates the URL shortener through its database and cache.
+
+Usage:
+  shortenerctl [-json] <command> [flags] [args]
+
+Commands:
+  links create [-alias code] [-user id] [-expires-in hours] <url>
+  links list [-user id] [-limit n] [-offset n]
+  links disable <id|code>
+  links enable <id|code>
+  links delete <id|code>
+  keys create -user id -name name [-scopes a,b] [-rate-limit n] [-expires-in days]
+  keys list -user id
+  keys revoke <id>
+  cache flush [code...]
+  cache warm [-limit n]
+  stats [-from YYYY-MM-DD] [-to YYYY-MM-DD] <id|code>
+
+Configuration is read from the environment (and .env) like the API server.
+`
+
+var errUsage = errors.New("invalid usage")
+
+type app struct {
+	urlRepo       repository.URLRepository
+	clickRepo     repository.ClickRepository
+	apiKeyRepo    repository.APIKeyRepository
+	revisionRepo  repository.URLRevisionRepository
+	auditRepo     repository.AuditRepository
+	urlCache      *redisRepo.URLCacheRepository // nil without Redis
+	urlUseCase    *usecase.URLUseCase
+	apiKeyUseCase *usecase.APIKeyUseCase
+
+	validate *validator.Validate
+	out      io.Writer
+	json     bool
+}
+
+func main() {
+	_ = godotenv.Load()
+
+	flags := flag.NewFlagSet("shortenerctl", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	jsonOutput := flags.Bool("json", false, "print JSON instead of tables")
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		os.Exit(2)
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	cfg := infrastructure.LoadConfig()
+
+	database, err := infrastructure.OpenDatabase(ctx, cfg.Database)
+	if err != nil {
+		fatal(fmt.Errorf("connect to database: %w", err))
+	}
+	defer database.Close()
+
+	a := &app{
+		urlRepo:      database.URLs,
+		clickRepo:    database.Clicks,
+		apiKeyRepo:   database.APIKeys,
+		revisionRepo: database.Revisions,
+		auditRepo:    database.Audit,
+		validate:     validator.New(),
+		out:          os.Stdout,
+		json:         *jsonOutput,
+	}
+
+	urlConfig := usecase.URLUseCaseConfig{
+		URLRepo:       a.urlRepo,
+		ClickRepo:     a.clickRepo,
+		RevisionRepo:  a.revisionRepo,
+		WorkspaceRepo: database.Workspaces,
+		AuditRepo:     a.auditRepo,
+		BaseURL:       cfg.App.BaseURL,
+		CodeLength:    cfg.App.ShortCodeLength,
+	}
+
+	redisClient, err := redisRepo.NewRedisClient(cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.DB)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: redis not available, cache will not be updated: %v\n", err)
+	} else {
+		defer redisClient.Close()
+		a.urlCache = redisRepo.NewURLCacheRepository(redisClient, cfg.App.CacheTTL)
+		urlConfig.URLCache = a.urlCache
+	}
+
+	a.urlUseCase = usecase.NewURLUseCase(urlConfig)
+	a.apiKeyUseCase = usecase.NewAPIKeyUseCase(usecase.APIKeyUseCaseConfig{
+		APIKeyRepo:    a.apiKeyRepo,
+		WorkspaceRepo: urlConfig.WorkspaceRepo,
+		AuditRepo:     a.auditRepo,
+		RotationGrace: cfg.App.KeyRotationGrace,
+		MaxRateLimit:  cfg.App.KeyMaxRateLimit,
+	})
+
+	if err := a.run(ctx, flags.Args()); err != nil {
+		if errors.Is(err, errUsage) {
+			flags.Usage()
+			os.Exit(2)
+		}
+		fatal(err)
+	}
+}
+
+func (a *app) run(ctx context.Context, args []string) error {
+	command, args := args[0], args[1:]
+	if command == "stats" {
+		return a.stats(ctx, args)
+	}
+
+	if len(args) == 0 {
+		return errUsage
+	}
+	sub, args := args[0], args[1:]
+
+	switch command + " " + sub {
+	case "links create":
+		return a.createLink(ctx, args)
+	case "links list":
+		return a.listLinks(ctx, args)
+	case "links disable":
+		return a.setLinkActive(ctx, args, false)
+	case "links enable":
+		return a.setLinkActive(ctx, args, true)
+	case "links delete":
+		return a.deleteLink(ctx, args)
+	case "keys create":
+		return a.createKey(ctx, args)
+	case "keys list":
+		return a.listKeys(ctx, args)
+	case "keys revoke":
+		return a.revokeKey(ctx, args)
+	case "cache flush":
+		return a.flushCache(ctx, args)
+	case "cache warm":
+		return a.warmCache(ctx, args)
+	default:
+		return errUsage
+	}
+}
+
+// parseFlags parses a subcommand's flags and returns its positional
+// arguments, of which there must be exactly want (or any number if negative).
+func parseFlags(flags *flag.FlagSet, args []string, want int) ([]string, error) {
+	flags.SetOutput(io.Discard)
+	if err := flags.Parse(args); err != nil {
+		return nil, errUsage
+	}
+	if want >= 0 && flags.NArg() != want {
+		return nil, errUsage
+	}
+	return flags.Args(), nil
+}
+
+func (a *app) printJSON(v any) error {
+	enc := json.NewEncoder(a.out)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
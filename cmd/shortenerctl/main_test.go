@@ -0,0 +1,234 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	redisRepo "github.com/bimakw/url-shortener/internal/adapter/outbound/redis"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// newTestApp wires the commands like main does, on in-memory repositories
+// and an in-process Redis.
+func newTestApp(t *testing.T) (*app, *bytes.Buffer) {
+	t.Helper()
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	out := &bytes.Buffer{}
+	workspaces := memory.NewWorkspaceRepository(nil)
+	urls := memory.NewURLRepository()
+	a := &app{
+		urlRepo:      urls,
+		clickRepo:    memory.NewClickRepository(),
+		apiKeyRepo:   memory.NewAPIKeyRepository(),
+		revisionRepo: memory.NewURLRevisionRepository(urls),
+		auditRepo:    memory.NewAuditRepository(),
+		urlCache:     redisRepo.NewURLCacheRepository(client, time.Hour),
+		validate:     validator.New(),
+		out:          out,
+	}
+	a.urlUseCase = usecase.NewURLUseCase(usecase.URLUseCaseConfig{
+		URLRepo:       a.urlRepo,
+		URLCache:      a.urlCache,
+		ClickRepo:     a.clickRepo,
+		RevisionRepo:  a.revisionRepo,
+		WorkspaceRepo: workspaces,
+		AuditRepo:     a.auditRepo,
+		BaseURL:       "https://sho.rt/",
+		CodeLength:    6,
+	})
+	a.apiKeyUseCase = usecase.NewAPIKeyUseCase(usecase.APIKeyUseCaseConfig{
+		APIKeyRepo:    a.apiKeyRepo,
+		WorkspaceRepo: workspaces,
+		AuditRepo:     a.auditRepo,
+	})
+	return a, out
+}
+
+func TestParseFlags(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		want    int
+		rest    []string
+		limit   int
+		wantErr bool
+	}{
+		{"no args", nil, 0, []string{}, 10, false},
+		{"flag", []string{"-limit", "5"}, 0, []string{}, 5, false},
+		{"flag and argument", []string{"-limit", "5", "abc123"}, 1, []string{"abc123"}, 5, false},
+		{"any number", []string{"a", "b"}, -1, []string{"a", "b"}, 10, false},
+		{"missing argument", nil, 1, nil, 10, true},
+		{"extra argument", []string{"a", "b"}, 1, nil, 10, true},
+		{"unknown flag", []string{"-nope"}, 0, nil, 10, true},
+		{"bad value", []string{"-limit", "many"}, 0, nil, 10, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			limit := flags.Int("limit", 10, "")
+
+			rest, err := parseFlags(flags, tt.args, tt.want)
+			if tt.wantErr {
+				if !errors.Is(err, errUsage) {
+					t.Errorf("parseFlags() error = %v, want errUsage", err)
+				}
+				return
+			}
+			if err != nil || !slices.Equal(rest, tt.rest) || *limit != tt.limit {
+				t.Errorf("parseFlags() = %q, %v with limit %d, want %q with limit %d", rest, err, *limit, tt.rest, tt.limit)
+			}
+		})
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	ctx := context.Background()
+	a, _ := newTestApp(t)
+
+	for _, args := range [][]string{
+		{"links"},
+		{"links", "rename"},
+		{"users", "list"},
+		{"links", "create"},
+		{"links", "disable"},
+		{"links", "delete", "a", "b"},
+		{"keys", "create", "-user", "user-1"},
+		{"keys", "list"},
+		{"keys", "revoke"},
+		{"cache", "warm", "extra"},
+		{"stats"},
+	} {
+		if err := a.run(ctx, args); !errors.Is(err, errUsage) {
+			t.Errorf("run(%q) error = %v, want errUsage", args, err)
+		}
+	}
+}
+
+func TestRunLinks(t *testing.T) {
+	ctx := context.Background()
+	a, out := newTestApp(t)
+
+	tests := []struct {
+		args []string
+		want string // in the output
+	}{
+		{[]string{"links", "create", "-alias", "docs", "-user", "user-1", "https://example.com/docs"}, "created https://sho.rt/docs -> https://example.com/docs"},
+		{[]string{"links", "list"}, "docs"},
+		{[]string{"links", "list", "-user", "user-2"}, "ID"},
+		{[]string{"links", "disable", "docs"}, "disabled docs"},
+		{[]string{"links", "enable", "docs"}, "enabled docs"},
+		{[]string{"stats", "docs"}, "Total clicks"},
+		{[]string{"links", "delete", "docs"}, "deleted docs"},
+	}
+	for _, tt := range tests {
+		out.Reset()
+		if err := a.run(ctx, tt.args); err != nil {
+			t.Fatalf("run(%q) error = %v", tt.args, err)
+		}
+		if !strings.Contains(out.String(), tt.want) {
+			t.Errorf("run(%q) printed %q, want %q in it", tt.args, out.String(), tt.want)
+		}
+	}
+
+	if err := a.run(ctx, []string{"links", "delete", "docs"}); err == nil || errors.Is(err, errUsage) {
+		t.Errorf("run(delete missing) error = %v, want not found", err)
+	}
+
+	// Every change from the command line is audited without an actor
+	events, _ := a.auditRepo.List(ctx, entity.AuditFilter{Limit: 10})
+	if len(events) != 4 {
+		t.Errorf("%d audit events, want create, disable, enable and delete", len(events))
+	}
+}
+
+func TestRunKeys(t *testing.T) {
+	ctx := context.Background()
+	a, out := newTestApp(t)
+	a.json = true
+
+	if err := a.run(ctx, []string{"keys", "create", "-user", "user-1", "-name", "ci", "-scopes", "urls:read"}); err != nil {
+		t.Fatalf("keys create error = %v", err)
+	}
+	keys, _ := a.apiKeyRepo.GetByUserID(ctx, "user-1")
+	if len(keys) != 1 || !slices.Equal(keys[0].Scopes, []string{entity.ScopeURLsRead}) || !strings.Contains(out.String(), `"key": "sk_`) {
+		t.Fatalf("keys create made %v and printed %q", keys, out.String())
+	}
+
+	if err := a.run(ctx, []string{"keys", "create", "-user", "user-1", "-name", "bad", "-scopes", "everything"}); err == nil {
+		t.Error("keys create with an unknown scope succeeded")
+	}
+
+	out.Reset()
+	if err := a.run(ctx, []string{"keys", "revoke", keys[0].ID}); err != nil {
+		t.Fatalf("keys revoke error = %v", err)
+	}
+	if got, _ := a.apiKeyRepo.GetByID(ctx, keys[0].ID); got.IsActive {
+		t.Error("key still active after keys revoke")
+	}
+	if err := a.run(ctx, []string{"keys", "revoke", "missing"}); err == nil {
+		t.Error("keys revoke of a missing key succeeded")
+	}
+}
+
+func TestRunCache(t *testing.T) {
+	ctx := context.Background()
+	a, out := newTestApp(t)
+
+	for _, req := range []entity.CreateURLRequest{
+		{OriginalURL: "https://example.com/open", CustomAlias: "open"},
+		{OriginalURL: "https://example.com/secret", CustomAlias: "secret", Password: "correct horse"},
+	} {
+		if _, err := a.urlUseCase.CreateShortURL(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.run(ctx, []string{"cache", "flush"}); err != nil {
+		t.Fatalf("cache flush error = %v", err)
+	}
+	if got, _ := a.urlCache.Get(ctx, "open"); got != nil {
+		t.Error("link still cached after cache flush")
+	}
+
+	out.Reset()
+	if err := a.run(ctx, []string{"cache", "warm"}); err != nil {
+		t.Fatalf("cache warm error = %v", err)
+	}
+	if got := out.String(); got != "cached 2 links\n" {
+		t.Errorf("cache warm printed %q, want 2 links cached", got)
+	}
+	if got, _ := a.urlCache.Get(ctx, "open"); got == nil {
+		t.Error("open link not cached by cache warm")
+	}
+	// Protected links keep their password when warmed
+	if got, _ := a.urlCache.Get(ctx, "secret"); got == nil || got.PasswordHash == "" {
+		t.Errorf("protected link cached by cache warm = %+v, want it with its password hash", got)
+	}
+
+	if err := a.run(ctx, []string{"cache", "flush", "open"}); err != nil {
+		t.Fatalf("cache flush open error = %v", err)
+	}
+	if got, _ := a.urlCache.Get(ctx, "open"); got != nil {
+		t.Error("link still cached after cache flush open")
+	}
+
+	a.urlCache = nil
+	if err := a.run(ctx, []string{"cache", "warm"}); !errors.Is(err, errNoCache) {
+		t.Errorf("cache warm without Redis error = %v, want errNoCache", err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+func (a *app) stats(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	fromStr := flags.String("from", "", "first day, YYYY-MM-DD (default 30 days ago)")
+	toStr := flags.String("to", "", "last day, YYYY-MM-DD (default today)")
+	rest, err := parseFlags(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	from := time.Now().AddDate(0, 0, -30)
+	to := time.Now()
+	if *fromStr != "" {
+		if from, err = time.Parse("2006-01-02", *fromStr); err != nil {
+			return fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if *toStr != "" {
+		if to, err = time.Parse("2006-01-02", *toStr); err != nil {
+			return fmt.Errorf("invalid -to: %w", err)
+		}
+		to = to.Add(24*time.Hour - time.Second) // End of day
+	}
+
+	url, err := a.resolveLink(ctx, rest[0])
+	if err != nil {
+		return err
+	}
+
+	stats, err := a.clickRepo.GetStatsByURLID(ctx, url.ID, from, to)
+	if err != nil {
+		return err
+	}
+
+	if a.json {
+		return a.printJSON(stats)
+	}
+
+	w := tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Link\t%s -> %s\n", url.ShortCode, url.OriginalURL)
+	fmt.Fprintf(w, "Period\t%s to %s\n", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	fmt.Fprintf(w, "Total clicks\t%d\n", stats.TotalClicks)
+	fmt.Fprintf(w, "Unique clicks\t%d\n", stats.UniqueClicks)
+
+	if len(stats.ClicksByDate) > 0 {
+		fmt.Fprintln(w, "\nDATE\tCLICKS")
+		dates := make([]string, 0, len(stats.ClicksByDate))
+		for date := range stats.ClicksByDate {
+			dates = append(dates, date)
+		}
+		sort.Strings(dates)
+		for _, date := range dates {
+			fmt.Fprintf(w, "%s\t%d\n", date, stats.ClicksByDate[date])
+		}
+	}
+
+	if len(stats.TopReferrers) > 0 {
+		fmt.Fprintln(w, "\nREFERRER\tCLICKS")
+		for _, s := range stats.TopReferrers {
+			fmt.Fprintf(w, "%s\t%d\n", s.Referrer, s.Count)
+		}
+	}
+	if len(stats.TopCountries) > 0 {
+		fmt.Fprintln(w, "\nCOUNTRY\tCLICKS")
+		for _, s := range stats.TopCountries {
+			fmt.Fprintf(w, "%s\t%d\n", s.Country, s.Count)
+		}
+	}
+	if len(stats.TopBrowsers) > 0 {
+		fmt.Fprintln(w, "\nBROWSER\tCLICKS")
+		for _, s := range stats.TopBrowsers {
+			fmt.Fprintf(w, "%s\t%d\n", s.Browser, s.Count)
+		}
+	}
+	if len(stats.TopDevices) > 0 {
+		fmt.Fprintln(w, "\nDEVICE\tCLICKS")
+		for _, s := range stats.TopDevices {
+			fmt.Fprintf(w, "%s\t%d\n", s.Device, s.Count)
+		}
+	}
+
+	return w.Flush()
+}
@@ -2,8 +2,11 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
 	"github.com/bimakw/url-shortener/internal/application/usecase"
 	"github.com/bimakw/url-shortener/internal/domain/entity"
 	"github.com/go-playground/validator/v10"
@@ -22,8 +25,8 @@ func NewAPIKeyHandler(uc *usecase.APIKeyUseCase) *APIKeyHandler {
 }
 
 func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok || userID == "" {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
 		Error(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
@@ -39,9 +42,22 @@ func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.WorkspaceID == "" {
+		req.WorkspaceID = middleware.WorkspaceIDFromContext(r.Context())
+	}
+
 	response, err := h.apiKeyUseCase.CreateAPIKey(r.Context(), userID, req)
 	if err != nil {
-		Error(w, http.StatusInternalServerError, "Failed to create API key")
+		switch err {
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "Only workspace admins can create workspace API keys")
+		case usecase.ErrScopeNotHeld:
+			Error(w, http.StatusForbidden, "API key cannot grant scopes it does not have")
+		case usecase.ErrRateLimitHigh:
+			Error(w, http.StatusBadRequest, "API key rate limit is above the maximum")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to create API key")
+		}
 		return
 	}
 
@@ -49,14 +65,30 @@ func (h *APIKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok || userID == "" {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
 		Error(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
 
-	keys, err := h.apiKeyUseCase.GetUserAPIKeys(r.Context(), userID)
+	// Workspace API keys list their own workspace's keys by default
+	workspaceID := r.URL.Query().Get("workspace_id")
+	if workspaceID == "" {
+		workspaceID = middleware.WorkspaceIDFromContext(r.Context())
+	}
+
+	var keys []*entity.APIKeyResponse
+	var err error
+	if workspaceID != "" {
+		keys, err = h.apiKeyUseCase.GetWorkspaceAPIKeys(r.Context(), workspaceID, userID)
+	} else {
+		keys, err = h.apiKeyUseCase.GetUserAPIKeys(r.Context(), userID)
+	}
 	if err != nil {
+		if err == usecase.ErrUnauthorized {
+			Error(w, http.StatusForbidden, "Only workspace admins can list workspace API keys")
+			return
+		}
 		Error(w, http.StatusInternalServerError, "Failed to get API keys")
 		return
 	}
@@ -65,8 +97,8 @@ func (h *APIKeyHandler) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok || userID == "" {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
 		Error(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
@@ -79,20 +111,70 @@ func (h *APIKeyHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	err := h.apiKeyUseCase.RevokeAPIKey(r.Context(), id, userID)
 	if err != nil {
-		if err == usecase.ErrAPIKeyNotFound {
+		switch err {
+		case usecase.ErrAPIKeyNotFound:
 			Error(w, http.StatusNotFound, "API key not found")
-			return
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this API key")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to revoke API key")
 		}
-		Error(w, http.StatusInternalServerError, "Failed to revoke API key")
 		return
 	}
 
 	Success(w, http.StatusOK, "API key revoked", nil)
 }
 
+func (h *APIKeyHandler) RotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	id := r.PathValue("id")
+	if id == "" {
+		Error(w, http.StatusBadRequest, "API key ID is required")
+		return
+	}
+
+	// The body is optional, an empty one uses the default grace period
+	var req entity.RotateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.apiKeyUseCase.RotateAPIKey(r.Context(), id, userID, req)
+	if err != nil {
+		switch err {
+		case usecase.ErrAPIKeyNotFound:
+			Error(w, http.StatusNotFound, "API key not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this API key")
+		case usecase.ErrAPIKeyRotated:
+			Error(w, http.StatusConflict, "API key has already been rotated")
+		case usecase.ErrAPIKeyInactive, usecase.ErrAPIKeyExpired:
+			Error(w, http.StatusConflict, "Only active API keys can be rotated")
+		case usecase.ErrScopeNotHeld:
+			Error(w, http.StatusForbidden, "API key cannot grant scopes it does not have")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to rotate API key")
+		}
+		return
+	}
+
+	Success(w, http.StatusCreated, "API key rotated. Store the new key safely, it won't be shown again.", response)
+}
+
 func (h *APIKeyHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok || userID == "" {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
 		Error(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
@@ -105,11 +187,14 @@ func (h *APIKeyHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
 
 	err := h.apiKeyUseCase.DeleteAPIKey(r.Context(), id, userID)
 	if err != nil {
-		if err == usecase.ErrAPIKeyNotFound {
+		switch err {
+		case usecase.ErrAPIKeyNotFound:
 			Error(w, http.StatusNotFound, "API key not found")
-			return
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this API key")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to delete API key")
 		}
-		Error(w, http.StatusInternalServerError, "Failed to delete API key")
 		return
 	}
 
@@ -0,0 +1,78 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+type AuditHandler struct {
+	auditUseCase *usecase.AuditUseCase
+}
+
+func NewAuditHandler(uc *usecase.AuditUseCase) *AuditHandler {
+	return &AuditHandler{auditUseCase: uc}
+}
+
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	q := r.URL.Query()
+	filter := entity.AuditFilter{
+		ActorUserID:   q.Get("actor_user_id"),
+		ActorAPIKeyID: q.Get("actor_api_key_id"),
+		Action:        q.Get("action"),
+		TargetType:    q.Get("target_type"),
+		TargetID:      q.Get("target_id"),
+		WorkspaceID:   q.Get("workspace_id"),
+	}
+
+	if fromStr := q.Get("from"); fromStr != "" {
+		from, err := time.Parse(time.RFC3339, fromStr)
+		if err != nil {
+			Error(w, http.StatusBadRequest, "Invalid from, expected RFC 3339 time")
+			return
+		}
+		filter.From = &from
+	}
+
+	if toStr := q.Get("to"); toStr != "" {
+		to, err := time.Parse(time.RFC3339, toStr)
+		if err != nil {
+			Error(w, http.StatusBadRequest, "Invalid to, expected RFC 3339 time")
+			return
+		}
+		filter.To = &to
+	}
+
+	if cursor := q.Get("cursor"); cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			Error(w, http.StatusBadRequest, "Invalid cursor")
+			return
+		}
+		filter.Cursor = id
+	}
+
+	filter.Limit, _ = strconv.Atoi(q.Get("limit"))
+
+	page, err := h.auditUseCase.ListEvents(r.Context(), userID, filter)
+	if err != nil {
+		if err == usecase.ErrUnauthorized {
+			Error(w, http.StatusForbidden, "You cannot view this audit log")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "Failed to get audit events")
+		return
+	}
+
+	Success(w, http.StatusOK, "Audit events retrieved", page)
+}
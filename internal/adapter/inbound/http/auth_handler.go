@@ -0,0 +1,93 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/go-playground/validator/v10"
+)
+
+type AuthHandler struct {
+	authUseCase *usecase.AuthUseCase
+	validate    *validator.Validate
+}
+
+func NewAuthHandler(uc *usecase.AuthUseCase) *AuthHandler {
+	return &AuthHandler{
+		authUseCase: uc,
+		validate:    validator.New(),
+	}
+}
+
+func (h *AuthHandler) Signup(w http.ResponseWriter, r *http.Request) {
+	var req entity.SignupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authUseCase.Signup(r.Context(), req)
+	if err != nil {
+		if err == usecase.ErrEmailExists {
+			Error(w, http.StatusConflict, "Email already registered")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "Failed to sign up")
+		return
+	}
+
+	Success(w, http.StatusCreated, "Account created", response)
+}
+
+func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var req entity.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.authUseCase.Login(r.Context(), req)
+	if err != nil {
+		if err == usecase.ErrInvalidCredentials {
+			Error(w, http.StatusUnauthorized, "Invalid email or password")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "Failed to log in")
+		return
+	}
+
+	Success(w, http.StatusOK, "Logged in", response)
+}
+
+func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	user, err := h.authUseCase.GetUser(r.Context(), userID)
+	if err != nil {
+		if err == usecase.ErrUserNotFound {
+			Error(w, http.StatusNotFound, "User not found")
+			return
+		}
+		Error(w, http.StatusInternalServerError, "Failed to get user")
+		return
+	}
+
+	Success(w, http.StatusOK, "User retrieved", user)
+}
@@ -6,14 +6,17 @@ import (
 	"strings"
 
 	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
 )
 
 type contextKey string
 
 const (
-	ContextKeyUserID     contextKey = "user_id"
-	ContextKeyAPIKeyID   contextKey = "api_key_id"
-	ContextKeyAPIScopes  contextKey = "api_key_scopes"
+	ContextKeyUserID       contextKey = "user_id"
+	ContextKeyAPIKeyID     contextKey = "api_key_id"
+	ContextKeyAPIScopes    contextKey = "api_key_scopes"
+	ContextKeyAPIRateLimit contextKey = "api_key_rate_limit"
+	ContextKeyWorkspaceID  contextKey = "workspace_id"
 )
 
 type APIKeyMiddleware struct {
@@ -43,13 +46,17 @@ func (m *APIKeyMiddleware) Authenticate(next http.Handler) http.Handler {
 
 		key := parts[1]
 		if !strings.HasPrefix(key, "sk_") {
-			http.Error(w, `{"success":false,"message":"Invalid API key format"}`, http.StatusUnauthorized)
+			// Not an API key, session tokens are handled by AuthMiddleware
+			next.ServeHTTP(w, r)
 			return
 		}
 
 		// Validate API key
 		apiKey, err := m.apiKeyUseCase.ValidateAPIKey(r.Context(), key)
 		if err != nil {
+			if !respondIPLimit(w, r) {
+				return
+			}
 			switch err {
 			case usecase.ErrAPIKeyNotFound:
 				http.Error(w, `{"success":false,"message":"Invalid API key"}`, http.StatusUnauthorized)
@@ -67,7 +74,51 @@ func (m *APIKeyMiddleware) Authenticate(next http.Handler) http.Handler {
 		ctx := context.WithValue(r.Context(), ContextKeyUserID, apiKey.UserID)
 		ctx = context.WithValue(ctx, ContextKeyAPIKeyID, apiKey.ID)
 		ctx = context.WithValue(ctx, ContextKeyAPIScopes, apiKey.Scopes)
+		ctx = context.WithValue(ctx, ContextKeyAPIRateLimit, apiKey.RateLimit)
+		if apiKey.WorkspaceID != "" {
+			ctx = context.WithValue(ctx, ContextKeyWorkspaceID, apiKey.WorkspaceID)
+		}
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// RequireScope rejects API key requests whose key lacks the given scope.
+// Requests that are not authenticated with an API key pass through.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, ok := r.Context().Value(ContextKeyAPIScopes).([]string)
+			if ok && !entity.HasScope(scopes, scope) {
+				http.Error(w, `{"success":false,"message":"API key is missing required scope: `+scope+`"}`, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func UserIDFromContext(ctx context.Context) string {
+	userID, _ := ctx.Value(ContextKeyUserID).(string)
+	return userID
+}
+
+func APIKeyIDFromContext(ctx context.Context) string {
+	keyID, _ := ctx.Value(ContextKeyAPIKeyID).(string)
+	return keyID
+}
+
+// APIScopesFromContext returns the scopes of the API key used for the
+// request, if any.
+func APIScopesFromContext(ctx context.Context) []string {
+	scopes, _ := ctx.Value(ContextKeyAPIScopes).([]string)
+	return scopes
+}
+
+// WorkspaceIDFromContext returns the workspace of the API key used for the
+// request, if any.
+func WorkspaceIDFromContext(ctx context.Context) string {
+	workspaceID, _ := ctx.Value(ContextKeyWorkspaceID).(string)
+	return workspaceID
+}
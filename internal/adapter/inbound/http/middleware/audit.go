@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+)
+
+// AuditActor records who is making the request so use cases can attribute
+// their audit events. It must run after authentication.
+func AuditActor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := usecase.WithActor(r.Context(), usecase.Actor{
+			UserID:    UserIDFromContext(r.Context()),
+			APIKeyID:  APIKeyIDFromContext(r.Context()),
+			IPAddress: ClientIP(r),
+
+			WorkspaceID: WorkspaceIDFromContext(r.Context()),
+			Scopes:      APIScopesFromContext(r.Context()),
+		})
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+)
+
+type AuthMiddleware struct {
+	authUseCase *usecase.AuthUseCase
+}
+
+func NewAuthMiddleware(uc *usecase.AuthUseCase) *AuthMiddleware {
+	return &AuthMiddleware{authUseCase: uc}
+}
+
+// Authenticate accepts session tokens issued at login. API keys are left to
+// APIKeyMiddleware, and requests without credentials continue anonymously.
+func (m *AuthMiddleware) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := bearerToken(r)
+		if !ok || strings.HasPrefix(token, "sk_") || UserIDFromContext(r.Context()) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		userID, err := m.authUseCase.ValidateToken(token)
+		if err != nil {
+			http.Error(w, `{"success":false,"message":"Invalid or expired session token"}`, http.StatusUnauthorized)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), ContextKeyUserID, userID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	authHeader := r.Header.Get("Authorization")
+	parts := strings.SplitN(authHeader, " ", 2)
+	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
+		return "", false
+	}
+	return parts[1], true
+}
@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const (
+	contextKeyClientIP contextKey = "client_ip"
+	contextKeyHTTPS    contextKey = "https"
+)
+
+// TrustProxies works out the IP of each request's client for ClientIP. The
+// X-Forwarded-For and X-Real-IP headers are only believed on requests from
+// one of the trusted proxies, as any client can send them. X-Forwarded-For
+// is read from the right, skipping trusted proxies, so a client cannot put
+// an address of its choosing in front. It must run before anything that
+// looks at the client IP. X-Forwarded-Proto is likewise only believed from a
+// trusted proxy for IsHTTPS.
+func TrustProxies(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := context.WithValue(r.Context(), contextKeyClientIP, resolveClientIP(r, trusted))
+			ctx = context.WithValue(ctx, contextKeyHTTPS, resolveHTTPS(r, trusted))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ClientIP returns the IP of the client making r, as worked out by
+// TrustProxies, or the address of the connection's peer without it.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(contextKeyClientIP).(string); ok {
+		return ip
+	}
+	return peerIP(r)
+}
+
+// IsHTTPS reports whether the client reached us over HTTPS, directly or
+// through a trusted proxy as worked out by TrustProxies.
+func IsHTTPS(r *http.Request) bool {
+	if https, ok := r.Context().Value(contextKeyHTTPS).(bool); ok {
+		return https
+	}
+	return r.TLS != nil
+}
+
+// ParseTrustedProxies parses a comma separated list of IP addresses and
+// CIDR ranges.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+
+		if strings.Contains(field, "/") {
+			prefix, err := netip.ParsePrefix(field)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", field, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", field, err)
+		}
+		addr = addr.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+	}
+	return prefixes, nil
+}
+
+func resolveClientIP(r *http.Request, trusted []netip.Prefix) string {
+	ip := peerIP(r)
+	if !isTrusted(ip, trusted) {
+		return ip
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+			if err != nil {
+				break
+			}
+			ip = addr.Unmap().String()
+			if !isTrusted(ip, trusted) {
+				break
+			}
+		}
+		return ip
+	}
+
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return ip
+}
+
+func resolveHTTPS(r *http.Request, trusted []netip.Prefix) bool {
+	if r.TLS != nil {
+		return true
+	}
+	return isTrusted(peerIP(r), trusted) && r.Header.Get("X-Forwarded-Proto") == "https"
+}
+
+func isTrusted(ip string, trusted []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// peerIP strips the port so all connections from one client share an IP.
+func peerIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.1")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		xff        []string
+		realIP     string
+		want       string
+	}{
+		{"direct client", "198.51.100.7:4000", nil, "", "198.51.100.7"},
+		{"direct client spoofing", "198.51.100.7:4000", []string{"203.0.113.9"}, "203.0.113.9", "198.51.100.7"},
+		{"behind a proxy", "10.0.0.2:4000", []string{"203.0.113.9"}, "", "203.0.113.9"},
+		{"prepended address", "10.0.0.2:4000", []string{"1.2.3.4, 203.0.113.9"}, "", "203.0.113.9"},
+		{"chain of proxies", "10.0.0.2:4000", []string{"203.0.113.9, 192.0.2.1", "10.1.1.1"}, "", "203.0.113.9"},
+		{"garbage in the chain", "10.0.0.2:4000", []string{"bogus, 203.0.113.9"}, "", "203.0.113.9"},
+		{"X-Real-IP", "192.0.2.1:4000", nil, "203.0.113.9", "203.0.113.9"},
+		{"no forwarding headers", "192.0.2.1:4000", nil, "", "192.0.2.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := TrustProxies(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = ClientIP(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, xff := range tt.xff {
+				req.Header.Add("X-Forwarded-For", xff)
+			}
+			if tt.realIP != "" {
+				req.Header.Set("X-Real-IP", tt.realIP)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("ClientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if got, err := ParseTrustedProxies(""); err != nil || len(got) != 0 {
+		t.Errorf("ParseTrustedProxies(\"\") = %v, %v, want none", got, err)
+	}
+	if _, err := ParseTrustedProxies("10.0.0.0/8,not-an-ip"); err == nil {
+		t.Error("ParseTrustedProxies() with a bad entry should fail")
+	}
+}
+
+func TestIsHTTPS(t *testing.T) {
+	trusted, err := ParseTrustedProxies("10.0.0.0/8")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		proto      string
+		want       bool
+	}{
+		{"direct client", "198.51.100.7:4000", "", false},
+		{"direct client spoofing", "198.51.100.7:4000", "https", false},
+		{"behind a proxy", "10.0.0.2:4000", "https", true},
+		{"plain HTTP behind a proxy", "10.0.0.2:4000", "http", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got bool
+			handler := TrustProxies(trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = IsHTTPS(r)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.proto != "" {
+				req.Header.Set("X-Forwarded-Proto", tt.proto)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			if got != tt.want {
+				t.Errorf("IsHTTPS() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -39,7 +39,7 @@ func Logging(logger *slog.Logger) func(http.Handler) http.Handler {
 				slog.Int("status", rw.status),
 				slog.Duration("duration", duration),
 				slog.Int("size", rw.size),
-				slog.String("ip", getIP(r)),
+				slog.String("ip", ClientIP(r)),
 			)
 		})
 	}
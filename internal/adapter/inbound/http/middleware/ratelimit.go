@@ -1,93 +1,237 @@
 package middleware
 
 import (
+	"context"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"golang.org/x/time/rate"
 )
 
+// RateLimitStore keeps the quota state for rate limited clients.
+type RateLimitStore interface {
+	Allow(ctx context.Context, key string, requestsPerSecond, burst int) (RateLimitResult, error)
+}
+
+// RateLimitResult describes the state of a client's quota after a request.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	ResetAfter time.Duration // until the quota is fully replenished
+	RetryAfter time.Duration // until the next request is allowed, zero if allowed
+}
+
 type RateLimiter struct {
-	visitors map[string]*visitor
-	mu       sync.RWMutex
-	limit    rate.Limit
+	store    RateLimitStore
+	fallback RateLimitStore
+	limit    int
 	burst    int
 }
 
+func NewRateLimiter(requestsPerSecond int, burst int) *RateLimiter {
+	return &RateLimiter{
+		store: NewMemoryRateLimitStore(),
+		limit: requestsPerSecond,
+		burst: burst,
+	}
+}
+
+// NewRateLimiterWithStore uses the given store and falls back to an
+// in-memory store whenever it returns an error.
+func NewRateLimiterWithStore(store RateLimitStore, requestsPerSecond int, burst int) *RateLimiter {
+	return &RateLimiter{
+		store:    store,
+		fallback: NewMemoryRateLimitStore(),
+		limit:    requestsPerSecond,
+		burst:    burst,
+	}
+}
+
+const contextKeyIPRateLimit contextKey = "ip_rate_limit"
+
+// LimitByIP limits every request by client IP. It must run before
+// authentication so that requests with invalid API keys or tokens count too.
+// Requests with an API key are counted but only held to the IP quota when the
+// key turns out invalid, as a valid key has a quota of its own.
+func (rl *RateLimiter) LimitByIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, ok := rl.take(r.Context(), "ip:"+ClientIP(r), rl.limit, rl.burst)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if token, _ := bearerToken(r); strings.HasPrefix(token, "sk_") {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyIPRateLimit, result)))
+			return
+		}
+		if !respond(w, result) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// LimitByAPIKey holds requests made with an API key to the key's own quota
+// instead of the IP one. It must run after authentication.
+func (rl *RateLimiter) LimitByAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keyID := APIKeyIDFromContext(r.Context())
+		if keyID == "" {
+			if respondIPLimit(w, r) {
+				next.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		limit, burst := rl.limit, rl.burst
+		if keyLimit, ok := r.Context().Value(ContextKeyAPIRateLimit).(int); ok && keyLimit > 0 {
+			limit, burst = keyLimit, keyLimit*2
+		}
+		if result, ok := rl.take(r.Context(), "key:"+keyID, limit, burst); ok && !respond(w, result) {
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take takes a request from the bucket. It returns false when the store
+// fails without a fallback, to let the request through.
+func (rl *RateLimiter) take(ctx context.Context, key string, limit, burst int) (RateLimitResult, bool) {
+	result, err := rl.store.Allow(ctx, key, limit, burst)
+	if err != nil {
+		if rl.fallback == nil {
+			// Fail open rather than take the API down with the store
+			return result, false
+		}
+		result, _ = rl.fallback.Allow(ctx, key, limit, burst)
+	}
+	return result, true
+}
+
+// respond sets the rate limit headers of result. It answers 429 and returns
+// false when the request is not allowed.
+func respond(w http.ResponseWriter, result RateLimitResult) bool {
+	setRateLimitHeaders(w, result)
+
+	if !result.Allowed {
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+		return false
+	}
+	return true
+}
+
+// respondIPLimit applies the IP quota LimitByIP left to authentication, for
+// an API key that failed to authenticate. It returns false once it answered
+// 429.
+func respondIPLimit(w http.ResponseWriter, r *http.Request) bool {
+	result, ok := r.Context().Value(contextKeyIPRateLimit).(RateLimitResult)
+	if !ok {
+		return true
+	}
+	return respond(w, result)
+}
+
+// MemoryRateLimitStore is a token bucket per client kept in process memory.
+type MemoryRateLimitStore struct {
+	visitors map[string]*visitor
+	mu       sync.Mutex
+}
+
 type visitor struct {
 	limiter  *rate.Limiter
 	lastSeen time.Time
 }
 
-func NewRateLimiter(requestsPerSecond int, burst int) *RateLimiter {
-	rl := &RateLimiter{
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	s := &MemoryRateLimitStore{
 		visitors: make(map[string]*visitor),
-		limit:    rate.Limit(requestsPerSecond),
-		burst:    burst,
 	}
 
 	// Cleanup old visitors every minute
-	go rl.cleanupVisitors()
+	go s.cleanupVisitors()
 
-	return rl
+	return s
 }
 
-func (rl *RateLimiter) getVisitor(ip string) *rate.Limiter {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
+func (s *MemoryRateLimitStore) getVisitor(key string, limit rate.Limit, burst int) *rate.Limiter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	v, exists := rl.visitors[ip]
+	v, exists := s.visitors[key]
 	if !exists {
-		limiter := rate.NewLimiter(rl.limit, rl.burst)
-		rl.visitors[ip] = &visitor{limiter: limiter, lastSeen: time.Now()}
+		limiter := rate.NewLimiter(limit, burst)
+		s.visitors[key] = &visitor{limiter: limiter, lastSeen: time.Now()}
 		return limiter
 	}
 
+	// The API key's limit may have been changed since we last saw it
+	if v.limiter.Limit() != limit {
+		v.limiter.SetLimit(limit)
+	}
+	if v.limiter.Burst() != burst {
+		v.limiter.SetBurst(burst)
+	}
+
 	v.lastSeen = time.Now()
 	return v.limiter
 }
 
-func (rl *RateLimiter) cleanupVisitors() {
+func (s *MemoryRateLimitStore) cleanupVisitors() {
 	for {
 		time.Sleep(time.Minute)
 
-		rl.mu.Lock()
-		for ip, v := range rl.visitors {
+		s.mu.Lock()
+		for key, v := range s.visitors {
 			if time.Since(v.lastSeen) > 3*time.Minute {
-				delete(rl.visitors, ip)
+				delete(s.visitors, key)
 			}
 		}
-		rl.mu.Unlock()
+		s.mu.Unlock()
 	}
 }
 
-func (rl *RateLimiter) Limit(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		ip := getIP(r)
-		limiter := rl.getVisitor(ip)
+func (s *MemoryRateLimitStore) Allow(ctx context.Context, key string, requestsPerSecond, burst int) (RateLimitResult, error) {
+	limit := rate.Limit(requestsPerSecond)
+	limiter := s.getVisitor(key, limit, burst)
 
-		if !limiter.Allow() {
-			http.Error(w, "Too many requests", http.StatusTooManyRequests)
-			return
-		}
+	now := time.Now()
+	allowed := limiter.AllowN(now, 1)
+	tokens := limiter.TokensAt(now)
 
-		next.ServeHTTP(w, r)
-	})
+	result := RateLimitResult{
+		Allowed:    allowed,
+		Limit:      burst,
+		Remaining:  max(int(math.Floor(tokens)), 0),
+		ResetAfter: tokensDuration(float64(burst)-tokens, limit),
+	}
+	if !allowed {
+		result.RetryAfter = tokensDuration(1-tokens, limit)
+	}
+
+	return result, nil
 }
 
-func getIP(r *http.Request) string {
-	// Check X-Forwarded-For first
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		return xff
+func setRateLimitHeaders(w http.ResponseWriter, result RateLimitResult) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("X-RateLimit-Reset", strconv.Itoa(ceilSeconds(result.ResetAfter)))
+	if !result.Allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(max(ceilSeconds(result.RetryAfter), 1)))
 	}
+}
 
-	// Check X-Real-IP
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
+func tokensDuration(tokens float64, limit rate.Limit) time.Duration {
+	if tokens <= 0 || limit <= 0 {
+		return 0
 	}
+	return time.Duration(tokens / float64(limit) * float64(time.Second))
+}
 
-	return r.RemoteAddr
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
 }
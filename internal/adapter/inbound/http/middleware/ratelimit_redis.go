@@ -0,0 +1,71 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const rateLimitKeyPrefix = "ratelimit:"
+
+// gcraScript implements the generic cell rate algorithm. The bucket is a
+// single key holding the theoretical arrival time (TAT) in microseconds, so
+// every replica sharing the Redis instance shares the same quota.
+//
+// KEYS[1] bucket key
+// ARGV[1] emission interval in microseconds
+// ARGV[2] burst
+//
+// Returns {allowed, remaining, reset_after_us, retry_after_us}.
+var gcraScript = redis.NewScript(`
+local interval = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local clock = redis.call("TIME")
+local now = tonumber(clock[1]) * 1000000 + tonumber(clock[2])
+local tolerance = interval * burst
+
+local tat = tonumber(redis.call("GET", KEYS[1]))
+if not tat or tat < now then
+	tat = now
+end
+
+local new_tat = tat + interval
+local allow_at = new_tat - tolerance
+if allow_at > now then
+	return {0, 0, tat - now, allow_at - now}
+end
+
+redis.call("SET", KEYS[1], new_tat, "PX", math.ceil((new_tat - now) / 1000))
+local remaining = math.floor((tolerance - (new_tat - now)) / interval)
+return {1, remaining, new_tat - now, 0}
+`)
+
+// RedisRateLimitStore keeps GCRA buckets in Redis.
+type RedisRateLimitStore struct {
+	client *redis.Client
+}
+
+func NewRedisRateLimitStore(client *redis.Client) *RedisRateLimitStore {
+	return &RedisRateLimitStore{client: client}
+}
+
+func (s *RedisRateLimitStore) Allow(ctx context.Context, key string, requestsPerSecond, burst int) (RateLimitResult, error) {
+	if requestsPerSecond <= 0 {
+		requestsPerSecond = 1
+	}
+	interval := int64(time.Second/time.Microsecond) / int64(requestsPerSecond)
+
+	values, err := gcraScript.Run(ctx, s.client, []string{rateLimitKeyPrefix + key}, max(interval, 1), burst).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Limit:      burst,
+		Remaining:  int(values[1]),
+		ResetAfter: time.Duration(values[2]) * time.Microsecond,
+		RetryAfter: time.Duration(values[3]) * time.Microsecond,
+	}, nil
+}
@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRateLimiter_LimitsByIP(t *testing.T) {
+	rl := NewRateLimiter(1, 2)
+	handler := rl.LimitByIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	codes := make([]int, 3)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		// Different ports, same client
+		req.RemoteAddr = fmt.Sprintf("10.0.0.1:%d", 40000+i)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes[i] = rec.Code
+
+		if rec.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("request %d: X-RateLimit-Limit = %q, want 2", i, rec.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Errorf("first two requests should pass, got %v", codes)
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("third request should be limited, got %d", codes[2])
+	}
+}
+
+func TestRateLimiter_UsesAPIKeyQuota(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	handler := rl.LimitByAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i := 0; i < 10; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		ctx := context.WithValue(req.Context(), ContextKeyAPIKeyID, "key-1")
+		ctx = context.WithValue(ctx, ContextKeyAPIRateLimit, 5)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req.WithContext(ctx))
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200 within key burst", i, rec.Code)
+		}
+		if rec.Header().Get("X-RateLimit-Limit") != "10" {
+			t.Errorf("X-RateLimit-Limit = %q, want 10", rec.Header().Get("X-RateLimit-Limit"))
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	ctx := context.WithValue(req.Context(), ContextKeyAPIKeyID, "key-1")
+	ctx = context.WithValue(ctx, ContextKeyAPIRateLimit, 5)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req.WithContext(ctx))
+
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429 after key burst", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header should be set on 429")
+	}
+	if rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("X-RateLimit-Remaining = %q, want 0", rec.Header().Get("X-RateLimit-Remaining"))
+	}
+}
+
+func TestRateLimiter_APIKeyQuotaSkipsOtherRequests(t *testing.T) {
+	rl := NewRateLimiter(1, 1)
+	handler := rl.LimitByAPIKey(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Left to the IP limit
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("request %d: status = %d, want 200 without an API key", i, rec.Code)
+		}
+	}
+}
+
+func TestRateLimiter_FallsBackWhenStoreFails(t *testing.T) {
+	client := redis.NewClient(&redis.Options{
+		Addr:        "127.0.0.1:1",
+		DialTimeout: 100 * time.Millisecond,
+		MaxRetries:  -1,
+	})
+	defer client.Close()
+
+	rl := NewRateLimiterWithStore(NewRedisRateLimitStore(client), 1, 1)
+	handler := rl.LimitByIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	codes := make([]int, 2)
+	for i := range codes {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		codes[i] = rec.Code
+	}
+
+	if codes[0] != http.StatusOK || codes[1] != http.StatusTooManyRequests {
+		t.Errorf("codes = %v, want in-memory limiting [200 429]", codes)
+	}
+}
@@ -0,0 +1,172 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/postgres"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/redis"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/sqlite"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+// BenchmarkRedirect measures GET /{code} and reports its p50 and p99
+// latency, uncached and with each cache available. Links are stored in
+// Postgres when TEST_DATABASE_DSN is set and in a temporary SQLite file
+// otherwise; the Redis run needs TEST_REDIS_ADDR. Both are emptied before
+// every run, so they are only used with BENCH_EMPTY_STORES=1.
+//
+//	go test -run '^$' -bench Redirect ./internal/adapter/inbound/http
+func BenchmarkRedirect(b *testing.B) {
+	caches := []struct {
+		name string
+		open func(b *testing.B) repository.URLCacheRepository
+	}{
+		{"cache=none", func(b *testing.B) repository.URLCacheRepository { return nil }},
+		{"cache=memory", func(b *testing.B) repository.URLCacheRepository {
+			return memory.NewURLCacheRepository(time.Hour)
+		}},
+		{"cache=redis", func(b *testing.B) repository.URLCacheRepository {
+			return openBenchRedis(b)
+		}},
+		{"cache=redis+local", func(b *testing.B) repository.URLCacheRepository {
+			cache := redis.NewTieredURLCache(openBenchRedis(b), 1000, time.Minute)
+			b.Cleanup(func() { cache.Close() })
+			return cache
+		}},
+	}
+
+	for _, cache := range caches {
+		b.Run(cache.name, func(b *testing.B) {
+			urls, clicks := openBenchDatabase(b)
+			benchmarkRedirect(b, urls, clicks, cache.open(b))
+		})
+	}
+}
+
+func benchmarkRedirect(b *testing.B, urls repository.URLRepository, clicks repository.ClickRepository, cache repository.URLCacheRepository) {
+	ctx := context.Background()
+
+	// Clicks are written in the background, as in cmd/api
+	ingester := usecase.NewClickIngester(usecase.ClickIngesterConfig{
+		URLRepo:   urls,
+		ClickRepo: clicks,
+		URLCache:  cache,
+		QueueSize: 100000,
+	})
+	b.Cleanup(func() { _ = ingester.Shutdown(ctx) })
+
+	handler := NewURLHandler(usecase.NewURLUseCase(usecase.URLUseCaseConfig{
+		URLRepo:       urls,
+		URLCache:      cache,
+		ClickRepo:     clicks,
+		ClickIngester: ingester,
+		BaseURL:       "http://sho.rt",
+	}))
+
+	url := &entity.URL{ShortCode: "bench1", OriginalURL: "https://example.com", IsActive: true}
+	if err := urls.Create(ctx, url); err != nil {
+		b.Fatal(err)
+	}
+
+	latencies := make([]time.Duration, 0, b.N)
+	for b.Loop() {
+		req := httptest.NewRequest("GET", "/bench1", nil)
+		req.SetPathValue("code", "bench1")
+		rec := httptest.NewRecorder()
+
+		start := time.Now()
+		handler.Redirect(rec, req)
+		latencies = append(latencies, time.Since(start))
+
+		if rec.Code != http.StatusMovedPermanently {
+			b.Fatalf("status = %d, want %d", rec.Code, http.StatusMovedPermanently)
+		}
+	}
+
+	slices.Sort(latencies)
+	b.ReportMetric(float64(percentile(latencies, 50).Nanoseconds()), "p50-ns")
+	b.ReportMetric(float64(percentile(latencies, 99).Nanoseconds()), "p99-ns")
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	return sorted[(len(sorted)-1)*p/100]
+}
+
+func openBenchDatabase(b *testing.B) (repository.URLRepository, repository.ClickRepository) {
+	b.Helper()
+
+	if dsn := os.Getenv("TEST_DATABASE_DSN"); dsn != "" {
+		requireEmptyStores(b, "TEST_DATABASE_DSN")
+
+		db, err := sql.Open("postgres", dsn)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Cleanup(func() { db.Close() })
+
+		if err := postgres.RunMigrations(context.Background(), db); err != nil {
+			b.Fatalf("RunMigrations() error = %v", err)
+		}
+		if _, err := db.Exec(`TRUNCATE urls, clicks RESTART IDENTITY CASCADE`); err != nil {
+			b.Fatalf("truncate: %v", err)
+		}
+		return postgres.NewURLRepository(db), postgres.NewClickRepository(db)
+	}
+
+	db, err := sqlite.Open(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { db.Close() })
+
+	migrator, err := sqlite.NewMigrator(db)
+	if err != nil {
+		b.Fatal(err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		b.Fatalf("Up() error = %v", err)
+	}
+	return sqlite.NewURLRepository(db), sqlite.NewClickRepository(db)
+}
+
+func openBenchRedis(b *testing.B) *redis.URLCacheRepository {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		b.Skip("TEST_REDIS_ADDR not set")
+	}
+	requireEmptyStores(b, "TEST_REDIS_ADDR")
+
+	client := goredis.NewClient(&goredis.Options{Addr: addr})
+	b.Cleanup(func() { client.Close() })
+
+	if err := client.FlushDB(context.Background()).Err(); err != nil {
+		b.Fatalf("flush: %v", err)
+	}
+	return redis.NewURLCacheRepository(client, time.Hour)
+}
+
+// requireEmptyStores skips the benchmark unless the store in env may be
+// emptied.
+func requireEmptyStores(b *testing.B, env string) {
+	if os.Getenv("BENCH_EMPTY_STORES") != "1" {
+		b.Skipf("%s is emptied by the benchmark, set BENCH_EMPTY_STORES=1 to allow it", env)
+	}
+}
@@ -3,36 +3,65 @@ package http
 import (
 	"log/slog"
 	"net/http"
+	"net/netip"
 
 	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
 )
 
 type RouterConfig struct {
-	URLHandler    *URLHandler
-	QRHandler     *QRHandler
-	APIKeyHandler *APIKeyHandler
-	Logger        *slog.Logger
-	RateLimit     int
+	URLHandler       *URLHandler
+	QRHandler        *QRHandler
+	APIKeyHandler    *APIKeyHandler
+	AuthHandler      *AuthHandler
+	WorkspaceHandler *WorkspaceHandler
+	AuditHandler     *AuditHandler
+	ClickIngester    *usecase.ClickIngester // reported by the health check
+	APIKeyMiddleware *middleware.APIKeyMiddleware
+	AuthMiddleware   *middleware.AuthMiddleware
+	RateLimiter      *middleware.RateLimiter
+	Logger           *slog.Logger
+	RateLimit        int
+	// TrustedProxies may set the client IP with X-Forwarded-For
+	TrustedProxies []netip.Prefix
 }
 
 func NewRouter(cfg RouterConfig) http.Handler {
 	mux := http.NewServeMux()
 
+	// scoped registers a route that API key requests may only call when the
+	// key carries the given scope
+	scoped := func(pattern, scope string, h http.HandlerFunc) {
+		mux.Handle(pattern, middleware.RequireScope(scope)(h))
+	}
+
 	// Health check
 	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		if cfg.ClickIngester != nil {
+			Success(w, http.StatusOK, "OK", map[string]any{"clicks": cfg.ClickIngester.Stats()})
+			return
+		}
 		Success(w, http.StatusOK, "OK", nil)
 	})
 
 	// API routes
-	mux.HandleFunc("POST /api/urls", cfg.URLHandler.CreateShortURL)
-	mux.HandleFunc("POST /api/urls/bulk", cfg.URLHandler.BulkCreateShortURLs)
-	mux.HandleFunc("GET /api/urls/{code}", cfg.URLHandler.GetURLInfo)
-	mux.HandleFunc("GET /api/urls/{code}/stats", cfg.URLHandler.GetStats)
-	mux.HandleFunc("DELETE /api/urls/{id}", cfg.URLHandler.DeleteURL)
-	mux.HandleFunc("GET /api/urls", cfg.URLHandler.GetUserURLs)
+	scoped("POST /api/urls", entity.ScopeURLsWrite, cfg.URLHandler.CreateShortURL)
+	scoped("POST /api/urls/bulk", entity.ScopeURLsWrite, cfg.URLHandler.BulkCreateShortURLs)
+	scoped("GET /api/urls/{code}", entity.ScopeURLsRead, cfg.URLHandler.GetURLInfo)
+	scoped("GET /api/urls/{code}/stats", entity.ScopeStatsRead, cfg.URLHandler.GetStats)
+	scoped("PATCH /api/urls/{id}", entity.ScopeURLsWrite, cfg.URLHandler.UpdateURL)
+	scoped("DELETE /api/urls/{id}", entity.ScopeURLsWrite, cfg.URLHandler.DeleteURL)
+	scoped("GET /api/urls/{id}/revisions", entity.ScopeURLsRead, cfg.URLHandler.GetURLRevisions)
+	scoped("POST /api/urls/{id}/revisions/{rev}/restore", entity.ScopeURLsWrite, cfg.URLHandler.RestoreURLRevision)
+	scoped("GET /api/urls/{id}/rules", entity.ScopeURLsRead, cfg.URLHandler.GetTargetingRules)
+	scoped("POST /api/urls/{id}/rules", entity.ScopeURLsWrite, cfg.URLHandler.AddTargetingRule)
+	scoped("PUT /api/urls/{id}/rules/{ruleID}", entity.ScopeURLsWrite, cfg.URLHandler.UpdateTargetingRule)
+	scoped("DELETE /api/urls/{id}/rules/{ruleID}", entity.ScopeURLsWrite, cfg.URLHandler.DeleteTargetingRule)
+	scoped("GET /api/urls", entity.ScopeURLsRead, cfg.URLHandler.GetUserURLs)
 
 	// QR Code
-	mux.HandleFunc("GET /api/urls/{code}/qr", cfg.QRHandler.GenerateQR)
+	scoped("GET /api/urls/{code}/qr", entity.ScopeURLsRead, cfg.QRHandler.GenerateQR)
 
 	// Link Preview
 	mux.HandleFunc("GET /api/preview", cfg.URLHandler.GetLinkPreview)
@@ -46,17 +75,69 @@ func NewRouter(cfg RouterConfig) http.Handler {
 
 	// API Key Management
 	if cfg.APIKeyHandler != nil {
-		mux.HandleFunc("POST /api/keys", cfg.APIKeyHandler.CreateAPIKey)
-		mux.HandleFunc("GET /api/keys", cfg.APIKeyHandler.GetAPIKeys)
-		mux.HandleFunc("POST /api/keys/{id}/revoke", cfg.APIKeyHandler.RevokeAPIKey)
-		mux.HandleFunc("DELETE /api/keys/{id}", cfg.APIKeyHandler.DeleteAPIKey)
+		scoped("POST /api/keys", entity.ScopeKeysManage, cfg.APIKeyHandler.CreateAPIKey)
+		scoped("GET /api/keys", entity.ScopeKeysManage, cfg.APIKeyHandler.GetAPIKeys)
+		scoped("POST /api/keys/{id}/revoke", entity.ScopeKeysManage, cfg.APIKeyHandler.RevokeAPIKey)
+		scoped("POST /api/keys/{id}/rotate", entity.ScopeKeysManage, cfg.APIKeyHandler.RotateAPIKey)
+		scoped("DELETE /api/keys/{id}", entity.ScopeKeysManage, cfg.APIKeyHandler.DeleteAPIKey)
+	}
+
+	// Accounts
+	if cfg.AuthHandler != nil {
+		mux.HandleFunc("POST /api/auth/signup", cfg.AuthHandler.Signup)
+		mux.HandleFunc("POST /api/auth/login", cfg.AuthHandler.Login)
+		mux.HandleFunc("GET /api/auth/me", cfg.AuthHandler.Me)
+	}
+
+	// Workspaces
+	if cfg.WorkspaceHandler != nil {
+		scoped("POST /api/workspaces", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.CreateWorkspace)
+		scoped("GET /api/workspaces", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.GetWorkspaces)
+		scoped("GET /api/workspaces/{id}", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.GetWorkspace)
+		scoped("GET /api/workspaces/{id}/members", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.GetMembers)
+		scoped("POST /api/workspaces/{id}/members", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.AddMember)
+		scoped("PATCH /api/workspaces/{id}/members/{userID}", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.UpdateMember)
+		scoped("DELETE /api/workspaces/{id}/members/{userID}", entity.ScopeWorkspacesManage, cfg.WorkspaceHandler.RemoveMember)
+	}
+
+	// Audit log
+	if cfg.AuditHandler != nil {
+		scoped("GET /api/audit", entity.ScopeAuditRead, cfg.AuditHandler.ListEvents)
 	}
 
 	// Redirect (must be last as it's a catch-all)
 	mux.HandleFunc("GET /{code}", cfg.URLHandler.Redirect)
+	mux.HandleFunc("POST /{code}", cfg.URLHandler.Unlock)
 
 	var handler http.Handler = mux
 
+	// Attribute audit events to the authenticated actor
+	handler = middleware.AuditActor(handler)
+
+	// API keys get their own quota once authenticated
+	rateLimiter := cfg.RateLimiter
+	if rateLimiter == nil && cfg.RateLimit > 0 {
+		rateLimiter = middleware.NewRateLimiter(cfg.RateLimit, cfg.RateLimit*2)
+	}
+	if rateLimiter != nil {
+		handler = rateLimiter.LimitByAPIKey(handler)
+	}
+
+	// Session authentication
+	if cfg.AuthMiddleware != nil {
+		handler = cfg.AuthMiddleware.Authenticate(handler)
+	}
+
+	// API key authentication
+	if cfg.APIKeyMiddleware != nil {
+		handler = cfg.APIKeyMiddleware.Authenticate(handler)
+	}
+
+	// Rate limiting by IP, before authentication so failed attempts count
+	if rateLimiter != nil {
+		handler = rateLimiter.LimitByIP(handler)
+	}
+
 	// CORS
 	corsConfig := middleware.DefaultCORSConfig()
 	handler = middleware.CORS(corsConfig)(handler)
@@ -67,11 +148,8 @@ func NewRouter(cfg RouterConfig) http.Handler {
 		handler = middleware.Recovery(cfg.Logger)(handler)
 	}
 
-	// Rate limiting
-	if cfg.RateLimit > 0 {
-		rateLimiter := middleware.NewRateLimiter(cfg.RateLimit, cfg.RateLimit*2)
-		handler = rateLimiter.Limit(handler)
-	}
+	// Client IP, which everything above relies on
+	handler = middleware.TrustProxies(cfg.TrustedProxies)(handler)
 
 	return handler
 }
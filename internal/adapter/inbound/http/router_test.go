@@ -0,0 +1,739 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	goredis "github.com/redis/go-redis/v9"
+
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/redis"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/pkg/geoip"
+)
+
+// newTestServer wires the router to in-memory repositories, the same way
+// cmd/api does with a database.
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return newTestServerWithCache(t, memory.NewURLCacheRepository(time.Hour))
+}
+
+func newTestServerWithCache(t *testing.T, cache repository.URLCacheRepository) *httptest.Server {
+	t.Helper()
+	return newTestServerWithConfig(t, cache, nil)
+}
+
+// newTestServerWithConfig lets configure adjust the router config, such as
+// the rate limit, before the server starts.
+func newTestServerWithConfig(t *testing.T, cache repository.URLCacheRepository, configure func(*RouterConfig)) *httptest.Server {
+	t.Helper()
+
+	users := memory.NewUserRepository()
+	workspaces := memory.NewWorkspaceRepository(users)
+	urls := memory.NewURLRepository()
+	audit := memory.NewAuditRepository()
+	countries, err := geoip.ReadCountryDB(strings.NewReader("5.1.0.0,5.1.255.255,DE\n36.64.0.0,36.95.255.255,ID\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	urlUseCase := usecase.NewURLUseCase(usecase.URLUseCaseConfig{
+		URLRepo:       urls,
+		URLCache:      cache,
+		ClickRepo:     memory.NewClickRepository(),
+		RevisionRepo:  memory.NewURLRevisionRepository(urls),
+		WorkspaceRepo: workspaces,
+		AuditRepo:     audit,
+		BaseURL:       "http://sho.rt",
+
+		PasswordAttemptRepo: memory.NewPasswordAttemptRepository(),
+		CountryDB:           countries,
+	})
+	apiKeyUseCase := usecase.NewAPIKeyUseCase(usecase.APIKeyUseCaseConfig{
+		APIKeyRepo:    memory.NewAPIKeyRepository(),
+		WorkspaceRepo: workspaces,
+		AuditRepo:     audit,
+	})
+	authUseCase := usecase.NewAuthUseCase(usecase.AuthUseCaseConfig{
+		UserRepo:  users,
+		AuditRepo: audit,
+		Secret:    "test-secret",
+	})
+
+	cfg := RouterConfig{
+		URLHandler:       NewURLHandler(urlUseCase),
+		QRHandler:        NewQRHandler(urlUseCase, "http://sho.rt"),
+		APIKeyHandler:    NewAPIKeyHandler(apiKeyUseCase),
+		AuthHandler:      NewAuthHandler(authUseCase),
+		WorkspaceHandler: NewWorkspaceHandler(usecase.NewWorkspaceUseCase(usecase.WorkspaceUseCaseConfig{WorkspaceRepo: workspaces, UserRepo: users, AuditRepo: audit})),
+		AuditHandler:     NewAuditHandler(usecase.NewAuditUseCase(usecase.AuditUseCaseConfig{AuditRepo: audit, WorkspaceRepo: workspaces})),
+		APIKeyMiddleware: middleware.NewAPIKeyMiddleware(apiKeyUseCase),
+		AuthMiddleware:   middleware.NewAuthMiddleware(authUseCase),
+	}
+	if configure != nil {
+		configure(&cfg)
+	}
+
+	server := httptest.NewServer(NewRouter(cfg))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// do sends a JSON request and decodes the response envelope. data receives
+// the payload of successful responses and may be nil.
+func do(t *testing.T, server *httptest.Server, method, path, token string, body, data any) *http.Response {
+	t.Helper()
+
+	var reader bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&reader).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req, err := http.NewRequest(method, server.URL+path, &reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if data != nil && resp.StatusCode < 300 {
+		envelope := Response{Data: data}
+		if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+			t.Fatalf("%s %s: decoding response: %v", method, path, err)
+		}
+	}
+	return resp
+}
+
+func signup(t *testing.T, server *httptest.Server, email string) string {
+	t.Helper()
+	var auth entity.AuthResponse
+	resp := do(t, server, "POST", "/api/auth/signup", "", entity.SignupRequest{Email: email, Password: "password123"}, &auth)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("signup status = %d", resp.StatusCode)
+	}
+	return auth.Token
+}
+
+func TestConcurrentSignup(t *testing.T) {
+	server := newTestServer(t)
+
+	body, _ := json.Marshal(entity.SignupRequest{Email: "alice@example.com", Password: "password123"})
+	statuses := make(chan int, 5)
+	var wg sync.WaitGroup
+	for range cap(statuses) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Post(server.URL+"/api/auth/signup", "application/json", bytes.NewReader(body))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+			statuses <- resp.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+
+	// Signups that passed the email check together still get a conflict
+	counts := map[int]int{}
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusConflict] != cap(statuses)-1 {
+		t.Errorf("signup statuses = %v, want one 201 and the rest 409", counts)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	server := newTestServer(t)
+
+	var url entity.URLResponse
+	resp := do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.com/page", CustomAlias: "example"}, &url)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create status = %d, want 201", resp.StatusCode)
+	}
+	if url.ShortURL != "http://sho.rt/example" {
+		t.Errorf("ShortURL = %q", url.ShortURL)
+	}
+
+	resp = do(t, server, "GET", "/example", "", nil, nil)
+	if resp.StatusCode != http.StatusMovedPermanently || resp.Header.Get("Location") != "https://example.com/page" {
+		t.Errorf("redirect = %d to %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	if got := resp.Header.Get("Cache-Control"); got != "private, max-age=3600" {
+		t.Errorf("Cache-Control = %q, want a bounded max-age", got)
+	}
+
+	if resp := do(t, server, "GET", "/missing", "", nil, nil); resp.StatusCode != http.StatusNotFound {
+		t.Errorf("missing link status = %d, want 404", resp.StatusCode)
+	}
+
+	tests := []struct {
+		name string
+		body any
+		want int
+	}{
+		{"alias taken", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "example"}, http.StatusConflict},
+		{"invalid url", entity.CreateURLRequest{OriginalURL: "not a url"}, http.StatusBadRequest},
+		{"unknown redirect type", entity.CreateURLRequest{OriginalURL: "https://example.com", RedirectType: 303}, http.StatusBadRequest},
+		{"short password", entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter2"}, http.StatusBadRequest},
+		{"malformed body", "{", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if resp := do(t, server, "POST", "/api/urls", "", tt.body, nil); resp.StatusCode != tt.want {
+			t.Errorf("create (%s) status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestRedirectTypes(t *testing.T) {
+	server := newTestServer(t)
+
+	tests := []struct {
+		redirectType int
+		cacheControl string
+	}{
+		{http.StatusMovedPermanently, "private, max-age=3600"},
+		{http.StatusFound, "no-cache"},
+		{http.StatusTemporaryRedirect, "no-cache"},
+		{http.StatusPermanentRedirect, "private, max-age=3600"},
+	}
+	for _, tt := range tests {
+		alias := "type" + strconv.Itoa(tt.redirectType)
+		if resp := do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: alias, RedirectType: tt.redirectType}, nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("create %d status = %d, want 201", tt.redirectType, resp.StatusCode)
+		}
+
+		resp := do(t, server, "GET", "/"+alias, "", nil, nil)
+		if resp.StatusCode != tt.redirectType || resp.Header.Get("Cache-Control") != tt.cacheControl {
+			t.Errorf("redirect = %d with Cache-Control %q, want %d with %q", resp.StatusCode, resp.Header.Get("Cache-Control"), tt.redirectType, tt.cacheControl)
+		}
+	}
+}
+
+func TestTargetingRules(t *testing.T) {
+	server := newTestServer(t)
+	alice := signup(t, server, "alice@example.com")
+	bob := signup(t, server, "bob@example.com")
+
+	var link entity.URLResponse
+	do(t, server, "POST", "/api/urls", alice, entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "app"}, &link)
+	rulesPath := "/api/urls/" + link.ID + "/rules"
+
+	do(t, server, "POST", rulesPath, alice, entity.TargetingRuleRequest{Device: "Tablet", URL: "https://example.com/tablet"}, nil)
+
+	var ios, android entity.TargetingRule
+	if resp := do(t, server, "POST", rulesPath, alice, entity.TargetingRuleRequest{OS: "iOS", URL: "https://apps.apple.com/app/id1"}, &ios); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("add rule status = %d, want 201", resp.StatusCode)
+	}
+	do(t, server, "POST", rulesPath, alice, entity.TargetingRuleRequest{OS: "Android", URL: "https://play.google.com/store/apps"}, &android)
+
+	userAgents := []struct {
+		name, userAgent, want string
+	}{
+		{"iPhone", "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148", "https://apps.apple.com/app/id1"},
+		{"Android phone", "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36", "https://play.google.com/store/apps"},
+		{"Android tablet", "Mozilla/5.0 (Linux; Android 14; SM-X710) AppleWebKit/537.36 Chrome/120.0 Safari/537.36", "https://example.com/tablet"},
+		{"desktop", "Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 Safari/605.1.15", "https://example.com"},
+	}
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for _, ua := range userAgents {
+		req, _ := http.NewRequest("GET", server.URL+"/app", nil)
+		req.Header.Set("User-Agent", ua.userAgent)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.Header.Get("Location") != ua.want {
+			t.Errorf("%s redirected to %q, want %q", ua.name, resp.Header.Get("Location"), ua.want)
+		}
+		// The destination depends on the visitor, so it must not be cached
+		if got := resp.Header.Get("Cache-Control"); got != "no-cache" {
+			t.Errorf("%s redirect Cache-Control = %q, want no-cache", ua.name, got)
+		}
+	}
+
+	rulePath := rulesPath + "/" + ios.ID
+	if resp := do(t, server, "PUT", rulePath, bob, entity.TargetingRuleRequest{OS: "iOS", URL: "https://example.org"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("update rule by another user status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, server, "PUT", rulePath, alice, entity.TargetingRuleRequest{Device: "Tablet", OS: "iOS", URL: "https://example.com/ipad"}, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("update rule status = %d, want 200", resp.StatusCode)
+	}
+	if resp := do(t, server, "DELETE", rulesPath+"/"+android.ID, alice, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("delete rule status = %d, want 200", resp.StatusCode)
+	}
+
+	var rules []entity.TargetingRule
+	do(t, server, "GET", rulesPath, alice, nil, &rules)
+	if len(rules) != 2 || rules[0].Device != "Tablet" || rules[1].URL != "https://example.com/ipad" {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   any
+		want   int
+	}{
+		{"no condition", "POST", rulesPath, entity.TargetingRuleRequest{URL: "https://example.org"}, http.StatusBadRequest},
+		{"unknown os", "POST", rulesPath, entity.TargetingRuleRequest{OS: "BeOS", URL: "https://example.org"}, http.StatusBadRequest},
+		{"invalid url", "POST", rulesPath, entity.TargetingRuleRequest{OS: "iOS", URL: "not a url"}, http.StatusBadRequest},
+		{"missing rule", "DELETE", rulesPath + "/missing", nil, http.StatusNotFound},
+		{"missing link", "GET", "/api/urls/missing/rules", nil, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if resp := do(t, server, tt.method, tt.path, alice, tt.body, nil); resp.StatusCode != tt.want {
+			t.Errorf("%s status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestCountryTargeting(t *testing.T) {
+	// The test client stands in for a proxy passing on visitors' addresses
+	server := newTestServerWithConfig(t, memory.NewURLCacheRepository(time.Hour), func(cfg *RouterConfig) {
+		cfg.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("127.0.0.1/32")}
+	})
+	token := signup(t, server, "alice@example.com")
+
+	var link entity.URLResponse
+	do(t, server, "POST", "/api/urls", token, entity.CreateURLRequest{OriginalURL: "https://example.com/sale", CustomAlias: "sale"}, &link)
+	for _, rule := range []entity.TargetingRuleRequest{
+		{Country: "DE", URL: "https://example.com/de"},
+		{Country: "ID", URL: "https://example.com/id"},
+	} {
+		if resp := do(t, server, "POST", "/api/urls/"+link.ID+"/rules", token, rule, nil); resp.StatusCode != http.StatusCreated {
+			t.Fatalf("add %s rule status = %d, want 201", rule.Country, resp.StatusCode)
+		}
+	}
+	if resp := do(t, server, "POST", "/api/urls/"+link.ID+"/rules", token, entity.TargetingRuleRequest{Country: "XX", URL: "https://example.com/xx"}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("unknown country status = %d, want 400", resp.StatusCode)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	for ip, want := range map[string]string{
+		"5.1.42.7":     "https://example.com/de",
+		"36.80.1.1":    "https://example.com/id",
+		"198.51.100.1": "https://example.com/sale",
+	} {
+		req, _ := http.NewRequest("GET", server.URL+"/sale", nil)
+		req.Header.Set("X-Forwarded-For", ip)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := resp.Header.Get("Location"); got != want {
+			t.Errorf("visitor from %s redirected to %q, want %q", ip, got, want)
+		}
+	}
+
+	// Untrusted clients cannot pick their country
+	spoofed := newTestServerWithConfig(t, memory.NewURLCacheRepository(time.Hour), nil)
+	token = signup(t, spoofed, "alice@example.com")
+	do(t, spoofed, "POST", "/api/urls", token, entity.CreateURLRequest{OriginalURL: "https://example.com/sale", CustomAlias: "sale"}, &link)
+	do(t, spoofed, "POST", "/api/urls/"+link.ID+"/rules", token, entity.TargetingRuleRequest{Country: "DE", URL: "https://example.com/de"}, nil)
+	req, _ := http.NewRequest("GET", spoofed.URL+"/sale", nil)
+	req.Header.Set("X-Forwarded-For", "5.1.42.7")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if got := resp.Header.Get("Location"); got != "https://example.com/sale" {
+		t.Errorf("spoofed X-Forwarded-For redirected to %q, want the default", got)
+	}
+}
+
+func TestPasswordProtectedRedirect(t *testing.T) {
+	server := newTestServer(t)
+
+	do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "secret", Password: "hunter22"}, nil)
+
+	if resp := do(t, server, "GET", "/secret", "", nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("redirect status = %d, want 403", resp.StatusCode)
+	}
+
+	if resp := do(t, server, "POST", "/api/urls/secret/verify", "", entity.VerifyPasswordRequest{Password: "wrong"}, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("verify (wrong) status = %d, want 401", resp.StatusCode)
+	}
+
+	var verified map[string]string
+	resp := do(t, server, "POST", "/api/urls/secret/verify", "", entity.VerifyPasswordRequest{Password: "hunter22"}, &verified)
+	if resp.StatusCode != http.StatusOK || verified["original_url"] != "https://example.com" {
+		t.Errorf("verify = %d, %v", resp.StatusCode, verified)
+	}
+
+	// Guessing on locks the client out for a while; the right password did
+	// not forget the wrong one before it
+	for range 4 {
+		if resp := do(t, server, "POST", "/api/urls/secret/verify", "", entity.VerifyPasswordRequest{Password: "wrong"}, nil); resp.StatusCode != http.StatusUnauthorized {
+			t.Fatalf("verify (wrong) status = %d, want 401", resp.StatusCode)
+		}
+	}
+	resp = do(t, server, "POST", "/api/urls/secret/verify", "", entity.VerifyPasswordRequest{Password: "wrong"}, nil)
+	if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "30" {
+		t.Errorf("verify (locked out) = %d, Retry-After %q, want 429 after 30s", resp.StatusCode, resp.Header.Get("Retry-After"))
+	}
+	if resp := do(t, server, "POST", "/api/urls/secret/verify", "", entity.VerifyPasswordRequest{Password: "hunter22"}, nil); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("verify (locked out, right password) status = %d, want 429", resp.StatusCode)
+	}
+
+	var stats entity.ClickStats
+	do(t, server, "GET", "/api/urls/secret/stats", "", nil, &stats)
+	if stats.PasswordLockouts != 1 || stats.LastPasswordLockout == nil {
+		t.Errorf("stats lockouts = %d, last %v, want 1", stats.PasswordLockouts, stats.LastPasswordLockout)
+	}
+}
+
+func TestPasswordUnlockPage(t *testing.T) {
+	server := newTestServer(t)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "secret", Password: "hunter22"}, nil)
+	do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.org", CustomAlias: "other", Password: "hunter22"}, nil)
+
+	browse := func(method, path string, form url.Values, cookies ...*http.Cookie) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(method, server.URL+path, strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept", "text/html,application/xhtml+xml")
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := browse("GET", "/secret", nil)
+	if resp.StatusCode != http.StatusForbidden || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Errorf("GET = %d %s, want the HTML form", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	resp = browse("POST", "/secret", url.Values{"password": {"wrong"}})
+	if resp.StatusCode != http.StatusUnauthorized || len(resp.Cookies()) != 0 {
+		t.Errorf("POST wrong password = %d with %d cookies, want 401 and none", resp.StatusCode, len(resp.Cookies()))
+	}
+
+	resp = browse("POST", "/secret", url.Values{"password": {"hunter22"}})
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "https://example.com" {
+		t.Fatalf("POST = %d to %q, want 303 to the link", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	cookies := resp.Cookies()
+	if len(cookies) != 1 || cookies[0].Path != "/secret" || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v, want one HttpOnly cookie for /secret", cookies)
+	}
+	unlock := cookies[0]
+
+	// The cookie opens the link, without a permanent redirect the browser
+	// would keep using once it expires
+	resp = browse("GET", "/secret", nil, unlock)
+	if resp.StatusCode != http.StatusFound || resp.Header.Get("Location") != "https://example.com" {
+		t.Errorf("GET with cookie = %d to %q, want 302 to the link", resp.StatusCode, resp.Header.Get("Location"))
+	}
+
+	// but no other link, even under that link's cookie name
+	stolen := &http.Cookie{Name: "unlock_other", Value: unlock.Value}
+	if resp := browse("GET", "/other", nil, stolen); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET other link with the cookie = %d, want 403", resp.StatusCode)
+	}
+	forged := &http.Cookie{Name: unlock.Name, Value: unlock.Value + "x"}
+	if resp := browse("GET", "/secret", nil, forged); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("GET with a forged cookie = %d, want 403", resp.StatusCode)
+	}
+
+	// Both the form and the cookie count as clicks
+	var info entity.URLResponse
+	do(t, server, "GET", "/api/urls/secret", "", nil, &info)
+	if info.ClickCount != 2 {
+		t.Errorf("ClickCount = %d, want 2", info.ClickCount)
+	}
+
+	// Guessing locks the form out, but not for a browser that unlocked it
+	for range 6 {
+		resp = browse("POST", "/secret", url.Values{"password": {"wrong"}})
+	}
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("POST after guessing = %d, want 429", resp.StatusCode)
+	}
+	resp = browse("POST", "/secret", url.Values{"password": {"hunter22"}}, unlock)
+	if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != "https://example.com" {
+		t.Errorf("POST with cookie while locked out = %d to %q, want 303 to the link", resp.StatusCode, resp.Header.Get("Location"))
+	}
+}
+
+// Creating a link caches it, so every redirect below is a cache hit and must
+// still see the password.
+func TestPasswordProtectedRedirectFromCache(t *testing.T) {
+	caches := map[string]func(t *testing.T) repository.URLCacheRepository{
+		"memory": func(t *testing.T) repository.URLCacheRepository {
+			return memory.NewURLCacheRepository(time.Hour)
+		},
+		"redis": func(t *testing.T) repository.URLCacheRepository {
+			return newTestRedisCache(t)
+		},
+		"redis+local": func(t *testing.T) repository.URLCacheRepository {
+			cache := redis.NewTieredURLCache(newTestRedisCache(t), 100, time.Hour)
+			t.Cleanup(func() { cache.Close() })
+			return cache
+		},
+	}
+
+	for name, open := range caches {
+		t.Run(name, func(t *testing.T) {
+			cache := open(t)
+			server := newTestServerWithCache(t, cache)
+
+			do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "secret", Password: "hunter22"}, nil)
+			if cached, _ := cache.Get(context.Background(), "secret"); cached == nil {
+				t.Fatal("link was not cached")
+			}
+
+			for range 3 {
+				resp := do(t, server, "GET", "/secret", "", nil, nil)
+				if resp.StatusCode != http.StatusForbidden {
+					t.Fatalf("redirect status = %d, want 403", resp.StatusCode)
+				}
+				if location := resp.Header.Get("Location"); location != "" {
+					t.Fatalf("redirected to %s", location)
+				}
+			}
+		})
+	}
+}
+
+func newTestRedisCache(t *testing.T) *redis.URLCacheRepository {
+	t.Helper()
+	client := goredis.NewClient(&goredis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	return redis.NewURLCacheRepository(client, time.Hour)
+}
+
+func TestOwnedLinks(t *testing.T) {
+	server := newTestServer(t)
+	alice := signup(t, server, "alice@example.com")
+	bob := signup(t, server, "bob@example.com")
+
+	var url entity.URLResponse
+	do(t, server, "POST", "/api/urls", alice, entity.CreateURLRequest{OriginalURL: "https://example.com"}, &url)
+
+	var urls []entity.URLResponse
+	if resp := do(t, server, "GET", "/api/urls", alice, nil, &urls); resp.StatusCode != http.StatusOK || len(urls) != 1 {
+		t.Errorf("list = %d, %d links, want 1", resp.StatusCode, len(urls))
+	}
+
+	if resp := do(t, server, "DELETE", "/api/urls/"+url.ID, bob, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("delete by another user status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, server, "DELETE", "/api/urls/"+url.ID, alice, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("delete by owner status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// Links created without an account have no owner, so no one may change them
+func TestAnonymousLinks(t *testing.T) {
+	server := newTestServer(t)
+	mallory := signup(t, server, "mallory@example.com")
+
+	var created entity.URLResponse
+	do(t, server, "POST", "/api/urls", "", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "anon"}, &created)
+
+	var info entity.URLResponse
+	if resp := do(t, server, "GET", "/api/urls/anon", "", nil, &info); resp.StatusCode != http.StatusOK || info.ID != "" {
+		t.Errorf("public info = %d with ID %q, want no ID", resp.StatusCode, info.ID)
+	}
+
+	target := "https://evil.example"
+	for _, token := range []string{"", mallory} {
+		if resp := do(t, server, "PATCH", "/api/urls/"+created.ID, token, entity.UpdateURLRequest{OriginalURL: &target}, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("PATCH with token %q status = %d, want 403", token, resp.StatusCode)
+		}
+		if resp := do(t, server, "DELETE", "/api/urls/"+created.ID, token, nil, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("DELETE with token %q status = %d, want 403", token, resp.StatusCode)
+		}
+		if resp := do(t, server, "POST", "/api/urls/"+created.ID+"/rules", token, entity.TargetingRuleRequest{OS: "iOS", URL: target}, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("add rule with token %q status = %d, want 403", token, resp.StatusCode)
+		}
+	}
+
+	if resp := do(t, server, "GET", "/anon", "", nil, nil); resp.Header.Get("Location") != "https://example.com" {
+		t.Errorf("redirect = %q, want the original destination", resp.Header.Get("Location"))
+	}
+}
+
+// A workspace API key acts for its workspace only, not as the user who made it
+func TestWorkspaceAPIKeyStaysInWorkspace(t *testing.T) {
+	server := newTestServer(t)
+	alice := signup(t, server, "alice@example.com")
+
+	var marketing, sales entity.WorkspaceResponse
+	do(t, server, "POST", "/api/workspaces", alice, entity.CreateWorkspaceRequest{Name: "Marketing"}, &marketing)
+	do(t, server, "POST", "/api/workspaces", alice, entity.CreateWorkspaceRequest{Name: "Sales"}, &sales)
+
+	var personal, salesLink entity.URLResponse
+	do(t, server, "POST", "/api/urls", alice, entity.CreateURLRequest{OriginalURL: "https://example.com"}, &personal)
+	do(t, server, "POST", "/api/urls", alice, entity.CreateURLRequest{OriginalURL: "https://example.com", WorkspaceID: sales.ID}, &salesLink)
+
+	var personalKey, key entity.APIKeyResponse
+	do(t, server, "POST", "/api/keys", alice, entity.CreateAPIKeyRequest{Name: "personal"}, &personalKey)
+	scopes := []string{entity.ScopeURLsRead, entity.ScopeURLsWrite, entity.ScopeKeysManage}
+	do(t, server, "POST", "/api/keys", alice, entity.CreateAPIKeyRequest{Name: "marketing", WorkspaceID: marketing.ID, Scopes: scopes}, &key)
+	if key.Key == "" {
+		t.Fatal("workspace key was not created")
+	}
+
+	var created entity.URLResponse
+	if resp := do(t, server, "POST", "/api/urls", key.Key, entity.CreateURLRequest{OriginalURL: "https://example.com"}, &created); resp.StatusCode != http.StatusCreated || created.WorkspaceID != marketing.ID {
+		t.Errorf("create = %d in workspace %q, want 201 in %q", resp.StatusCode, created.WorkspaceID, marketing.ID)
+	}
+	var urls []entity.URLResponse
+	if resp := do(t, server, "GET", "/api/urls", key.Key, nil, &urls); resp.StatusCode != http.StatusOK || len(urls) != 1 || urls[0].ID != created.ID {
+		t.Errorf("list = %d, %+v, want only the workspace link", resp.StatusCode, urls)
+	}
+	if resp := do(t, server, "GET", "/api/urls?workspace_id="+sales.ID, key.Key, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("list another workspace status = %d, want 403", resp.StatusCode)
+	}
+
+	target := "https://evil.example"
+	for name, id := range map[string]string{"personal": personal.ID, "other workspace": salesLink.ID} {
+		if resp := do(t, server, "PATCH", "/api/urls/"+id, key.Key, entity.UpdateURLRequest{OriginalURL: &target}, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("PATCH %s link status = %d, want 403", name, resp.StatusCode)
+		}
+		if resp := do(t, server, "DELETE", "/api/urls/"+id, key.Key, nil, nil); resp.StatusCode != http.StatusForbidden {
+			t.Errorf("DELETE %s link status = %d, want 403", name, resp.StatusCode)
+		}
+	}
+
+	if resp := do(t, server, "POST", "/api/keys/"+personalKey.ID+"/revoke", key.Key, nil, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("revoke personal key status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, server, "POST", "/api/keys", key.Key, entity.CreateAPIKeyRequest{Name: "escape", WorkspaceID: sales.ID}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("create key in another workspace status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, server, "PATCH", "/api/urls/"+personal.ID, alice, entity.UpdateURLRequest{OriginalURL: &target}, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("PATCH personal link with a session status = %d, want 200", resp.StatusCode)
+	}
+}
+
+// Guessing API keys or tokens uses up the client's IP quota like any request
+func TestRateLimitCountsFailedAuthentication(t *testing.T) {
+	server := newTestServerWithConfig(t, memory.NewURLCacheRepository(time.Hour), func(cfg *RouterConfig) {
+		cfg.RateLimiter = middleware.NewRateLimiter(1, 3)
+	})
+
+	statuses := make([]int, 5)
+	for i := range statuses {
+		token := "sk_guess"
+		if i%2 == 1 {
+			token = "not-a-jwt"
+		}
+		statuses[i] = do(t, server, "GET", "/api/urls", token, nil, nil).StatusCode
+	}
+
+	if statuses[0] != http.StatusUnauthorized || statuses[len(statuses)-1] != http.StatusTooManyRequests {
+		t.Errorf("statuses = %v, want 401 until the IP quota runs out, then 429", statuses)
+	}
+}
+
+// A valid API key is held to its own quota, not to the IP one
+func TestRateLimitAPIKeyQuota(t *testing.T) {
+	server := newTestServerWithConfig(t, memory.NewURLCacheRepository(time.Hour), func(cfg *RouterConfig) {
+		cfg.RateLimiter = middleware.NewRateLimiter(1, 3)
+	})
+	token := signup(t, server, "alice@example.com")
+
+	if resp := do(t, server, "POST", "/api/keys", token, entity.CreateAPIKeyRequest{Name: "greedy", RateLimit: 1_000_000}, nil); resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("create key above the maximum rate limit status = %d, want 400", resp.StatusCode)
+	}
+
+	// This uses up the IP quota
+	var key entity.APIKeyResponse
+	if resp := do(t, server, "POST", "/api/keys", token, entity.CreateAPIKeyRequest{Name: "busy", RateLimit: 5}, &key); resp.StatusCode != http.StatusCreated {
+		t.Fatalf("create key status = %d", resp.StatusCode)
+	}
+
+	for i := range 6 {
+		resp := do(t, server, "GET", "/api/urls", key.Key, nil, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("request %d with the key status = %d, want 200 within its quota", i, resp.StatusCode)
+		}
+		if limit := resp.Header.Get("X-RateLimit-Limit"); limit != "10" {
+			t.Errorf("request %d X-RateLimit-Limit = %q, want the key's 10", i, limit)
+		}
+	}
+
+	// The IP quota still applies to keys that do not authenticate
+	if resp := do(t, server, "GET", "/api/urls", "sk_guess", nil, nil); resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("unknown key past the IP quota status = %d, want 429", resp.StatusCode)
+	}
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	server := newTestServer(t)
+	token := signup(t, server, "alice@example.com")
+
+	var key entity.APIKeyResponse
+	resp := do(t, server, "POST", "/api/keys", token, entity.CreateAPIKeyRequest{Name: "read only", Scopes: []string{entity.ScopeURLsRead}}, &key)
+	if resp.StatusCode != http.StatusCreated || key.Key == "" {
+		t.Fatalf("create key = %d, %+v", resp.StatusCode, key)
+	}
+
+	if resp := do(t, server, "GET", "/api/urls", key.Key, nil, nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("list with urls:read status = %d, want 200", resp.StatusCode)
+	}
+	if resp := do(t, server, "POST", "/api/urls", key.Key, entity.CreateURLRequest{OriginalURL: "https://example.com"}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("create without urls:write status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, server, "GET", "/api/urls", "sk_unknown", nil, nil); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("unknown key status = %d, want 401", resp.StatusCode)
+	}
+
+	// A key managing keys cannot hand out scopes it does not have
+	do(t, server, "POST", "/api/keys", token, entity.CreateAPIKeyRequest{Name: "manager", Scopes: []string{entity.ScopeKeysManage, entity.ScopeURLsRead}}, &key)
+	if resp := do(t, server, "POST", "/api/keys", key.Key, entity.CreateAPIKeyRequest{Name: "audit", Scopes: []string{entity.ScopeAuditRead}}, nil); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("create key with a scope the caller lacks status = %d, want 403", resp.StatusCode)
+	}
+	if resp := do(t, server, "POST", "/api/keys", key.Key, entity.CreateAPIKeyRequest{Name: "reader", Scopes: []string{entity.ScopeURLsRead}}, nil); resp.StatusCode != http.StatusCreated {
+		t.Errorf("create key with a scope the caller has status = %d, want 201", resp.StatusCode)
+	}
+}
@@ -0,0 +1,152 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func (h *URLHandler) GetTargetingRules(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		Error(w, http.StatusBadRequest, "URL ID is required")
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	rules, err := h.urlUseCase.GetTargetingRules(r.Context(), id, userID)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to get targeting rules")
+		}
+		return
+	}
+
+	Success(w, http.StatusOK, "Targeting rules retrieved", rules)
+}
+
+func (h *URLHandler) AddTargetingRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		Error(w, http.StatusBadRequest, "URL ID is required")
+		return
+	}
+
+	var req entity.TargetingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	rule, err := h.urlUseCase.AddTargetingRule(r.Context(), id, userID, req)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		case usecase.ErrInvalidURL:
+			Error(w, http.StatusBadRequest, "Invalid URL format")
+		case usecase.ErrEmptyTargetingRule:
+			Error(w, http.StatusBadRequest, "A targeting rule needs a device, an OS or a country")
+		case usecase.ErrCountryTargetingDisabled:
+			Error(w, http.StatusBadRequest, "Country targeting is not enabled on this server")
+		case usecase.ErrTooManyTargetingRules:
+			Error(w, http.StatusConflict, "This URL has the maximum number of targeting rules")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to add targeting rule")
+		}
+		return
+	}
+
+	Success(w, http.StatusCreated, "Targeting rule added", rule)
+}
+
+func (h *URLHandler) UpdateTargetingRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ruleID := r.PathValue("ruleID")
+	if id == "" || ruleID == "" {
+		Error(w, http.StatusBadRequest, "URL ID and rule ID are required")
+		return
+	}
+
+	var req entity.TargetingRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	rule, err := h.urlUseCase.UpdateTargetingRule(r.Context(), id, ruleID, userID, req)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrTargetingRuleNotFound:
+			Error(w, http.StatusNotFound, "Targeting rule not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		case usecase.ErrInvalidURL:
+			Error(w, http.StatusBadRequest, "Invalid URL format")
+		case usecase.ErrEmptyTargetingRule:
+			Error(w, http.StatusBadRequest, "A targeting rule needs a device, an OS or a country")
+		case usecase.ErrCountryTargetingDisabled:
+			Error(w, http.StatusBadRequest, "Country targeting is not enabled on this server")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to update targeting rule")
+		}
+		return
+	}
+
+	Success(w, http.StatusOK, "Targeting rule updated", rule)
+}
+
+func (h *URLHandler) DeleteTargetingRule(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	ruleID := r.PathValue("ruleID")
+	if id == "" || ruleID == "" {
+		Error(w, http.StatusBadRequest, "URL ID and rule ID are required")
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	err := h.urlUseCase.DeleteTargetingRule(r.Context(), id, ruleID, userID)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrTargetingRuleNotFound:
+			Error(w, http.StatusNotFound, "Targeting rule not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to delete targeting rule")
+		}
+		return
+	}
+
+	Success(w, http.StatusOK, "Targeting rule deleted", nil)
+}
@@ -0,0 +1,132 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// unlockCookiePrefix names the cookie holding a link's unlock token; the
+// short code completes it and the cookie's path limits it to that link.
+const unlockCookiePrefix = "unlock_"
+
+var unlockPage = template.Must(template.New("unlock").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Password required</title>
+<style>
+body { font-family: system-ui, sans-serif; display: flex; justify-content: center; padding-top: 15vh; margin: 0; }
+form { width: 18rem; }
+input, button { width: 100%; box-sizing: border-box; padding: .5rem; margin-top: .5rem; font-size: 1rem; }
+.error { color: #b00020; }
+</style>
+</head>
+<body>
+<form method="post" action="/{{.ShortCode}}">
+<h1>Password required</h1>
+<p>This link is protected. Enter its password to continue.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<input type="password" name="password" aria-label="Password" autofocus required>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// acceptsHTML reports whether the client is a browser rather than an API
+// client.
+func acceptsHTML(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func renderUnlockPage(w http.ResponseWriter, code int, shortCode, errMessage string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_ = unlockPage.Execute(w, struct{ ShortCode, Error string }{shortCode, errMessage})
+}
+
+// isUnlocked reports whether the request carries a valid unlock cookie for
+// url.
+func (h *URLHandler) isUnlocked(r *http.Request, url *entity.URL) bool {
+	cookie, err := r.Cookie(unlockCookiePrefix + url.ShortCode)
+	if err != nil {
+		return false
+	}
+	return h.urlUseCase.IsUnlocked(url, cookie.Value)
+}
+
+// Unlock handles the password form of a protected link. The right password
+// sets a cookie that opens the link until it expires, then redirects.
+func (h *URLHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	shortCode := r.PathValue("code")
+
+	url, err := h.urlUseCase.GetOriginalURL(r.Context(), shortCode)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrURLExpired:
+			Error(w, http.StatusGone, "URL has expired")
+		case usecase.ErrURLInactive:
+			Error(w, http.StatusGone, "URL is no longer active")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to redirect")
+		}
+		return
+	}
+
+	// A browser that already unlocked the link is not held to a lockout
+	// others ran into
+	if h.isUnlocked(r, url) {
+		http.Redirect(w, r, h.visit(r, url), http.StatusSeeOther)
+		return
+	}
+
+	if _, err := h.urlUseCase.VerifyPassword(r.Context(), shortCode, r.PostFormValue("password")); err != nil {
+		var lockout *usecase.LockoutError
+		if errors.As(err, &lockout) {
+			setRetryAfter(w, lockout.RetryAfter)
+			renderUnlockPage(w, http.StatusTooManyRequests, shortCode, "Too many wrong passwords, try again later.")
+			return
+		}
+
+		switch err {
+		case usecase.ErrPasswordRequired, usecase.ErrInvalidPassword:
+			renderUnlockPage(w, http.StatusUnauthorized, shortCode, "Wrong password, try again.")
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to verify password")
+		}
+		return
+	}
+
+	token, expiresAt, err := h.urlUseCase.UnlockToken(url)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to unlock URL")
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     unlockCookiePrefix + url.ShortCode,
+		Value:    token,
+		Path:     "/" + url.ShortCode,
+		Expires:  expiresAt,
+		HttpOnly: true,
+		Secure:   middleware.IsHTTPS(r),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	destination := h.visit(r, url)
+
+	// See Other turns the form POST into a GET of the destination
+	http.Redirect(w, r, destination, http.StatusSeeOther)
+}
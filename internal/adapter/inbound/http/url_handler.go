@@ -2,11 +2,14 @@ package http
 
 import (
 	"encoding/json"
+	"errors"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
 	"github.com/bimakw/url-shortener/internal/application/usecase"
 	"github.com/bimakw/url-shortener/internal/domain/entity"
 	"github.com/go-playground/validator/v10"
@@ -37,13 +40,16 @@ func (h *URLHandler) CreateShortURL(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Get user ID from context if authenticated
-	if userID, ok := r.Context().Value("user_id").(string); ok {
-		req.UserID = userID
+	req.UserID = middleware.UserIDFromContext(r.Context())
+	if req.WorkspaceID == "" {
+		req.WorkspaceID = middleware.WorkspaceIDFromContext(r.Context())
 	}
 
 	response, err := h.urlUseCase.CreateShortURL(r.Context(), req)
 	if err != nil {
 		switch err {
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You cannot add URLs to this workspace")
 		case usecase.ErrAliasExists:
 			Error(w, http.StatusConflict, "Custom alias already exists")
 		case usecase.ErrInvalidURL:
@@ -79,16 +85,41 @@ func (h *URLHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if password protected
-	if url.PasswordHash != "" {
+	// Check if password protected; browsers get a form, API clients JSON
+	if url.PasswordHash != "" && !h.isUnlocked(r, url) {
+		if acceptsHTML(r) {
+			renderUnlockPage(w, http.StatusForbidden, shortCode, "")
+			return
+		}
 		Error(w, http.StatusForbidden, "This URL is password protected. Use POST /api/urls/{code}/verify to access.")
 		return
 	}
 
-	// Record click
+	destination := h.visit(r, url)
+
+	status, maxAge := h.urlUseCase.RedirectFor(url)
+	switch {
+	case url.PasswordHash != "":
+		// A cached redirect would skip the password check from then on
+		w.Header().Set("Cache-Control", "no-store")
+	case maxAge > 0:
+		// Without a max-age browsers keep permanent redirects forever, and
+		// never see a new destination or count as clicks again. Private
+		// keeps shared caches from answering for everyone.
+		w.Header().Set("Cache-Control", "private, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	default:
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+
+	http.Redirect(w, r, destination, status)
+}
+
+// visit records a click on url and returns where the visitor goes, as
+// chosen by the link's targeting rules.
+func (h *URLHandler) visit(r *http.Request, url *entity.URL) string {
 	click := &entity.Click{
-		ShortCode: shortCode,
-		IPAddress: getClientIP(r),
+		ShortCode: url.ShortCode,
+		IPAddress: middleware.ClientIP(r),
 		UserAgent: r.UserAgent(),
 		Referrer:  r.Referer(),
 	}
@@ -96,9 +127,10 @@ func (h *URLHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 	// Parse user agent for device/browser info
 	parseUserAgent(click)
 
-	_ = h.urlUseCase.RecordClick(r.Context(), click)
+	destination := h.urlUseCase.Route(url, click)
 
-	http.Redirect(w, r, url.OriginalURL, http.StatusMovedPermanently)
+	_ = h.urlUseCase.RecordClick(r.Context(), url, click)
+	return destination
 }
 
 func (h *URLHandler) GetURLInfo(w http.ResponseWriter, r *http.Request) {
@@ -158,8 +190,8 @@ func (h *URLHandler) GetStats(w http.ResponseWriter, r *http.Request) {
 }
 
 func (h *URLHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
-	userID, ok := r.Context().Value("user_id").(string)
-	if !ok || userID == "" {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
 		Error(w, http.StatusUnauthorized, "Authentication required")
 		return
 	}
@@ -171,8 +203,24 @@ func (h *URLHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 		limit = 20
 	}
 
-	urls, err := h.urlUseCase.GetUserURLs(r.Context(), userID, limit, offset)
+	// Workspace API keys list their own workspace's links by default
+	workspaceID := r.URL.Query().Get("workspace_id")
+	if workspaceID == "" {
+		workspaceID = middleware.WorkspaceIDFromContext(r.Context())
+	}
+
+	var urls []*entity.URLResponse
+	var err error
+	if workspaceID != "" {
+		urls, err = h.urlUseCase.GetWorkspaceURLs(r.Context(), workspaceID, userID, limit, offset)
+	} else {
+		urls, err = h.urlUseCase.GetUserURLs(r.Context(), userID, limit, offset)
+	}
 	if err != nil {
+		if err == usecase.ErrUnauthorized {
+			Error(w, http.StatusForbidden, "You are not a member of this workspace")
+			return
+		}
 		Error(w, http.StatusInternalServerError, "Failed to get URLs")
 		return
 	}
@@ -180,6 +228,106 @@ func (h *URLHandler) GetUserURLs(w http.ResponseWriter, r *http.Request) {
 	Success(w, http.StatusOK, "URLs retrieved", urls)
 }
 
+func (h *URLHandler) UpdateURL(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		Error(w, http.StatusBadRequest, "URL ID is required")
+		return
+	}
+
+	var req entity.UpdateURLRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	response, err := h.urlUseCase.UpdateURL(r.Context(), id, userID, req)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		case usecase.ErrAliasExists:
+			Error(w, http.StatusConflict, "Custom alias already exists")
+		case usecase.ErrInvalidURL:
+			Error(w, http.StatusBadRequest, "Invalid URL format")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to update URL")
+		}
+		return
+	}
+
+	Success(w, http.StatusOK, "URL updated", response)
+}
+
+func (h *URLHandler) GetURLRevisions(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		Error(w, http.StatusBadRequest, "URL ID is required")
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	revisions, err := h.urlUseCase.GetURLRevisions(r.Context(), id, userID)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to get revisions")
+		}
+		return
+	}
+
+	Success(w, http.StatusOK, "Revisions retrieved", revisions)
+}
+
+func (h *URLHandler) RestoreURLRevision(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if id == "" {
+		Error(w, http.StatusBadRequest, "URL ID is required")
+		return
+	}
+
+	revision, err := strconv.Atoi(r.PathValue("rev"))
+	if err != nil || revision <= 0 {
+		Error(w, http.StatusBadRequest, "Invalid revision number")
+		return
+	}
+
+	userID := middleware.UserIDFromContext(r.Context())
+
+	response, err := h.urlUseCase.RestoreURLRevision(r.Context(), id, userID, revision)
+	if err != nil {
+		switch err {
+		case usecase.ErrURLNotFound:
+			Error(w, http.StatusNotFound, "URL not found")
+		case usecase.ErrRevisionNotFound:
+			Error(w, http.StatusNotFound, "Revision not found")
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		case usecase.ErrAliasExists:
+			Error(w, http.StatusConflict, "Short code of this revision is now used by another URL")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to restore revision")
+		}
+		return
+	}
+
+	Success(w, http.StatusOK, "Revision restored", response)
+}
+
 func (h *URLHandler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if id == "" {
@@ -187,15 +335,18 @@ func (h *URLHandler) DeleteURL(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userID, _ := r.Context().Value("user_id").(string)
+	userID := middleware.UserIDFromContext(r.Context())
 
 	err := h.urlUseCase.DeleteURL(r.Context(), id, userID)
 	if err != nil {
-		if err == usecase.ErrURLNotFound {
+		switch err {
+		case usecase.ErrURLNotFound:
 			Error(w, http.StatusNotFound, "URL not found")
-			return
+		case usecase.ErrUnauthorized:
+			Error(w, http.StatusForbidden, "You do not have access to this URL")
+		default:
+			Error(w, http.StatusInternalServerError, "Failed to delete URL")
 		}
-		Error(w, http.StatusInternalServerError, "Failed to delete URL")
 		return
 	}
 
@@ -215,9 +366,12 @@ func (h *URLHandler) BulkCreateShortURLs(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Get user ID from context if authenticated
-	if userID, ok := r.Context().Value("user_id").(string); ok {
-		for i := range req.URLs {
-			req.URLs[i].UserID = userID
+	userID := middleware.UserIDFromContext(r.Context())
+	workspaceID := middleware.WorkspaceIDFromContext(r.Context())
+	for i := range req.URLs {
+		req.URLs[i].UserID = userID
+		if req.URLs[i].WorkspaceID == "" {
+			req.URLs[i].WorkspaceID = workspaceID
 		}
 	}
 
@@ -266,6 +420,13 @@ func (h *URLHandler) VerifyPassword(w http.ResponseWriter, r *http.Request) {
 
 	url, err := h.urlUseCase.VerifyPassword(r.Context(), shortCode, req.Password)
 	if err != nil {
+		var lockout *usecase.LockoutError
+		if errors.As(err, &lockout) {
+			setRetryAfter(w, lockout.RetryAfter)
+			Error(w, http.StatusTooManyRequests, "Too many wrong passwords, try again later")
+			return
+		}
+
 		switch err {
 		case usecase.ErrURLNotFound:
 			Error(w, http.StatusNotFound, "URL not found")
@@ -279,21 +440,18 @@ func (h *URLHandler) VerifyPassword(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Record click
-	click := &entity.Click{
-		ShortCode: shortCode,
-		IPAddress: getClientIP(r),
-		UserAgent: r.UserAgent(),
-		Referrer:  r.Referer(),
-	}
-	parseUserAgent(click)
-	_ = h.urlUseCase.RecordClick(r.Context(), click)
+	destination := h.visit(r, url)
 
 	Success(w, http.StatusOK, "Password verified", map[string]string{
-		"original_url": url.OriginalURL,
+		"original_url": destination,
 	})
 }
 
+// setRetryAfter tells the client how many seconds to wait before retrying.
+func setRetryAfter(w http.ResponseWriter, d time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(int(math.Ceil(d.Seconds())), 1)))
+}
+
 func (h *URLHandler) CheckPasswordProtected(w http.ResponseWriter, r *http.Request) {
 	shortCode := r.PathValue("code")
 	if shortCode == "" {
@@ -364,36 +522,15 @@ func (h *URLHandler) StripUTM(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-func getClientIP(r *http.Request) string {
-	// Check X-Forwarded-For header
-	xff := r.Header.Get("X-Forwarded-For")
-	if xff != "" {
-		ips := strings.Split(xff, ",")
-		return strings.TrimSpace(ips[0])
-	}
-
-	// Check X-Real-IP header
-	xri := r.Header.Get("X-Real-IP")
-	if xri != "" {
-		return xri
-	}
-
-	// Fall back to RemoteAddr
-	ip := r.RemoteAddr
-	if colonIdx := strings.LastIndex(ip, ":"); colonIdx != -1 {
-		ip = ip[:colonIdx]
-	}
-	return ip
-}
-
 func parseUserAgent(click *entity.Click) {
 	ua := strings.ToLower(click.UserAgent)
 
-	// Simple device detection
-	if strings.Contains(ua, "mobile") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone") {
-		click.Device = "Mobile"
-	} else if strings.Contains(ua, "tablet") || strings.Contains(ua, "ipad") {
+	// Simple device detection; Android tablets leave "mobile" out
+	if strings.Contains(ua, "tablet") || strings.Contains(ua, "ipad") ||
+		strings.Contains(ua, "android") && !strings.Contains(ua, "mobile") {
 		click.Device = "Tablet"
+	} else if strings.Contains(ua, "mobile") || strings.Contains(ua, "android") || strings.Contains(ua, "iphone") {
+		click.Device = "Mobile"
 	} else {
 		click.Device = "Desktop"
 	}
@@ -412,18 +549,19 @@ func parseUserAgent(click *entity.Click) {
 		click.Browser = "Other"
 	}
 
-	// Simple OS detection
+	// Simple OS detection. iOS claims to be "like Mac OS X" and Android
+	// runs on Linux, so both are checked first.
 	switch {
 	case strings.Contains(ua, "windows"):
 		click.OS = "Windows"
+	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
+		click.OS = "iOS"
+	case strings.Contains(ua, "android"):
+		click.OS = "Android"
 	case strings.Contains(ua, "mac"):
 		click.OS = "macOS"
 	case strings.Contains(ua, "linux"):
 		click.OS = "Linux"
-	case strings.Contains(ua, "android"):
-		click.OS = "Android"
-	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
-		click.OS = "iOS"
 	default:
 		click.OS = "Other"
 	}
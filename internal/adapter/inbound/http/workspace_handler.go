@@ -0,0 +1,188 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/bimakw/url-shortener/internal/adapter/inbound/http/middleware"
+	"github.com/bimakw/url-shortener/internal/application/usecase"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/go-playground/validator/v10"
+)
+
+type WorkspaceHandler struct {
+	workspaceUseCase *usecase.WorkspaceUseCase
+	validate         *validator.Validate
+}
+
+func NewWorkspaceHandler(uc *usecase.WorkspaceUseCase) *WorkspaceHandler {
+	return &WorkspaceHandler{
+		workspaceUseCase: uc,
+		validate:         validator.New(),
+	}
+}
+
+func (h *WorkspaceHandler) CreateWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req entity.CreateWorkspaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	response, err := h.workspaceUseCase.CreateWorkspace(r.Context(), userID, req)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to create workspace")
+		return
+	}
+
+	Success(w, http.StatusCreated, "Workspace created", response)
+}
+
+func (h *WorkspaceHandler) GetWorkspaces(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	workspaces, err := h.workspaceUseCase.GetUserWorkspaces(r.Context(), userID)
+	if err != nil {
+		Error(w, http.StatusInternalServerError, "Failed to get workspaces")
+		return
+	}
+
+	Success(w, http.StatusOK, "Workspaces retrieved", workspaces)
+}
+
+func (h *WorkspaceHandler) GetWorkspace(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	workspace, err := h.workspaceUseCase.GetWorkspace(r.Context(), r.PathValue("id"), userID)
+	if err != nil {
+		workspaceError(w, err, "Failed to get workspace")
+		return
+	}
+
+	Success(w, http.StatusOK, "Workspace retrieved", workspace)
+}
+
+func (h *WorkspaceHandler) GetMembers(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	members, err := h.workspaceUseCase.GetMembers(r.Context(), r.PathValue("id"), userID)
+	if err != nil {
+		workspaceError(w, err, "Failed to get members")
+		return
+	}
+
+	Success(w, http.StatusOK, "Members retrieved", members)
+}
+
+func (h *WorkspaceHandler) AddMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req entity.AddWorkspaceMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	member, err := h.workspaceUseCase.AddMember(r.Context(), r.PathValue("id"), userID, req)
+	if err != nil {
+		workspaceError(w, err, "Failed to add member")
+		return
+	}
+
+	Success(w, http.StatusCreated, "Member added", member)
+}
+
+func (h *WorkspaceHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	var req entity.UpdateWorkspaceMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		Error(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		Error(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	member, err := h.workspaceUseCase.UpdateMemberRole(r.Context(), r.PathValue("id"), userID, r.PathValue("userID"), req)
+	if err != nil {
+		workspaceError(w, err, "Failed to update member")
+		return
+	}
+
+	Success(w, http.StatusOK, "Member updated", member)
+}
+
+func (h *WorkspaceHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.UserIDFromContext(r.Context())
+	if userID == "" {
+		Error(w, http.StatusUnauthorized, "Authentication required")
+		return
+	}
+
+	err := h.workspaceUseCase.RemoveMember(r.Context(), r.PathValue("id"), userID, r.PathValue("userID"))
+	if err != nil {
+		workspaceError(w, err, "Failed to remove member")
+		return
+	}
+
+	Success(w, http.StatusOK, "Member removed", nil)
+}
+
+// workspaceError writes the response for errors shared by workspace endpoints.
+func workspaceError(w http.ResponseWriter, err error, fallback string) {
+	switch err {
+	case usecase.ErrWorkspaceNotFound:
+		Error(w, http.StatusNotFound, "Workspace not found")
+	case usecase.ErrMemberNotFound:
+		Error(w, http.StatusNotFound, "Member not found")
+	case usecase.ErrUserNotFound:
+		Error(w, http.StatusNotFound, "No user with this email")
+	case usecase.ErrMemberExists:
+		Error(w, http.StatusConflict, "User is already a member")
+	case usecase.ErrLastOwner:
+		Error(w, http.StatusConflict, "Workspace must keep at least one owner")
+	case usecase.ErrUnauthorized:
+		Error(w, http.StatusForbidden, "Your role does not allow this")
+	default:
+		Error(w, http.StatusInternalServerError, fallback)
+	}
+}
@@ -0,0 +1,148 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.APIKeyRepository = (*APIKeyRepository)(nil)
+
+type APIKeyRepository struct {
+	mu   sync.RWMutex
+	keys map[string]*entity.APIKey // by ID
+}
+
+func NewAPIKeyRepository() *APIKeyRepository {
+	return &APIKeyRepository{keys: make(map[string]*entity.APIKey)}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.create(key)
+}
+
+func (r *APIKeyRepository) create(key *entity.APIKey) error {
+	for _, other := range r.keys {
+		if other.KeyHash == key.KeyHash {
+			return ErrDuplicate
+		}
+	}
+
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	key.CreatedAt = time.Now()
+	key.IsActive = true
+
+	r.keys[key.ID] = copyAPIKey(key)
+	return nil
+}
+
+func (r *APIKeyRepository) GetByKeyHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.keys {
+		if key.KeyHash == keyHash {
+			return copyAPIKey(key), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*entity.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyAPIKey(key), nil
+}
+
+func (r *APIKeyRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.APIKey, error) {
+	return r.list(func(key *entity.APIKey) bool { return key.UserID == userID }), nil
+}
+
+func (r *APIKeyRepository) GetByWorkspaceID(ctx context.Context, workspaceID string) ([]*entity.APIKey, error) {
+	return r.list(func(key *entity.APIKey) bool { return key.WorkspaceID == workspaceID }), nil
+}
+
+func (r *APIKeyRepository) list(match func(*entity.APIKey) bool) []*entity.APIKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var keys []*entity.APIKey
+	for _, key := range r.keys {
+		if match(key) {
+			keys = append(keys, copyAPIKey(key))
+		}
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].CreatedAt.After(keys[j].CreatedAt) })
+	return keys
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string) error {
+	return r.update(id, func(key *entity.APIKey) {
+		now := time.Now()
+		key.LastUsed = &now
+	})
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.keys, id)
+	return nil
+}
+
+func (r *APIKeyRepository) Deactivate(ctx context.Context, id string) error {
+	return r.update(id, func(key *entity.APIKey) {
+		key.IsActive = false
+	})
+}
+
+func (r *APIKeyRepository) Rotate(ctx context.Context, id string, newKey *entity.APIKey, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, ok := r.keys[id]
+	if !ok || old.ReplacedBy != "" {
+		return repository.ErrAPIKeyReplaced
+	}
+	if err := r.create(newKey); err != nil {
+		return err
+	}
+
+	old.ReplacedBy = newKey.ID
+	old.ExpiresAt = &expiresAt
+	return nil
+}
+
+func (r *APIKeyRepository) update(id string, fn func(key *entity.APIKey)) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, ok := r.keys[id]; ok {
+		fn(key)
+	}
+	return nil
+}
+
+func copyAPIKey(key *entity.APIKey) *entity.APIKey {
+	c := *key
+	c.Scopes = slices.Clone(key.Scopes)
+	return &c
+}
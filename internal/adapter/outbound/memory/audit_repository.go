@@ -0,0 +1,69 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+var _ repository.AuditRepository = (*AuditRepository)(nil)
+
+type AuditRepository struct {
+	mu     sync.RWMutex
+	events []*entity.AuditEvent // in ID order
+}
+
+func NewAuditRepository() *AuditRepository {
+	return &AuditRepository{}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, event *entity.AuditEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = int64(len(r.events)) + 1
+	event.CreatedAt = time.Now()
+
+	r.events = append(r.events, copyAuditEvent(event))
+	return nil
+}
+
+func (r *AuditRepository) List(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []*entity.AuditEvent
+	for i := len(r.events) - 1; i >= 0 && len(events) < filter.Limit; i-- {
+		if event := r.events[i]; matchesAuditFilter(event, filter) {
+			events = append(events, copyAuditEvent(event))
+		}
+	}
+
+	return events, nil
+}
+
+func matchesAuditFilter(event *entity.AuditEvent, filter entity.AuditFilter) bool {
+	switch {
+	case filter.ActorUserID != "" && event.ActorUserID != filter.ActorUserID,
+		filter.ActorAPIKeyID != "" && event.ActorAPIKeyID != filter.ActorAPIKeyID,
+		filter.Action != "" && event.Action != filter.Action,
+		filter.TargetType != "" && event.TargetType != filter.TargetType,
+		filter.TargetID != "" && event.TargetID != filter.TargetID,
+		filter.WorkspaceID != "" && event.WorkspaceID != filter.WorkspaceID,
+		filter.From != nil && event.CreatedAt.Before(*filter.From),
+		filter.To != nil && event.CreatedAt.After(*filter.To),
+		filter.Cursor > 0 && event.ID >= filter.Cursor:
+		return false
+	}
+	return true
+}
+
+func copyAuditEvent(event *entity.AuditEvent) *entity.AuditEvent {
+	c := *event
+	c.Changes = slices.Clone(event.Changes)
+	return &c
+}
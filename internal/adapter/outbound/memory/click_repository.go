@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.ClickRepository = (*ClickRepository)(nil)
+
+type ClickRepository struct {
+	mu     sync.RWMutex
+	clicks []*entity.Click // in insertion order
+}
+
+func NewClickRepository() *ClickRepository {
+	return &ClickRepository{}
+}
+
+func (r *ClickRepository) Create(ctx context.Context, click *entity.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if click.ID == "" {
+		click.ID = uuid.New().String()
+	}
+	click.CreatedAt = time.Now()
+
+	stored := *click
+	r.clicks = append(r.clicks, &stored)
+	return nil
+}
+
+func (r *ClickRepository) CreateBatch(ctx context.Context, clicks []*entity.Click) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, click := range clicks {
+		if click.ID == "" {
+			click.ID = uuid.New().String()
+		}
+		if click.CreatedAt.IsZero() {
+			click.CreatedAt = time.Now()
+		}
+
+		stored := *click
+		r.clicks = append(r.clicks, &stored)
+	}
+	return nil
+}
+
+func (r *ClickRepository) GetByURLID(ctx context.Context, urlID string, limit, offset int) ([]*entity.Click, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Newest first
+	var clicks []*entity.Click
+	for i := len(r.clicks) - 1; i >= 0; i-- {
+		if r.clicks[i].URLID == urlID {
+			stored := *r.clicks[i]
+			clicks = append(clicks, &stored)
+		}
+	}
+
+	return page(clicks, limit, offset), nil
+}
+
+func (r *ClickRepository) GetStatsByURLID(ctx context.Context, urlID string, from, to time.Time) (*entity.ClickStats, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stats := &entity.ClickStats{
+		ClicksByDate: make(map[string]int64),
+	}
+
+	ips := make(map[string]bool)
+	referrers := make(map[string]int64)
+	countries := make(map[string]int64)
+	browsers := make(map[string]int64)
+	devices := make(map[string]int64)
+
+	for _, click := range r.clicks {
+		if click.URLID != urlID || click.CreatedAt.Before(from) || click.CreatedAt.After(to) {
+			continue
+		}
+
+		stats.TotalClicks++
+		ips[click.IPAddress] = true
+		stats.ClicksByDate[click.CreatedAt.Format("2006-01-02")]++
+		referrers[orDefault(click.Referrer, "Direct")]++
+		countries[orDefault(click.Country, "Unknown")]++
+		browsers[orDefault(click.Browser, "Unknown")]++
+		devices[orDefault(click.Device, "Unknown")]++
+	}
+	stats.UniqueClicks = int64(len(ips))
+
+	for _, c := range topCounts(referrers, 5) {
+		stats.TopReferrers = append(stats.TopReferrers, entity.ReferrerStat{Referrer: c.value, Count: c.count})
+	}
+	for _, c := range topCounts(countries, 5) {
+		stats.TopCountries = append(stats.TopCountries, entity.CountryStat{Country: c.value, Count: c.count})
+	}
+	for _, c := range topCounts(browsers, 5) {
+		stats.TopBrowsers = append(stats.TopBrowsers, entity.BrowserStat{Browser: c.value, Count: c.count})
+	}
+	for _, c := range topCounts(devices, 5) {
+		stats.TopDevices = append(stats.TopDevices, entity.DeviceStat{Device: c.value, Count: c.count})
+	}
+
+	return stats, nil
+}
+
+func (r *ClickRepository) CountByURLID(ctx context.Context, urlID string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var count int64
+	for _, click := range r.clicks {
+		if click.URLID == urlID {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *ClickRepository) CountUniqueByURLID(ctx context.Context, urlID string) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ips := make(map[string]bool)
+	for _, click := range r.clicks {
+		if click.URLID == urlID {
+			ips[click.IPAddress] = true
+		}
+	}
+	return int64(len(ips)), nil
+}
+
+type valueCount struct {
+	value string
+	count int64
+}
+
+// topCounts returns the limit most frequent values, most frequent first.
+func topCounts(counts map[string]int64, limit int) []valueCount {
+	sorted := make([]valueCount, 0, len(counts))
+	for value, count := range counts {
+		sorted = append(sorted, valueCount{value, count})
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].value < sorted[j].value
+	})
+
+	return page(sorted, limit, 0)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
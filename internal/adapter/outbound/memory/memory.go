@@ -0,0 +1,31 @@
+// Package memory implements the repositories in process memory. It backs
+// tests and can stand in for a database in development; nothing survives a
+// restart.
+//
+// All repositories are safe for concurrent use. They store copies of the
+// entities they are given and hand out copies, so callers cannot change
+// stored data without going through the repository, just as with a database.
+package memory
+
+import "errors"
+
+var (
+	// ErrDuplicate is returned when a write would break a uniqueness rule
+	// that the SQL adapters enforce with a constraint.
+	ErrDuplicate = errors.New("memory: duplicate key")
+	// ErrNotFound is returned when a write refers to a missing row, where
+	// the SQL adapters would fail a foreign key.
+	ErrNotFound = errors.New("memory: referenced row not found")
+)
+
+// page applies limit and offset to items the way LIMIT and OFFSET would.
+func page[T any](items []T, limit, offset int) []T {
+	if offset >= len(items) {
+		return nil
+	}
+	items = items[offset:]
+	if limit >= 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/internal/domain/repository/repositorytest"
+)
+
+func TestContract(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		users := NewUserRepository()
+		urls := NewURLRepository()
+		return repositorytest.Repositories{
+			URLs:       urls,
+			Clicks:     NewClickRepository(),
+			APIKeys:    NewAPIKeyRepository(),
+			Users:      users,
+			Revisions:  NewURLRevisionRepository(urls),
+			Workspaces: NewWorkspaceRepository(users),
+			Audit:      NewAuditRepository(),
+		}
+	})
+}
+
+func TestURLCacheContract(t *testing.T) {
+	repositorytest.RunURLCache(t, func(t *testing.T) (repository.URLCacheRepository, repositorytest.Advance) {
+		return NewURLCacheRepository(time.Hour), time.Sleep
+	})
+}
+
+func TestPasswordAttemptContract(t *testing.T) {
+	repositorytest.RunPasswordAttempts(t, func(t *testing.T) repository.PasswordAttemptRepository {
+		return NewPasswordAttemptRepository()
+	})
+}
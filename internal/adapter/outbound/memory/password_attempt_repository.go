@@ -0,0 +1,114 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+var _ repository.PasswordAttemptRepository = (*PasswordAttemptRepository)(nil)
+
+// sweepInterval is how often expired attempts and lockouts are dropped, so
+// keys that are never seen again do not pile up.
+const sweepInterval = time.Minute
+
+type attemptCount struct {
+	count     int64
+	expiresAt time.Time
+}
+
+// PasswordAttemptRepository counts password attempts in process. It suits a
+// single instance; replicas must share the Redis one.
+type PasswordAttemptRepository struct {
+	mu        sync.Mutex
+	attempts  map[string]attemptCount
+	lockouts  map[string]time.Time // key -> end of the lockout
+	nextSweep time.Time
+}
+
+func NewPasswordAttemptRepository() *PasswordAttemptRepository {
+	return &PasswordAttemptRepository{
+		attempts: make(map[string]attemptCount),
+		lockouts: make(map[string]time.Time),
+	}
+}
+
+func (r *PasswordAttemptRepository) Reserve(ctx context.Context, key string, policy repository.AttemptPolicy) (repository.AttemptReservation, error) {
+	return r.reserve(key, policy, false)
+}
+
+func (r *PasswordAttemptRepository) Count(ctx context.Context, key string, policy repository.AttemptPolicy) (repository.AttemptReservation, error) {
+	return r.reserve(key, policy, true)
+}
+
+func (r *PasswordAttemptRepository) reserve(key string, policy repository.AttemptPolicy, force bool) (repository.AttemptReservation, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	r.sweep(now)
+
+	if until, ok := r.lockouts[key]; ok && now.Before(until) && !force {
+		return repository.AttemptReservation{LockedFor: until.Sub(now)}, nil
+	}
+
+	attempts, ok := r.attempts[key]
+	if !ok || !now.Before(attempts.expiresAt) {
+		attempts = attemptCount{expiresAt: now.Add(policy.Window)}
+	}
+	attempts.count++
+	r.attempts[key] = attempts
+
+	reservation := repository.AttemptReservation{Allowed: true, Attempts: attempts.count}
+	if attempts.count > policy.Free {
+		reservation.LockedFor = policy.Lockout(attempts.count - policy.Free)
+		r.lockouts[key] = now.Add(reservation.LockedFor)
+	}
+	return reservation, nil
+}
+
+func (r *PasswordAttemptRepository) Release(ctx context.Context, key string, policy repository.AttemptPolicy) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.attempts[key]
+	if ok && attempts.count > 0 {
+		attempts.count--
+		r.attempts[key] = attempts
+	}
+	if attempts.count <= policy.Free {
+		delete(r.lockouts, key)
+	}
+	return nil
+}
+
+func (r *PasswordAttemptRepository) Reset(ctx context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	delete(r.lockouts, key)
+	return nil
+}
+
+// sweep drops what has expired, at most once per sweepInterval. The caller
+// must hold r.mu.
+func (r *PasswordAttemptRepository) sweep(now time.Time) {
+	if now.Before(r.nextSweep) {
+		return
+	}
+	r.nextSweep = now.Add(sweepInterval)
+
+	for key, attempts := range r.attempts {
+		if !now.Before(attempts.expiresAt) {
+			delete(r.attempts, key)
+		}
+	}
+	for key, until := range r.lockouts {
+		if !now.Before(until) {
+			delete(r.lockouts, key)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+var _ repository.URLCacheRepository = (*URLCacheRepository)(nil)
+
+const defaultTTL = 1 * time.Hour
+
+type cachedURL struct {
+	url       *entity.URL // nil when cached as not found
+	expiresAt time.Time
+}
+
+type clickBatch struct {
+	counts  map[string]int64
+	takenAt time.Time
+}
+
+type URLCacheRepository struct {
+	mu     sync.Mutex
+	urls   map[string]cachedURL // by short code
+	clicks map[string]int64     // pending, by URL ID
+	ttl    time.Duration
+
+	batches   map[string]clickBatch // taken clicks, by batch ID
+	lastBatch int
+}
+
+func NewURLCacheRepository(ttl time.Duration) *URLCacheRepository {
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+	return &URLCacheRepository{
+		urls:   make(map[string]cachedURL),
+		clicks: make(map[string]int64),
+		ttl:    ttl,
+
+		batches: make(map[string]clickBatch),
+	}
+}
+
+func (r *URLCacheRepository) Get(ctx context.Context, shortCode string) (*entity.URL, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cached, ok := r.urls[shortCode]
+	if !ok {
+		return nil, nil
+	}
+	if time.Now().After(cached.expiresAt) {
+		delete(r.urls, shortCode)
+		return nil, nil
+	}
+	if cached.url == nil {
+		return nil, repository.ErrCachedNotFound
+	}
+
+	url := *cached.url
+	return &url, nil
+}
+
+func (r *URLCacheRepository) Set(ctx context.Context, url *entity.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ttl := r.ttl
+	// If URL has expiry, use that instead
+	if url.ExpiresAt != nil {
+		remaining := time.Until(*url.ExpiresAt)
+		if remaining > 0 && remaining < ttl {
+			ttl = remaining
+		}
+	}
+
+	cached := *url
+	r.urls[url.ShortCode] = cachedURL{url: &cached, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (r *URLCacheRepository) SetNotFound(ctx context.Context, shortCode string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// A link cached meanwhile, e.g. by its creation, wins
+	if cached, ok := r.urls[shortCode]; ok && time.Now().Before(cached.expiresAt) {
+		return nil
+	}
+	r.urls[shortCode] = cachedURL{expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (r *URLCacheRepository) Delete(ctx context.Context, shortCode string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.urls, shortCode)
+	return nil
+}
+
+func (r *URLCacheRepository) AddPendingClicks(ctx context.Context, counts map[string]int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, delta := range counts {
+		r.clicks[id] += delta
+	}
+	return nil
+}
+
+func (r *URLCacheRepository) GetPendingClicks(ctx context.Context, urlIDs []string) (map[string]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	counts := make(map[string]int64, len(urlIDs))
+	for _, id := range urlIDs {
+		if count, ok := r.clicks[id]; ok {
+			counts[id] += count
+		}
+		for _, batch := range r.batches {
+			if count, ok := batch.counts[id]; ok {
+				counts[id] += count
+			}
+		}
+	}
+	return counts, nil
+}
+
+func (r *URLCacheRepository) TakePendingClicks(ctx context.Context) (*repository.PendingClickBatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.clicks) == 0 {
+		return nil, nil
+	}
+
+	r.lastBatch++
+	id := strconv.Itoa(r.lastBatch)
+	r.batches[id] = clickBatch{counts: r.clicks, takenAt: time.Now()}
+	r.clicks = make(map[string]int64)
+
+	return &repository.PendingClickBatch{ID: id, Counts: maps.Clone(r.batches[id].counts)}, nil
+}
+
+func (r *URLCacheRepository) DonePendingClicks(ctx context.Context, batchID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.batches, batchID)
+	return nil
+}
+
+func (r *URLCacheRepository) ReturnPendingClicks(ctx context.Context, batchID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, count := range r.batches[batchID].counts {
+		r.clicks[id] += count
+	}
+	delete(r.batches, batchID)
+	return nil
+}
+
+func (r *URLCacheRepository) ClaimPendingClicks(ctx context.Context, olderThan time.Duration) ([]*repository.PendingClickBatch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	var claimed []*repository.PendingClickBatch
+	for id, batch := range r.batches {
+		if now.Sub(batch.takenAt) <= olderThan {
+			continue
+		}
+		batch.takenAt = now
+		r.batches[id] = batch
+		claimed = append(claimed, &repository.PendingClickBatch{ID: id, Counts: maps.Clone(batch.counts)})
+	}
+	return claimed, nil
+}
@@ -0,0 +1,182 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.URLRepository = (*URLRepository)(nil)
+
+type URLRepository struct {
+	mu   sync.RWMutex
+	urls map[string]*entity.URL // by ID
+}
+
+func NewURLRepository() *URLRepository {
+	return &URLRepository{urls: make(map[string]*entity.URL)}
+}
+
+func (r *URLRepository) Create(ctx context.Context, url *entity.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conflicts(url, "") {
+		return ErrDuplicate
+	}
+
+	if url.ID == "" {
+		url.ID = uuid.New().String()
+	}
+	url.CreatedAt = time.Now()
+	url.UpdatedAt = time.Now()
+	url.IsActive = true
+
+	stored := *url
+	stored.TargetingRules = slices.Clone(url.TargetingRules)
+	r.urls[url.ID] = &stored
+	return nil
+}
+
+func (r *URLRepository) GetByShortCode(ctx context.Context, shortCode string) (*entity.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, url := range r.urls {
+		if url.ShortCode == shortCode || url.CustomAlias == shortCode {
+			stored := *url
+			return &stored, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *URLRepository) GetByID(ctx context.Context, id string) (*entity.URL, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	url, ok := r.urls[id]
+	if !ok {
+		return nil, nil
+	}
+	stored := *url
+	return &stored, nil
+}
+
+func (r *URLRepository) GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*entity.URL, error) {
+	return r.list(func(url *entity.URL) bool { return url.UserID == userID }, newestFirst, limit, offset), nil
+}
+
+func (r *URLRepository) GetByWorkspaceID(ctx context.Context, workspaceID string, limit, offset int) ([]*entity.URL, error) {
+	return r.list(func(url *entity.URL) bool { return url.WorkspaceID == workspaceID }, newestFirst, limit, offset), nil
+}
+
+func (r *URLRepository) List(ctx context.Context, limit, offset int) ([]*entity.URL, error) {
+	return r.list(func(*entity.URL) bool { return true }, newestFirst, limit, offset), nil
+}
+
+// GetMostClicked returns the active links with the most clicks.
+func (r *URLRepository) GetMostClicked(ctx context.Context, limit int) ([]*entity.URL, error) {
+	mostClicked := func(a, b *entity.URL) bool { return a.ClickCount > b.ClickCount }
+	return r.list((*entity.URL).CanRedirect, mostClicked, limit, 0), nil
+}
+
+func newestFirst(a, b *entity.URL) bool {
+	return a.CreatedAt.After(b.CreatedAt)
+}
+
+func (r *URLRepository) list(match func(*entity.URL) bool, less func(a, b *entity.URL) bool, limit, offset int) []*entity.URL {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var urls []*entity.URL
+	for _, url := range r.urls {
+		if match(url) {
+			stored := *url
+			urls = append(urls, &stored)
+		}
+	}
+
+	sort.SliceStable(urls, func(i, j int) bool { return less(urls[i], urls[j]) })
+	return page(urls, limit, offset)
+}
+
+func (r *URLRepository) Update(ctx context.Context, url *entity.URL) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.urls[url.ID]
+	if !ok {
+		return nil
+	}
+	if r.conflicts(url, url.ID) {
+		return ErrDuplicate
+	}
+
+	url.UpdatedAt = time.Now()
+
+	stored.ShortCode = url.ShortCode
+	stored.OriginalURL = url.OriginalURL
+	stored.CustomAlias = url.CustomAlias
+	stored.ExpiresAt = url.ExpiresAt
+	stored.UpdatedAt = url.UpdatedAt
+	stored.IsActive = url.IsActive
+	stored.PasswordHash = url.PasswordHash
+	stored.RedirectType = url.RedirectType
+	stored.TargetingRules = slices.Clone(url.TargetingRules)
+	return nil
+}
+
+func (r *URLRepository) Delete(ctx context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.urls, id)
+	return nil
+}
+
+func (r *URLRepository) AddClickCounts(ctx context.Context, counts map[string]int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, delta := range counts {
+		if url, ok := r.urls[id]; ok {
+			url.ClickCount += delta
+			url.UpdatedAt = time.Now()
+		}
+	}
+	return nil
+}
+
+func (r *URLRepository) ShortCodeExists(ctx context.Context, shortCode string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, url := range r.urls {
+		if url.ShortCode == shortCode || url.CustomAlias == shortCode {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// conflicts reports whether a link other than exceptID has the same short
+// code or custom alias as url, mirroring the unique columns of the SQL
+// schema. The caller must hold the lock.
+func (r *URLRepository) conflicts(url *entity.URL, exceptID string) bool {
+	for id, other := range r.urls {
+		if id == exceptID {
+			continue
+		}
+		if other.ShortCode == url.ShortCode || (url.CustomAlias != "" && other.CustomAlias == url.CustomAlias) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.URLRevisionRepository = (*URLRevisionRepository)(nil)
+
+type URLRevisionRepository struct {
+	mu        sync.RWMutex
+	revisions map[string][]*entity.URLRevision // by URL ID, oldest first
+
+	urls   *URLRepository
+	editMu sync.Mutex // held by Edit, so edits of links run one at a time
+}
+
+// NewURLRevisionRepository returns an empty repository for the revisions of
+// the links in urls, which Edit changes.
+func NewURLRevisionRepository(urls *URLRepository) *URLRevisionRepository {
+	return &URLRevisionRepository{
+		revisions: make(map[string][]*entity.URLRevision),
+		urls:      urls,
+	}
+}
+
+func (r *URLRevisionRepository) Create(ctx context.Context, rev *entity.URLRevision) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if rev.ID == "" {
+		rev.ID = uuid.New().String()
+	}
+	rev.CreatedAt = time.Now()
+
+	// Revision numbers are sequential per URL
+	rev.Revision = len(r.revisions[rev.URLID]) + 1
+
+	stored := *rev
+	r.revisions[rev.URLID] = append(r.revisions[rev.URLID], &stored)
+	return nil
+}
+
+func (r *URLRevisionRepository) Edit(ctx context.Context, urlID, action, changedBy string, edit func(url *entity.URL) error) (*entity.URL, error) {
+	r.editMu.Lock()
+	defer r.editMu.Unlock()
+
+	url, err := r.urls.GetByID(ctx, urlID)
+	if err != nil || url == nil {
+		return nil, err
+	}
+	if err := edit(url); err != nil {
+		return nil, err
+	}
+
+	// Update fails only before it changes anything and Create cannot fail,
+	// so the link is never saved without its revision
+	if err := r.urls.Update(ctx, url); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, entity.NewURLRevision(url, action, changedBy)); err != nil {
+		return nil, err
+	}
+	return url, nil
+}
+
+func (r *URLRevisionRepository) GetByURLID(ctx context.Context, urlID string) ([]*entity.URLRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Newest first
+	stored := r.revisions[urlID]
+	revisions := make([]*entity.URLRevision, 0, len(stored))
+	for i := len(stored) - 1; i >= 0; i-- {
+		rev := *stored[i]
+		revisions = append(revisions, &rev)
+	}
+
+	if len(revisions) == 0 {
+		return nil, nil
+	}
+	return revisions, nil
+}
+
+func (r *URLRevisionRepository) GetByRevision(ctx context.Context, urlID string, revision int) (*entity.URLRevision, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	stored := r.revisions[urlID]
+	if revision < 1 || revision > len(stored) {
+		return nil, nil
+	}
+	rev := *stored[revision-1]
+	return &rev, nil
+}
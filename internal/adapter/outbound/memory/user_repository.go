@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+type UserRepository struct {
+	mu    sync.RWMutex
+	users map[string]*entity.User // by ID
+}
+
+func NewUserRepository() *UserRepository {
+	return &UserRepository{users: make(map[string]*entity.User)}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.byEmail(user.Email) != nil {
+		return repository.ErrEmailTaken
+	}
+
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+
+	stored := *user
+	r.users[user.ID] = &stored
+	return nil
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user, ok := r.users[id]
+	if !ok {
+		return nil, nil
+	}
+	stored := *user
+	return &stored, nil
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	user := r.byEmail(email)
+	if user == nil {
+		return nil, nil
+	}
+	stored := *user
+	return &stored, nil
+}
+
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.byEmail(email) != nil, nil
+}
+
+// email returns the address of a user, or "" if there is none.
+func (r *UserRepository) email(id string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if user, ok := r.users[id]; ok {
+		return user.Email
+	}
+	return ""
+}
+
+// byEmail finds a user by email. The caller must hold the lock.
+func (r *UserRepository) byEmail(email string) *entity.User {
+	for _, user := range r.users {
+		if user.Email == email {
+			return user
+		}
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.WorkspaceRepository = (*WorkspaceRepository)(nil)
+
+type WorkspaceRepository struct {
+	mu         sync.RWMutex
+	workspaces map[string]*entity.Workspace
+	members    map[string]map[string]*entity.WorkspaceMember // by workspace, then user
+	users      *UserRepository
+}
+
+// NewWorkspaceRepository returns an empty repository. Members are given the
+// email of their account in users, which may be nil.
+func NewWorkspaceRepository(users *UserRepository) *WorkspaceRepository {
+	return &WorkspaceRepository{
+		workspaces: make(map[string]*entity.Workspace),
+		members:    make(map[string]map[string]*entity.WorkspaceMember),
+		users:      users,
+	}
+}
+
+func (r *WorkspaceRepository) Create(ctx context.Context, workspace *entity.Workspace) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if workspace.ID == "" {
+		workspace.ID = uuid.New().String()
+	}
+	workspace.CreatedAt = time.Now()
+	workspace.UpdatedAt = time.Now()
+
+	stored := *workspace
+	r.workspaces[workspace.ID] = &stored
+	r.members[workspace.ID] = map[string]*entity.WorkspaceMember{
+		workspace.CreatedBy: {
+			WorkspaceID: workspace.ID,
+			UserID:      workspace.CreatedBy,
+			Role:        entity.RoleOwner,
+			CreatedAt:   workspace.CreatedAt,
+		},
+	}
+	return nil
+}
+
+func (r *WorkspaceRepository) GetByID(ctx context.Context, id string) (*entity.Workspace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	workspace, ok := r.workspaces[id]
+	if !ok {
+		return nil, nil
+	}
+	stored := *workspace
+	return &stored, nil
+}
+
+func (r *WorkspaceRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.Workspace, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var workspaces []*entity.Workspace
+	for id, members := range r.members {
+		if _, ok := members[userID]; ok {
+			stored := *r.workspaces[id]
+			workspaces = append(workspaces, &stored)
+		}
+	}
+
+	sort.SliceStable(workspaces, func(i, j int) bool { return workspaces[i].CreatedAt.After(workspaces[j].CreatedAt) })
+	return workspaces, nil
+}
+
+func (r *WorkspaceRepository) GetMember(ctx context.Context, workspaceID, userID string) (*entity.WorkspaceMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	member, ok := r.members[workspaceID][userID]
+	if !ok {
+		return nil, nil
+	}
+	return r.withEmail(member), nil
+}
+
+func (r *WorkspaceRepository) GetMembers(ctx context.Context, workspaceID string) ([]*entity.WorkspaceMember, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var members []*entity.WorkspaceMember
+	for _, member := range r.members[workspaceID] {
+		members = append(members, r.withEmail(member))
+	}
+
+	sort.SliceStable(members, func(i, j int) bool { return members[i].CreatedAt.Before(members[j].CreatedAt) })
+	return members, nil
+}
+
+func (r *WorkspaceRepository) AddMember(ctx context.Context, member *entity.WorkspaceMember) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// The SQL adapters reject unknown workspaces with a foreign key
+	members, ok := r.members[member.WorkspaceID]
+	if !ok {
+		return ErrNotFound
+	}
+	if _, exists := members[member.UserID]; exists {
+		return ErrDuplicate
+	}
+
+	member.CreatedAt = time.Now()
+
+	stored := *member
+	members[member.UserID] = &stored
+	return nil
+}
+
+func (r *WorkspaceRepository) UpdateMemberRole(ctx context.Context, workspaceID, userID string, role entity.WorkspaceRole) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if member, ok := r.members[workspaceID][userID]; ok {
+		member.Role = role
+	}
+	return nil
+}
+
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.members[workspaceID], userID)
+	return nil
+}
+
+func (r *WorkspaceRepository) CountOwners(ctx context.Context, workspaceID string) (int, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	count := 0
+	for _, member := range r.members[workspaceID] {
+		if member.Role == entity.RoleOwner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// withEmail returns a copy of member with the email of its user filled in.
+// The caller must hold the lock.
+func (r *WorkspaceRepository) withEmail(member *entity.WorkspaceMember) *entity.WorkspaceMember {
+	stored := *member
+	stored.Email = ""
+	if r.users != nil {
+		stored.Email = r.users.email(member.UserID)
+	}
+	return &stored
+}
@@ -14,6 +14,8 @@ import (
 
 var _ repository.APIKeyRepository = (*APIKeyRepository)(nil)
 
+const apiKeyColumns = `id, key_hash, key_prefix, name, user_id, workspace_id, scopes, rate_limit, expires_at, created_at, last_used, is_active, replaced_by`
+
 type APIKeyRepository struct {
 	db *sql.DB
 }
@@ -23,6 +25,10 @@ func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
 }
 
 func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	return insertAPIKey(ctx, r.db, key)
+}
+
+func insertAPIKey(ctx context.Context, db execer, key *entity.APIKey) error {
 	if key.ID == "" {
 		key.ID = uuid.New().String()
 	}
@@ -35,15 +41,17 @@ func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error
 	}
 
 	query := `
-		INSERT INTO api_keys (id, key, name, user_id, scopes, rate_limit, expires_at, created_at, is_active)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		INSERT INTO api_keys (id, key_hash, key_prefix, name, user_id, workspace_id, scopes, rate_limit, expires_at, created_at, is_active)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 	`
 
-	_, err = r.db.ExecContext(ctx, query,
+	_, err = db.ExecContext(ctx, query,
 		key.ID,
-		key.Key,
+		key.KeyHash,
+		key.KeyPrefix,
 		key.Name,
 		key.UserID,
+		nullString(key.WorkspaceID),
 		scopesJSON,
 		key.RateLimit,
 		nullTime(key.ExpiresAt),
@@ -54,30 +62,10 @@ func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error
 	return err
 }
 
-func (r *APIKeyRepository) GetByKey(ctx context.Context, key string) (*entity.APIKey, error) {
-	query := `
-		SELECT id, key, name, user_id, scopes, rate_limit, expires_at, created_at, last_used, is_active
-		FROM api_keys
-		WHERE key = $1
-	`
-
-	apiKey := &entity.APIKey{}
-	var scopesJSON []byte
-	var expiresAt, lastUsed sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, key).Scan(
-		&apiKey.ID,
-		&apiKey.Key,
-		&apiKey.Name,
-		&apiKey.UserID,
-		&scopesJSON,
-		&apiKey.RateLimit,
-		&expiresAt,
-		&apiKey.CreatedAt,
-		&lastUsed,
-		&apiKey.IsActive,
-	)
+func (r *APIKeyRepository) GetByKeyHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
 
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -85,44 +73,13 @@ func (r *APIKeyRepository) GetByKey(ctx context.Context, key string) (*entity.AP
 		return nil, err
 	}
 
-	if err := json.Unmarshal(scopesJSON, &apiKey.Scopes); err != nil {
-		return nil, err
-	}
-
-	if expiresAt.Valid {
-		apiKey.ExpiresAt = &expiresAt.Time
-	}
-	if lastUsed.Valid {
-		apiKey.LastUsed = &lastUsed.Time
-	}
-
 	return apiKey, nil
 }
 
 func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*entity.APIKey, error) {
-	query := `
-		SELECT id, key, name, user_id, scopes, rate_limit, expires_at, created_at, last_used, is_active
-		FROM api_keys
-		WHERE id = $1
-	`
-
-	apiKey := &entity.APIKey{}
-	var scopesJSON []byte
-	var expiresAt, lastUsed sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&apiKey.ID,
-		&apiKey.Key,
-		&apiKey.Name,
-		&apiKey.UserID,
-		&scopesJSON,
-		&apiKey.RateLimit,
-		&expiresAt,
-		&apiKey.CreatedAt,
-		&lastUsed,
-		&apiKey.IsActive,
-	)
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
 
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -130,29 +87,21 @@ func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*entity.APIK
 		return nil, err
 	}
 
-	if err := json.Unmarshal(scopesJSON, &apiKey.Scopes); err != nil {
-		return nil, err
-	}
-
-	if expiresAt.Valid {
-		apiKey.ExpiresAt = &expiresAt.Time
-	}
-	if lastUsed.Valid {
-		apiKey.LastUsed = &lastUsed.Time
-	}
-
 	return apiKey, nil
 }
 
 func (r *APIKeyRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.APIKey, error) {
-	query := `
-		SELECT id, key, name, user_id, scopes, rate_limit, expires_at, created_at, last_used, is_active
-		FROM api_keys
-		WHERE user_id = $1
-		ORDER BY created_at DESC
-	`
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, userID)
+}
 
-	rows, err := r.db.QueryContext(ctx, query, userID)
+func (r *APIKeyRepository) GetByWorkspaceID(ctx context.Context, workspaceID string) ([]*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE workspace_id = $1 ORDER BY created_at DESC`
+	return r.list(ctx, query, workspaceID)
+}
+
+func (r *APIKeyRepository) list(ctx context.Context, query string, args ...any) ([]*entity.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -160,37 +109,10 @@ func (r *APIKeyRepository) GetByUserID(ctx context.Context, userID string) ([]*e
 
 	var keys []*entity.APIKey
 	for rows.Next() {
-		apiKey := &entity.APIKey{}
-		var scopesJSON []byte
-		var expiresAt, lastUsed sql.NullTime
-
-		err := rows.Scan(
-			&apiKey.ID,
-			&apiKey.Key,
-			&apiKey.Name,
-			&apiKey.UserID,
-			&scopesJSON,
-			&apiKey.RateLimit,
-			&expiresAt,
-			&apiKey.CreatedAt,
-			&lastUsed,
-			&apiKey.IsActive,
-		)
+		apiKey, err := scanAPIKey(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		if err := json.Unmarshal(scopesJSON, &apiKey.Scopes); err != nil {
-			return nil, err
-		}
-
-		if expiresAt.Valid {
-			apiKey.ExpiresAt = &expiresAt.Time
-		}
-		if lastUsed.Valid {
-			apiKey.LastUsed = &lastUsed.Time
-		}
-
 		keys = append(keys, apiKey)
 	}
 
@@ -214,3 +136,79 @@ func (r *APIKeyRepository) Deactivate(ctx context.Context, id string) error {
 	_, err := r.db.ExecContext(ctx, query, id)
 	return err
 }
+
+// Rotate inserts the new key first, then replaces the old one only if no
+// other rotation got to it, under the lock on its row.
+func (r *APIKeyRepository) Rotate(ctx context.Context, id string, newKey *entity.APIKey, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertAPIKey(ctx, tx, newKey); err != nil {
+		return err
+	}
+
+	query := `UPDATE api_keys SET replaced_by = $2, expires_at = $3 WHERE id = $1 AND replaced_by IS NULL`
+	result, err := tx.ExecContext(ctx, query, id, newKey.ID, expiresAt)
+	if err != nil {
+		return err
+	}
+	replaced, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if replaced == 0 {
+		return repository.ErrAPIKeyReplaced
+	}
+
+	return tx.Commit()
+}
+
+// execer runs statements on the database or in a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func scanAPIKey(row rowScanner) (*entity.APIKey, error) {
+	apiKey := &entity.APIKey{}
+	var scopesJSON []byte
+	var expiresAt, lastUsed sql.NullTime
+	var workspaceID, replacedBy sql.NullString
+
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.KeyHash,
+		&apiKey.KeyPrefix,
+		&apiKey.Name,
+		&apiKey.UserID,
+		&workspaceID,
+		&scopesJSON,
+		&apiKey.RateLimit,
+		&expiresAt,
+		&apiKey.CreatedAt,
+		&lastUsed,
+		&apiKey.IsActive,
+		&replacedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.WorkspaceID = workspaceID.String
+	apiKey.ReplacedBy = replacedBy.String
+
+	if err := json.Unmarshal(scopesJSON, &apiKey.Scopes); err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		apiKey.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsed.Valid {
+		apiKey.LastUsed = &lastUsed.Time
+	}
+
+	return apiKey, nil
+}
@@ -0,0 +1,136 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+var _ repository.AuditRepository = (*AuditRepository)(nil)
+
+type AuditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+func (r *AuditRepository) Create(ctx context.Context, event *entity.AuditEvent) error {
+	event.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO audit_events (actor_user_id, actor_api_key_id, action, target_type, target_id, workspace_id, ip_address, changes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id
+	`
+
+	var changes []byte
+	if len(event.Changes) > 0 {
+		changes = event.Changes
+	}
+
+	return r.db.QueryRowContext(ctx, query,
+		nullString(event.ActorUserID),
+		nullString(event.ActorAPIKeyID),
+		event.Action,
+		event.TargetType,
+		event.TargetID,
+		nullString(event.WorkspaceID),
+		nullString(event.IPAddress),
+		changes,
+		event.CreatedAt,
+	).Scan(&event.ID)
+}
+
+func (r *AuditRepository) List(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditEvent, error) {
+	var conditions []string
+	var args []any
+
+	where := func(column string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, column+" $"+strconv.Itoa(len(args)))
+	}
+
+	if filter.ActorUserID != "" {
+		where("actor_user_id =", filter.ActorUserID)
+	}
+	if filter.ActorAPIKeyID != "" {
+		where("actor_api_key_id =", filter.ActorAPIKeyID)
+	}
+	if filter.Action != "" {
+		where("action =", filter.Action)
+	}
+	if filter.TargetType != "" {
+		where("target_type =", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		where("target_id =", filter.TargetID)
+	}
+	if filter.WorkspaceID != "" {
+		where("workspace_id =", filter.WorkspaceID)
+	}
+	if filter.From != nil {
+		where("created_at >=", *filter.From)
+	}
+	if filter.To != nil {
+		where("created_at <=", *filter.To)
+	}
+	if filter.Cursor > 0 {
+		where("id <", filter.Cursor)
+	}
+
+	query := `
+		SELECT id, actor_user_id, actor_api_key_id, action, target_type, target_id, workspace_id, ip_address, changes, created_at
+		FROM audit_events
+	`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit)
+	query += " ORDER BY id DESC LIMIT $" + strconv.Itoa(len(args))
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*entity.AuditEvent
+	for rows.Next() {
+		event := &entity.AuditEvent{}
+		var actorUserID, actorAPIKeyID, workspaceID, ipAddress sql.NullString
+		var changes []byte
+
+		err := rows.Scan(
+			&event.ID,
+			&actorUserID,
+			&actorAPIKeyID,
+			&event.Action,
+			&event.TargetType,
+			&event.TargetID,
+			&workspaceID,
+			&ipAddress,
+			&changes,
+			&event.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		event.ActorUserID = actorUserID.String
+		event.ActorAPIKeyID = actorAPIKeyID.String
+		event.WorkspaceID = workspaceID.String
+		event.IPAddress = ipAddress.String
+		event.Changes = changes
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
@@ -3,6 +3,8 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/bimakw/url-shortener/internal/domain/entity"
@@ -27,8 +29,8 @@ func (r *ClickRepository) Create(ctx context.Context, click *entity.Click) error
 	click.CreatedAt = time.Now()
 
 	query := `
-		INSERT INTO clicks (id, url_id, short_code, ip_address, user_agent, referrer, country, city, device, browser, os, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		INSERT INTO clicks (id, url_id, short_code, ip_address, user_agent, referrer, country, city, device, browser, os, created_at, country_code, targeting_rule_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
 	_, err := r.db.ExecContext(ctx, query,
@@ -44,14 +46,79 @@ func (r *ClickRepository) Create(ctx context.Context, click *entity.Click) error
 		click.Browser,
 		click.OS,
 		click.CreatedAt,
+		click.CountryCode,
+		click.TargetingRuleID,
 	)
 
 	return err
 }
 
+// clickBatchRows caps the rows per INSERT so a statement stays well below
+// the driver's limit on bind parameters.
+const clickBatchRows = 1000
+
+// CreateBatch inserts the clicks with multi-row INSERTs in one transaction.
+func (r *ClickRepository) CreateBatch(ctx context.Context, clicks []*entity.Click) error {
+	if len(clicks) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for start := 0; start < len(clicks); start += clickBatchRows {
+		chunk := clicks[start:min(start+clickBatchRows, len(clicks))]
+
+		var query strings.Builder
+		query.WriteString(`INSERT INTO clicks (id, url_id, short_code, ip_address, user_agent, referrer, country, city, device, browser, os, created_at, country_code, targeting_rule_id) VALUES `)
+		args := make([]any, 0, len(chunk)*14)
+
+		for i, click := range chunk {
+			if click.ID == "" {
+				click.ID = uuid.New().String()
+			}
+			if click.CreatedAt.IsZero() {
+				click.CreatedAt = time.Now()
+			}
+
+			if i > 0 {
+				query.WriteString(", ")
+			}
+			n := len(args)
+			fmt.Fprintf(&query, "($%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d, $%d)",
+				n+1, n+2, n+3, n+4, n+5, n+6, n+7, n+8, n+9, n+10, n+11, n+12, n+13, n+14)
+			args = append(args,
+				click.ID,
+				click.URLID,
+				click.ShortCode,
+				click.IPAddress,
+				click.UserAgent,
+				click.Referrer,
+				click.Country,
+				click.City,
+				click.Device,
+				click.Browser,
+				click.OS,
+				click.CreatedAt,
+				click.CountryCode,
+				click.TargetingRuleID,
+			)
+		}
+
+		if _, err := tx.ExecContext(ctx, query.String(), args...); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
 func (r *ClickRepository) GetByURLID(ctx context.Context, urlID string, limit, offset int) ([]*entity.Click, error) {
 	query := `
-		SELECT id, url_id, short_code, ip_address, user_agent, referrer, country, city, device, browser, os, created_at
+		SELECT id, url_id, short_code, ip_address, user_agent, referrer, country, city, device, browser, os, created_at, country_code, targeting_rule_id
 		FROM clicks
 		WHERE url_id = $1
 		ORDER BY created_at DESC
@@ -80,6 +147,8 @@ func (r *ClickRepository) GetByURLID(ctx context.Context, urlID string, limit, o
 			&click.Browser,
 			&click.OS,
 			&click.CreatedAt,
+			&click.CountryCode,
+			&click.TargetingRuleID,
 		)
 		if err != nil {
 			return nil, err
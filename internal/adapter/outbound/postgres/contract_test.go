@@ -0,0 +1,48 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+
+	_ "github.com/lib/pq"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository/repositorytest"
+)
+
+// TestContract runs against the database in TEST_DATABASE_DSN, which it
+// migrates and empties between tests. It is skipped when the variable is
+// unset.
+func TestContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := RunMigrations(context.Background(), db); err != nil {
+		t.Fatalf("RunMigrations() error = %v", err)
+	}
+
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		_, err := db.Exec(`TRUNCATE urls, clicks, api_keys, url_revisions, users, workspaces, workspace_members, audit_events RESTART IDENTITY CASCADE`)
+		if err != nil {
+			t.Fatalf("truncate: %v", err)
+		}
+		return repositorytest.Repositories{
+			URLs:       NewURLRepository(db),
+			Clicks:     NewClickRepository(db),
+			APIKeys:    NewAPIKeyRepository(db),
+			Users:      NewUserRepository(db),
+			Revisions:  NewURLRevisionRepository(db),
+			Workspaces: NewWorkspaceRepository(db),
+			Audit:      NewAuditRepository(db),
+		}
+	})
+}
@@ -3,65 +3,51 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"embed"
+	"io/fs"
+
+	"github.com/bimakw/url-shortener/pkg/migrate"
 )
 
-func RunMigrations(ctx context.Context, db *sql.DB) error {
-	migrations := []string{
-		`CREATE TABLE IF NOT EXISTS urls (
-			id VARCHAR(36) PRIMARY KEY,
-			short_code VARCHAR(20) UNIQUE NOT NULL,
-			original_url TEXT NOT NULL,
-			custom_alias VARCHAR(20) UNIQUE,
-			user_id VARCHAR(36),
-			expires_at TIMESTAMP,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			click_count BIGINT NOT NULL DEFAULT 0,
-			is_active BOOLEAN NOT NULL DEFAULT TRUE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_urls_short_code ON urls(short_code)`,
-		`CREATE INDEX IF NOT EXISTS idx_urls_custom_alias ON urls(custom_alias)`,
-		`CREATE INDEX IF NOT EXISTS idx_urls_user_id ON urls(user_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at)`,
-		`CREATE TABLE IF NOT EXISTS clicks (
-			id VARCHAR(36) PRIMARY KEY,
-			url_id VARCHAR(36) NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
-			short_code VARCHAR(20) NOT NULL,
-			ip_address VARCHAR(45),
-			user_agent TEXT,
-			referrer TEXT,
-			country VARCHAR(100),
-			city VARCHAR(100),
-			device VARCHAR(50),
-			browser VARCHAR(50),
-			os VARCHAR(50),
-			created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_clicks_url_id ON clicks(url_id)`,
-		`CREATE INDEX IF NOT EXISTS idx_clicks_created_at ON clicks(created_at)`,
-		`CREATE INDEX IF NOT EXISTS idx_clicks_ip_address ON clicks(ip_address)`,
-		`ALTER TABLE urls ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255)`,
-		`CREATE TABLE IF NOT EXISTS api_keys (
-			id VARCHAR(36) PRIMARY KEY,
-			key VARCHAR(64) UNIQUE NOT NULL,
-			name VARCHAR(100) NOT NULL,
-			user_id VARCHAR(36) NOT NULL,
-			scopes JSONB NOT NULL DEFAULT '[]',
-			rate_limit INTEGER NOT NULL DEFAULT 100,
-			expires_at TIMESTAMP,
-			created_at TIMESTAMP NOT NULL DEFAULT NOW(),
-			last_used TIMESTAMP,
-			is_active BOOLEAN NOT NULL DEFAULT TRUE
-		)`,
-		`CREATE INDEX IF NOT EXISTS idx_api_keys_key ON api_keys(key)`,
-		`CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id)`,
-	}
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
 
-	for _, migration := range migrations {
-		if _, err := db.ExecContext(ctx, migration); err != nil {
-			return err
-		}
+// migrationLockID is the advisory lock key held while migrating, so replicas
+// starting at the same time do not migrate concurrently.
+const migrationLockID = 7_243_651_001
+
+var dialect = migrate.Dialect{
+	CreateTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT NOW()
+	)`,
+	Insert: `INSERT INTO schema_migrations (version, name) VALUES ($1, $2)`,
+	Delete: `DELETE FROM schema_migrations WHERE version = $1`,
+	Lock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, migrationLockID)
+		return err
+	},
+	Unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, migrationLockID)
+		return err
+	},
+}
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
 	}
+	return migrate.New(db, fsys, dialect)
+}
 
-	return nil
+// RunMigrations applies all pending migrations.
+func RunMigrations(ctx context.Context, db *sql.DB) error {
+	m, err := NewMigrator(db)
+	if err != nil {
+		return err
+	}
+	_, err = m.Up(ctx)
+	return err
 }
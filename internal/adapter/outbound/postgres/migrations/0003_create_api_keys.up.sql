@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS api_keys (
+	id VARCHAR(36) PRIMARY KEY,
+	key_hash VARCHAR(64) NOT NULL,
+	key_prefix VARCHAR(16) NOT NULL DEFAULT '',
+	name VARCHAR(100) NOT NULL,
+	user_id VARCHAR(36) NOT NULL,
+	scopes JSONB NOT NULL DEFAULT '[]',
+	rate_limit INTEGER NOT NULL DEFAULT 100,
+	expires_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	last_used TIMESTAMP,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+
+-- Older installs stored API keys in plaintext, hash them in place
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_hash VARCHAR(64);
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS key_prefix VARCHAR(16) NOT NULL DEFAULT '';
+
+DO $$
+BEGIN
+	IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'api_keys' AND column_name = 'key') THEN
+		UPDATE api_keys
+		SET key_hash = encode(sha256(convert_to(key, 'UTF8')), 'hex'), key_prefix = LEFT(key, 11)
+		WHERE key_hash IS NULL;
+		ALTER TABLE api_keys DROP COLUMN key;
+	END IF;
+END $$;
+
+ALTER TABLE api_keys ALTER COLUMN key_hash SET NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys(key_hash);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS url_revisions (
+	id VARCHAR(36) PRIMARY KEY,
+	url_id VARCHAR(36) NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	action VARCHAR(20) NOT NULL,
+	short_code VARCHAR(20) NOT NULL,
+	original_url TEXT NOT NULL,
+	custom_alias VARCHAR(20),
+	expires_at TIMESTAMP,
+	is_active BOOLEAN NOT NULL,
+	password_hash VARCHAR(255),
+	changed_by VARCHAR(36),
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	UNIQUE (url_id, revision)
+);
@@ -0,0 +1 @@
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS replaced_by VARCHAR(36);
@@ -0,0 +1,4 @@
+ALTER TABLE api_keys DROP COLUMN IF EXISTS workspace_id;
+ALTER TABLE urls DROP COLUMN IF EXISTS workspace_id;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS workspaces (
+	id VARCHAR(36) PRIMARY KEY,
+	name VARCHAR(100) NOT NULL,
+	created_by VARCHAR(36) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+	workspace_id VARCHAR(36) NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+	user_id VARCHAR(36) NOT NULL,
+	role VARCHAR(20) NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+	PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36);
+CREATE INDEX IF NOT EXISTS idx_urls_workspace_id ON urls(workspace_id);
+ALTER TABLE api_keys ADD COLUMN IF NOT EXISTS workspace_id VARCHAR(36);
+CREATE INDEX IF NOT EXISTS idx_api_keys_workspace_id ON api_keys(workspace_id);
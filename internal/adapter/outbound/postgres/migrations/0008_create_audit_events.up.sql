@@ -0,0 +1,16 @@
+CREATE TABLE IF NOT EXISTS audit_events (
+	id BIGSERIAL PRIMARY KEY,
+	actor_user_id VARCHAR(36),
+	actor_api_key_id VARCHAR(36),
+	action VARCHAR(50) NOT NULL,
+	target_type VARCHAR(20) NOT NULL,
+	target_id VARCHAR(36) NOT NULL,
+	workspace_id VARCHAR(36),
+	ip_address VARCHAR(45),
+	changes JSONB,
+	created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_user_id ON audit_events(actor_user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_workspace_id ON audit_events(workspace_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
@@ -0,0 +1,3 @@
+-- 0 follows the server's default redirect type
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS redirect_type SMALLINT NOT NULL DEFAULT 0;
+ALTER TABLE url_revisions ADD COLUMN IF NOT EXISTS redirect_type SMALLINT NOT NULL DEFAULT 0;
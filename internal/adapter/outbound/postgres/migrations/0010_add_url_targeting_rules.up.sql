@@ -0,0 +1,3 @@
+-- JSON array of rules, NULL when the link has none
+ALTER TABLE urls ADD COLUMN IF NOT EXISTS targeting_rules JSONB;
+ALTER TABLE url_revisions ADD COLUMN IF NOT EXISTS targeting_rules JSONB;
@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN IF EXISTS targeting_rule_id;
+ALTER TABLE clicks DROP COLUMN IF EXISTS country_code;
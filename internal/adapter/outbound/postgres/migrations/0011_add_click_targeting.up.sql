@@ -0,0 +1,2 @@
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS country_code VARCHAR(2) NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN IF NOT EXISTS targeting_rule_id VARCHAR(36) NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DELETE FROM url_revisions WHERE action = 'baseline';
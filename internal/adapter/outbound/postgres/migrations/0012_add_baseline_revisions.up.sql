@@ -0,0 +1,6 @@
+-- Links created before revisions were kept get their current state as a
+-- first revision, so their first edit can be undone
+INSERT INTO url_revisions (id, url_id, revision, action, short_code, original_url, custom_alias, expires_at, is_active, password_hash, redirect_type, created_at, targeting_rules)
+SELECT gen_random_uuid()::text, u.id, 1, 'baseline', u.short_code, u.original_url, u.custom_alias, u.expires_at, u.is_active, u.password_hash, u.redirect_type, u.updated_at, u.targeting_rules
+FROM urls u
+WHERE NOT EXISTS (SELECT 1 FROM url_revisions r WHERE r.url_id = u.id);
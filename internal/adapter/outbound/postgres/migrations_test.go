@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/bimakw/url-shortener/pkg/migrate"
+)
+
+func TestMigrations(t *testing.T) {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	migrations, err := migrate.Load(fsys)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("Load() returned no migrations")
+	}
+
+	for i, migration := range migrations {
+		if migration.Version != i+1 {
+			t.Errorf("migration %d has version %d, versions must be sequential", i, migration.Version)
+		}
+	}
+}
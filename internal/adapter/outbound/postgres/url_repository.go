@@ -3,7 +3,9 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"sort"
 	"time"
 
 	"github.com/bimakw/url-shortener/internal/domain/entity"
@@ -13,6 +15,8 @@ import (
 
 var _ repository.URLRepository = (*URLRepository)(nil)
 
+const urlColumns = `id, short_code, original_url, custom_alias, user_id, workspace_id, expires_at, created_at, updated_at, click_count, is_active, password_hash, redirect_type, targeting_rules`
+
 type URLRepository struct {
 	db *sql.DB
 }
@@ -22,6 +26,11 @@ func NewURLRepository(db *sql.DB) *URLRepository {
 }
 
 func (r *URLRepository) Create(ctx context.Context, url *entity.URL) error {
+	rules, err := encodeTargetingRules(url.TargetingRules)
+	if err != nil {
+		return err
+	}
+
 	if url.ID == "" {
 		url.ID = uuid.New().String()
 	}
@@ -30,52 +39,34 @@ func (r *URLRepository) Create(ctx context.Context, url *entity.URL) error {
 	url.IsActive = true
 
 	query := `
-		INSERT INTO urls (id, short_code, original_url, custom_alias, user_id, expires_at, created_at, updated_at, click_count, is_active, password_hash)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		INSERT INTO urls (id, short_code, original_url, custom_alias, user_id, workspace_id, expires_at, created_at, updated_at, click_count, is_active, password_hash, redirect_type, targeting_rules)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = r.db.ExecContext(ctx, query,
 		url.ID,
 		url.ShortCode,
 		url.OriginalURL,
 		nullString(url.CustomAlias),
 		nullString(url.UserID),
+		nullString(url.WorkspaceID),
 		nullTime(url.ExpiresAt),
 		url.CreatedAt,
 		url.UpdatedAt,
 		url.ClickCount,
 		url.IsActive,
 		nullString(url.PasswordHash),
+		url.RedirectType,
+		rules,
 	)
 
 	return err
 }
 
 func (r *URLRepository) GetByShortCode(ctx context.Context, shortCode string) (*entity.URL, error) {
-	query := `
-		SELECT id, short_code, original_url, custom_alias, user_id, expires_at, created_at, updated_at, click_count, is_active, password_hash
-		FROM urls
-		WHERE short_code = $1 OR custom_alias = $1
-	`
-
-	url := &entity.URL{}
-	var customAlias, userID, passwordHash sql.NullString
-	var expiresAt sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, shortCode).Scan(
-		&url.ID,
-		&url.ShortCode,
-		&url.OriginalURL,
-		&customAlias,
-		&userID,
-		&expiresAt,
-		&url.CreatedAt,
-		&url.UpdatedAt,
-		&url.ClickCount,
-		&url.IsActive,
-		&passwordHash,
-	)
+	query := `SELECT ` + urlColumns + ` FROM urls WHERE short_code = $1 OR custom_alias = $1`
 
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, shortCode))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -83,41 +74,13 @@ func (r *URLRepository) GetByShortCode(ctx context.Context, shortCode string) (*
 		return nil, err
 	}
 
-	url.CustomAlias = customAlias.String
-	url.UserID = userID.String
-	url.PasswordHash = passwordHash.String
-	if expiresAt.Valid {
-		url.ExpiresAt = &expiresAt.Time
-	}
-
 	return url, nil
 }
 
 func (r *URLRepository) GetByID(ctx context.Context, id string) (*entity.URL, error) {
-	query := `
-		SELECT id, short_code, original_url, custom_alias, user_id, expires_at, created_at, updated_at, click_count, is_active, password_hash
-		FROM urls
-		WHERE id = $1
-	`
-
-	url := &entity.URL{}
-	var customAlias, userID, passwordHash sql.NullString
-	var expiresAt sql.NullTime
-
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&url.ID,
-		&url.ShortCode,
-		&url.OriginalURL,
-		&customAlias,
-		&userID,
-		&expiresAt,
-		&url.CreatedAt,
-		&url.UpdatedAt,
-		&url.ClickCount,
-		&url.IsActive,
-		&passwordHash,
-	)
+	query := `SELECT ` + urlColumns + ` FROM urls WHERE id = $1`
 
+	url, err := scanURL(r.db.QueryRowContext(ctx, query, id))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, nil
@@ -125,26 +88,55 @@ func (r *URLRepository) GetByID(ctx context.Context, id string) (*entity.URL, er
 		return nil, err
 	}
 
-	url.CustomAlias = customAlias.String
-	url.UserID = userID.String
-	url.PasswordHash = passwordHash.String
-	if expiresAt.Valid {
-		url.ExpiresAt = &expiresAt.Time
-	}
-
 	return url, nil
 }
 
 func (r *URLRepository) GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*entity.URL, error) {
 	query := `
-		SELECT id, short_code, original_url, custom_alias, user_id, expires_at, created_at, updated_at, click_count, is_active, password_hash
+		SELECT ` + urlColumns + `
 		FROM urls
 		WHERE user_id = $1
 		ORDER BY created_at DESC
 		LIMIT $2 OFFSET $3
 	`
+	return r.list(ctx, query, userID, limit, offset)
+}
+
+func (r *URLRepository) GetByWorkspaceID(ctx context.Context, workspaceID string, limit, offset int) ([]*entity.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE workspace_id = $1
+		ORDER BY created_at DESC
+		LIMIT $2 OFFSET $3
+	`
+	return r.list(ctx, query, workspaceID, limit, offset)
+}
+
+func (r *URLRepository) List(ctx context.Context, limit, offset int) ([]*entity.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		ORDER BY created_at DESC
+		LIMIT $1 OFFSET $2
+	`
+	return r.list(ctx, query, limit, offset)
+}
+
+// GetMostClicked returns the active links with the most clicks.
+func (r *URLRepository) GetMostClicked(ctx context.Context, limit int) ([]*entity.URL, error) {
+	query := `
+		SELECT ` + urlColumns + `
+		FROM urls
+		WHERE is_active = true AND (expires_at IS NULL OR expires_at > NOW())
+		ORDER BY click_count DESC
+		LIMIT $1
+	`
+	return r.list(ctx, query, limit)
+}
 
-	rows, err := r.db.QueryContext(ctx, query, userID, limit, offset)
+func (r *URLRepository) list(ctx context.Context, query string, args ...any) ([]*entity.URL, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -152,34 +144,10 @@ func (r *URLRepository) GetByUserID(ctx context.Context, userID string, limit, o
 
 	var urls []*entity.URL
 	for rows.Next() {
-		url := &entity.URL{}
-		var customAlias, uid, passwordHash sql.NullString
-		var expiresAt sql.NullTime
-
-		err := rows.Scan(
-			&url.ID,
-			&url.ShortCode,
-			&url.OriginalURL,
-			&customAlias,
-			&uid,
-			&expiresAt,
-			&url.CreatedAt,
-			&url.UpdatedAt,
-			&url.ClickCount,
-			&url.IsActive,
-			&passwordHash,
-		)
+		url, err := scanURL(rows)
 		if err != nil {
 			return nil, err
 		}
-
-		url.CustomAlias = customAlias.String
-		url.UserID = uid.String
-		url.PasswordHash = passwordHash.String
-		if expiresAt.Valid {
-			url.ExpiresAt = &expiresAt.Time
-		}
-
 		urls = append(urls, url)
 	}
 
@@ -187,21 +155,34 @@ func (r *URLRepository) GetByUserID(ctx context.Context, userID string, limit, o
 }
 
 func (r *URLRepository) Update(ctx context.Context, url *entity.URL) error {
+	return updateURL(ctx, r.db, url)
+}
+
+func updateURL(ctx context.Context, db execer, url *entity.URL) error {
+	rules, err := encodeTargetingRules(url.TargetingRules)
+	if err != nil {
+		return err
+	}
+
 	url.UpdatedAt = time.Now()
 
 	query := `
 		UPDATE urls
-		SET original_url = $2, custom_alias = $3, expires_at = $4, updated_at = $5, is_active = $6
+		SET short_code = $2, original_url = $3, custom_alias = $4, expires_at = $5, updated_at = $6, is_active = $7, password_hash = $8, redirect_type = $9, targeting_rules = $10
 		WHERE id = $1
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	_, err = db.ExecContext(ctx, query,
 		url.ID,
+		url.ShortCode,
 		url.OriginalURL,
 		nullString(url.CustomAlias),
 		nullTime(url.ExpiresAt),
 		url.UpdatedAt,
 		url.IsActive,
+		nullString(url.PasswordHash),
+		url.RedirectType,
+		rules,
 	)
 
 	return err
@@ -213,10 +194,39 @@ func (r *URLRepository) Delete(ctx context.Context, id string) error {
 	return err
 }
 
-func (r *URLRepository) IncrementClickCount(ctx context.Context, id string) error {
-	query := `UPDATE urls SET click_count = click_count + 1, updated_at = $2 WHERE id = $1`
-	_, err := r.db.ExecContext(ctx, query, id, time.Now())
-	return err
+// AddClickCounts updates the links in ID order, so concurrent calls lock
+// rows in the same order and cannot deadlock.
+func (r *URLRepository) AddClickCounts(ctx context.Context, counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(counts))
+	for id := range counts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, `UPDATE urls SET click_count = click_count + $2, updated_at = $3 WHERE id = $1`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	updatedAt := time.Now()
+	for _, id := range ids {
+		if _, err := stmt.ExecContext(ctx, id, counts[id], updatedAt); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 func (r *URLRepository) ShortCodeExists(ctx context.Context, shortCode string) (bool, error) {
@@ -226,6 +236,47 @@ func (r *URLRepository) ShortCodeExists(ctx context.Context, shortCode string) (
 	return exists, err
 }
 
+func scanURL(row rowScanner) (*entity.URL, error) {
+	url := &entity.URL{}
+	var customAlias, userID, workspaceID, passwordHash sql.NullString
+	var expiresAt sql.NullTime
+	var rules []byte
+
+	err := row.Scan(
+		&url.ID,
+		&url.ShortCode,
+		&url.OriginalURL,
+		&customAlias,
+		&userID,
+		&workspaceID,
+		&expiresAt,
+		&url.CreatedAt,
+		&url.UpdatedAt,
+		&url.ClickCount,
+		&url.IsActive,
+		&passwordHash,
+		&url.RedirectType,
+		&rules,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	url.CustomAlias = customAlias.String
+	url.UserID = userID.String
+	url.WorkspaceID = workspaceID.String
+	url.PasswordHash = passwordHash.String
+	url.TargetingRules, err = decodeTargetingRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		url.ExpiresAt = &expiresAt.Time
+	}
+
+	return url, nil
+}
+
 func nullString(s string) sql.NullString {
 	if s == "" {
 		return sql.NullString{}
@@ -233,6 +284,30 @@ func nullString(s string) sql.NullString {
 	return sql.NullString{String: s, Valid: true}
 }
 
+// encodeTargetingRules stores a link without rules as NULL. A nil []byte
+// would not do, as drivers pass it on as an empty value.
+func encodeTargetingRules(rules []entity.TargetingRule) (sql.NullString, error) {
+	if len(rules) == 0 {
+		return sql.NullString{}, nil
+	}
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(data), Valid: true}, nil
+}
+
+func decodeTargetingRules(data []byte) ([]entity.TargetingRule, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var rules []entity.TargetingRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
 func nullTime(t *time.Time) sql.NullTime {
 	if t == nil {
 		return sql.NullTime{}
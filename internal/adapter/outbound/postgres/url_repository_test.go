@@ -0,0 +1,30 @@
+package postgres
+
+import (
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// A link without rules must reach the JSONB column as NULL, which does not
+// need a database to check
+func TestEncodeTargetingRules(t *testing.T) {
+	for _, rules := range [][]entity.TargetingRule{nil, {}} {
+		encoded, err := encodeTargetingRules(rules)
+		if err != nil {
+			t.Fatalf("encodeTargetingRules(%v) error = %v", rules, err)
+		}
+		if value, _ := encoded.Value(); value != nil {
+			t.Errorf("encodeTargetingRules(%v) = %#v, want NULL", rules, value)
+		}
+	}
+
+	encoded, err := encodeTargetingRules([]entity.TargetingRule{{Device: "Mobile", URL: "https://example.com/m"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := decodeTargetingRules([]byte(encoded.String))
+	if err != nil || len(rules) != 1 || rules[0].Device != "Mobile" {
+		t.Errorf("decodeTargetingRules() = %v, %v, want the mobile rule back", rules, err)
+	}
+}
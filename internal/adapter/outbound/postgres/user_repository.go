@@ -0,0 +1,106 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+var _ repository.UserRepository = (*UserRepository)(nil)
+
+// uniqueViolation is the SQLSTATE of an insert or update breaking a unique
+// constraint.
+const uniqueViolation = "23505"
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+func (r *UserRepository) Create(ctx context.Context, user *entity.User) error {
+	if user.ID == "" {
+		user.ID = uuid.New().String()
+	}
+	user.CreatedAt = time.Now()
+	user.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO users (id, email, name, password_hash, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		user.ID,
+		user.Email,
+		nullString(user.Name),
+		user.PasswordHash,
+		user.CreatedAt,
+		user.UpdatedAt,
+	)
+
+	// The email is the only unique column a new ID can clash on
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+		return repository.ErrEmailTaken
+	}
+	return err
+}
+
+func (r *UserRepository) GetByID(ctx context.Context, id string) (*entity.User, error) {
+	query := `
+		SELECT id, email, name, password_hash, created_at, updated_at
+		FROM users
+		WHERE id = $1
+	`
+	return r.getOne(ctx, query, id)
+}
+
+func (r *UserRepository) GetByEmail(ctx context.Context, email string) (*entity.User, error) {
+	query := `
+		SELECT id, email, name, password_hash, created_at, updated_at
+		FROM users
+		WHERE email = $1
+	`
+	return r.getOne(ctx, query, email)
+}
+
+func (r *UserRepository) EmailExists(ctx context.Context, email string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM users WHERE email = $1)`
+	var exists bool
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&exists)
+	return exists, err
+}
+
+func (r *UserRepository) getOne(ctx context.Context, query string, arg string) (*entity.User, error) {
+	user := &entity.User{}
+	var name sql.NullString
+
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(
+		&user.ID,
+		&user.Email,
+		&name,
+		&user.PasswordHash,
+		&user.CreatedAt,
+		&user.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	user.Name = name.String
+
+	return user, nil
+}
@@ -0,0 +1,212 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.WorkspaceRepository = (*WorkspaceRepository)(nil)
+
+type WorkspaceRepository struct {
+	db *sql.DB
+}
+
+func NewWorkspaceRepository(db *sql.DB) *WorkspaceRepository {
+	return &WorkspaceRepository{db: db}
+}
+
+func (r *WorkspaceRepository) Create(ctx context.Context, workspace *entity.Workspace) error {
+	if workspace.ID == "" {
+		workspace.ID = uuid.New().String()
+	}
+	workspace.CreatedAt = time.Now()
+	workspace.UpdatedAt = time.Now()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO workspaces (id, name, created_by, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, workspace.ID, workspace.Name, workspace.CreatedBy, workspace.CreatedAt, workspace.UpdatedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, workspace.ID, workspace.CreatedBy, entity.RoleOwner, workspace.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *WorkspaceRepository) GetByID(ctx context.Context, id string) (*entity.Workspace, error) {
+	query := `
+		SELECT id, name, created_by, created_at, updated_at
+		FROM workspaces
+		WHERE id = $1
+	`
+
+	workspace := &entity.Workspace{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&workspace.ID,
+		&workspace.Name,
+		&workspace.CreatedBy,
+		&workspace.CreatedAt,
+		&workspace.UpdatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return workspace, nil
+}
+
+func (r *WorkspaceRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.Workspace, error) {
+	query := `
+		SELECT w.id, w.name, w.created_by, w.created_at, w.updated_at
+		FROM workspaces w
+		JOIN workspace_members m ON m.workspace_id = w.id
+		WHERE m.user_id = $1
+		ORDER BY w.created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var workspaces []*entity.Workspace
+	for rows.Next() {
+		workspace := &entity.Workspace{}
+		err := rows.Scan(
+			&workspace.ID,
+			&workspace.Name,
+			&workspace.CreatedBy,
+			&workspace.CreatedAt,
+			&workspace.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		workspaces = append(workspaces, workspace)
+	}
+
+	return workspaces, rows.Err()
+}
+
+func (r *WorkspaceRepository) GetMember(ctx context.Context, workspaceID, userID string) (*entity.WorkspaceMember, error) {
+	query := `
+		SELECT m.workspace_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at
+		FROM workspace_members m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1 AND m.user_id = $2
+	`
+
+	member := &entity.WorkspaceMember{}
+	err := r.db.QueryRowContext(ctx, query, workspaceID, userID).Scan(
+		&member.WorkspaceID,
+		&member.UserID,
+		&member.Email,
+		&member.Role,
+		&member.CreatedAt,
+	)
+
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return member, nil
+}
+
+func (r *WorkspaceRepository) GetMembers(ctx context.Context, workspaceID string) ([]*entity.WorkspaceMember, error) {
+	query := `
+		SELECT m.workspace_id, m.user_id, COALESCE(u.email, ''), m.role, m.created_at
+		FROM workspace_members m
+		LEFT JOIN users u ON u.id = m.user_id
+		WHERE m.workspace_id = $1
+		ORDER BY m.created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*entity.WorkspaceMember
+	for rows.Next() {
+		member := &entity.WorkspaceMember{}
+		err := rows.Scan(
+			&member.WorkspaceID,
+			&member.UserID,
+			&member.Email,
+			&member.Role,
+			&member.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	return members, rows.Err()
+}
+
+func (r *WorkspaceRepository) AddMember(ctx context.Context, member *entity.WorkspaceMember) error {
+	member.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO workspace_members (workspace_id, user_id, role, created_at)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	_, err := r.db.ExecContext(ctx, query,
+		member.WorkspaceID,
+		member.UserID,
+		member.Role,
+		member.CreatedAt,
+	)
+
+	return err
+}
+
+func (r *WorkspaceRepository) UpdateMemberRole(ctx context.Context, workspaceID, userID string, role entity.WorkspaceRole) error {
+	query := `UPDATE workspace_members SET role = $3 WHERE workspace_id = $1 AND user_id = $2`
+	_, err := r.db.ExecContext(ctx, query, workspaceID, userID, role)
+	return err
+}
+
+func (r *WorkspaceRepository) RemoveMember(ctx context.Context, workspaceID, userID string) error {
+	query := `DELETE FROM workspace_members WHERE workspace_id = $1 AND user_id = $2`
+	_, err := r.db.ExecContext(ctx, query, workspaceID, userID)
+	return err
+}
+
+func (r *WorkspaceRepository) CountOwners(ctx context.Context, workspaceID string) (int, error) {
+	var count int
+	query := `SELECT COUNT(*) FROM workspace_members WHERE workspace_id = $1 AND role = $2`
+	err := r.db.QueryRowContext(ctx, query, workspaceID, entity.RoleOwner).Scan(&count)
+	return count, err
+}
@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// Bump cacheFormat whenever cachedURL or a type it holds changes
+const cacheFormat = 5
+
+// cachedURL keeps what entity.URL's JSON leaves out, such as the password hash
+type cachedURL struct {
+	Format       int        `json:"format"`
+	ID           string     `json:"id"`
+	ShortCode    string     `json:"short_code"`
+	OriginalURL  string     `json:"original_url"`
+	CustomAlias  string     `json:"custom_alias,omitempty"`
+	UserID       string     `json:"user_id,omitempty"`
+	WorkspaceID  string     `json:"workspace_id,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	ClickCount   int64      `json:"click_count"`
+	IsActive     bool       `json:"is_active"`
+	PasswordHash string     `json:"password_hash,omitempty"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+
+	TargetingRules []entity.TargetingRule `json:"targeting_rules,omitempty"`
+}
+
+func encodeURL(url *entity.URL) ([]byte, error) {
+	return json.Marshal(cachedURL{
+		Format:       cacheFormat,
+		ID:           url.ID,
+		ShortCode:    url.ShortCode,
+		OriginalURL:  url.OriginalURL,
+		CustomAlias:  url.CustomAlias,
+		UserID:       url.UserID,
+		WorkspaceID:  url.WorkspaceID,
+		ExpiresAt:    url.ExpiresAt,
+		CreatedAt:    url.CreatedAt,
+		UpdatedAt:    url.UpdatedAt,
+		ClickCount:   url.ClickCount,
+		IsActive:     url.IsActive,
+		PasswordHash: url.PasswordHash,
+		RedirectType: url.RedirectType,
+
+		TargetingRules: url.TargetingRules,
+	})
+}
+
+func decodeURL(data []byte) (*entity.URL, error) {
+	var cached cachedURL
+	if err := json.Unmarshal(data, &cached); err != nil {
+		return nil, err
+	}
+	if cached.Format != cacheFormat {
+		return nil, nil
+	}
+
+	return &entity.URL{
+		ID:           cached.ID,
+		ShortCode:    cached.ShortCode,
+		OriginalURL:  cached.OriginalURL,
+		CustomAlias:  cached.CustomAlias,
+		UserID:       cached.UserID,
+		WorkspaceID:  cached.WorkspaceID,
+		ExpiresAt:    cached.ExpiresAt,
+		CreatedAt:    cached.CreatedAt,
+		UpdatedAt:    cached.UpdatedAt,
+		ClickCount:   cached.ClickCount,
+		IsActive:     cached.IsActive,
+		PasswordHash: cached.PasswordHash,
+		RedirectType: cached.RedirectType,
+
+		TargetingRules: cached.TargetingRules,
+	}, nil
+}
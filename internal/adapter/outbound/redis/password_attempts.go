@@ -0,0 +1,115 @@
+package redis
+
+import (
+	"context"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+var _ repository.PasswordAttemptRepository = (*PasswordAttemptRepository)(nil)
+
+const (
+	attemptsKeyPrefix = "pwfail:"
+	lockoutKeyPrefix  = "pwlock:"
+)
+
+// reserveScript counts an attempt unless the key is locked out and ARGV[5]
+// is 0, and locks it out once the attempts pass the free ones. The window of
+// the count starts on the first attempt only, so later attempts do not keep
+// it alive. It returns whether the attempt is allowed, the attempts so far and
+// the lockout in milliseconds.
+var reserveScript = redis.NewScript(`
+local locked = redis.call('PTTL', KEYS[2])
+if locked > 0 and ARGV[5] == '0' then
+	return {0, 0, locked}
+end
+
+local attempts = redis.call('INCR', KEYS[1])
+if attempts == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+
+local free = tonumber(ARGV[2])
+if attempts <= free then
+	return {1, attempts, 0}
+end
+
+local lockout = tonumber(ARGV[3])
+local max_lockout = tonumber(ARGV[4])
+for i = 2, attempts - free do
+	if lockout >= max_lockout then
+		break
+	end
+	lockout = lockout * 2
+end
+lockout = math.min(lockout, max_lockout)
+redis.call('SET', KEYS[2], 1, 'PX', lockout)
+return {1, attempts, lockout}
+`)
+
+// releaseScript takes back an attempt and lifts the lockout once the attempts
+// left are not past the free ones. DECR keeps the expiry of the count.
+var releaseScript = redis.NewScript(`
+local attempts = tonumber(redis.call('GET', KEYS[1]) or '0')
+if attempts > 0 then
+	attempts = redis.call('DECR', KEYS[1])
+end
+if attempts <= tonumber(ARGV[1]) then
+	redis.call('DEL', KEYS[2])
+end
+return attempts
+`)
+
+// PasswordAttemptRepository keeps password attempts in Redis, so every
+// replica counts them together.
+type PasswordAttemptRepository struct {
+	client *redis.Client
+}
+
+func NewPasswordAttemptRepository(client *redis.Client) *PasswordAttemptRepository {
+	return &PasswordAttemptRepository{client: client}
+}
+
+func (r *PasswordAttemptRepository) Reserve(ctx context.Context, key string, policy repository.AttemptPolicy) (repository.AttemptReservation, error) {
+	return r.reserve(ctx, key, policy, false)
+}
+
+func (r *PasswordAttemptRepository) Count(ctx context.Context, key string, policy repository.AttemptPolicy) (repository.AttemptReservation, error) {
+	return r.reserve(ctx, key, policy, true)
+}
+
+func (r *PasswordAttemptRepository) reserve(ctx context.Context, key string, policy repository.AttemptPolicy, force bool) (repository.AttemptReservation, error) {
+	forceArg := 0
+	if force {
+		forceArg = 1
+	}
+
+	keys := []string{attemptsKeyPrefix + key, lockoutKeyPrefix + key}
+	result, err := reserveScript.Run(ctx, r.client, keys,
+		policy.Window.Milliseconds(),
+		policy.Free,
+		policy.BaseLockout.Milliseconds(),
+		policy.MaxLockout.Milliseconds(),
+		forceArg,
+	).Int64Slice()
+	if err != nil {
+		return repository.AttemptReservation{}, err
+	}
+
+	return repository.AttemptReservation{
+		Allowed:   result[0] == 1,
+		Attempts:  result[1],
+		LockedFor: time.Duration(result[2]) * time.Millisecond,
+	}, nil
+}
+
+func (r *PasswordAttemptRepository) Release(ctx context.Context, key string, policy repository.AttemptPolicy) error {
+	keys := []string{attemptsKeyPrefix + key, lockoutKeyPrefix + key}
+	return releaseScript.Run(ctx, r.client, keys, policy.Free).Err()
+}
+
+func (r *PasswordAttemptRepository) Reset(ctx context.Context, key string) error {
+	return r.client.Del(ctx, attemptsKeyPrefix+key, lockoutKeyPrefix+key).Err()
+}
@@ -0,0 +1,14 @@
+package redis
+
+import (
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/internal/domain/repository/repositorytest"
+)
+
+func TestPasswordAttemptContract(t *testing.T) {
+	repositorytest.RunPasswordAttempts(t, func(t *testing.T) repository.PasswordAttemptRepository {
+		return NewPasswordAttemptRepository(newTestClient(t))
+	})
+}
@@ -0,0 +1,125 @@
+package redis
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/pkg/lru"
+)
+
+var _ repository.URLCacheRepository = (*TieredURLCache)(nil)
+
+// TieredURLCache keeps the most recently used links in process, in front of
+// Redis, and evicts them as any replica invalidates them.
+type TieredURLCache struct {
+	remote *URLCacheRepository
+	local  *lru.Cache[string, entity.URL]
+	pubsub *redis.PubSub
+	done   chan struct{}
+
+	// generation counts invalidations
+	generation atomic.Uint64
+}
+
+func NewTieredURLCache(remote *URLCacheRepository, size int, localTTL time.Duration) *TieredURLCache {
+	c := &TieredURLCache{
+		remote: remote,
+		local:  lru.New[string, entity.URL](size, localTTL),
+		pubsub: remote.client.Subscribe(context.Background(), invalidateChannel),
+		done:   make(chan struct{}),
+	}
+	go c.listen()
+
+	return c
+}
+
+func (c *TieredURLCache) Get(ctx context.Context, shortCode string) (*entity.URL, error) {
+	if url, ok := c.local.Get(shortCode); ok {
+		return &url, nil
+	}
+
+	// Not kept locally if it was invalidated while being read
+	generation := c.generation.Load()
+	url, err := c.remote.Get(ctx, shortCode)
+	if err != nil || url == nil {
+		return url, err
+	}
+
+	if c.generation.Load() == generation {
+		c.local.Add(shortCode, *url)
+	}
+	return url, nil
+}
+
+func (c *TieredURLCache) Set(ctx context.Context, url *entity.URL) error {
+	if err := c.remote.Set(ctx, url); err != nil {
+		return err
+	}
+
+	c.local.Add(url.ShortCode, *url)
+	return nil
+}
+
+func (c *TieredURLCache) SetNotFound(ctx context.Context, shortCode string, ttl time.Duration) error {
+	return c.remote.SetNotFound(ctx, shortCode, ttl)
+}
+
+func (c *TieredURLCache) Delete(ctx context.Context, shortCode string) error {
+	// Our own invalidation arrives too, but reads in between must miss
+	c.local.Remove(shortCode)
+	return c.remote.Delete(ctx, shortCode)
+}
+
+func (c *TieredURLCache) AddPendingClicks(ctx context.Context, counts map[string]int64) error {
+	return c.remote.AddPendingClicks(ctx, counts)
+}
+
+func (c *TieredURLCache) GetPendingClicks(ctx context.Context, urlIDs []string) (map[string]int64, error) {
+	return c.remote.GetPendingClicks(ctx, urlIDs)
+}
+
+func (c *TieredURLCache) TakePendingClicks(ctx context.Context) (*repository.PendingClickBatch, error) {
+	return c.remote.TakePendingClicks(ctx)
+}
+
+func (c *TieredURLCache) DonePendingClicks(ctx context.Context, batchID string) error {
+	return c.remote.DonePendingClicks(ctx, batchID)
+}
+
+func (c *TieredURLCache) ReturnPendingClicks(ctx context.Context, batchID string) error {
+	return c.remote.ReturnPendingClicks(ctx, batchID)
+}
+
+func (c *TieredURLCache) ClaimPendingClicks(ctx context.Context, olderThan time.Duration) ([]*repository.PendingClickBatch, error) {
+	return c.remote.ClaimPendingClicks(ctx, olderThan)
+}
+
+func (c *TieredURLCache) Close() error {
+	err := c.pubsub.Close()
+	<-c.done
+	return err
+}
+
+func (c *TieredURLCache) listen() {
+	defer close(c.done)
+
+	for msg := range c.pubsub.ChannelWithSubscriptions() {
+		c.generation.Add(1)
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Invalidations were missed while unsubscribed
+			c.local.Purge()
+		case *redis.Message:
+			if msg.Payload == flushAll {
+				c.local.Purge()
+			} else {
+				c.local.Remove(msg.Payload)
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/internal/domain/repository/repositorytest"
+)
+
+func newTestTieredCache(t *testing.T, remote *URLCacheRepository) *TieredURLCache {
+	t.Helper()
+	cache := NewTieredURLCache(remote, 100, time.Hour)
+	t.Cleanup(func() { cache.Close() })
+	return cache
+}
+
+// waitSubscribed waits until n caches listen for invalidations.
+func waitSubscribed(t *testing.T, remote *URLCacheRepository, n int64) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		subs, err := remote.client.PubSubNumSub(context.Background(), invalidateChannel).Result()
+		if err == nil && subs[invalidateChannel] == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d caches subscribed, want %d", subs[invalidateChannel], n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestTieredURLCacheContract(t *testing.T) {
+	repositorytest.RunURLCache(t, func(t *testing.T) (repository.URLCacheRepository, repositorytest.Advance) {
+		client, advance := newTestClientWithClock(t)
+		return newTestTieredCache(t, NewURLCacheRepository(client, time.Hour)), advance
+	})
+}
+
+func TestTieredURLCacheInvalidation(t *testing.T) {
+	ctx := context.Background()
+	remote := NewURLCacheRepository(newTestClient(t), time.Hour)
+	a := newTestTieredCache(t, remote)
+	b := newTestTieredCache(t, remote)
+	waitSubscribed(t, remote, 2)
+
+	url := &entity.URL{ID: "url-1", ShortCode: "abc123", OriginalURL: "https://example.com", IsActive: true}
+	if err := a.Set(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Get(ctx, "abc123"); got == nil {
+		t.Fatal("Get() on the other replica missed")
+	}
+
+	// b now answers from memory, even if Redis changes under it
+	edited := *url
+	edited.OriginalURL = "https://example.org"
+	if err := remote.Set(ctx, &edited); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Get(ctx, "abc123"); got == nil || got.OriginalURL != url.OriginalURL {
+		t.Fatalf("Get() = %v, want the locally cached link", got)
+	}
+
+	// Deleting on a evicts on b
+	if err := a.Delete(ctx, "abc123"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool {
+		got, _ := b.Get(ctx, "abc123")
+		return got == nil
+	})
+
+	// So does a flush, e.g. from shortenerctl
+	if err := b.Set(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, func() bool {
+		got, _ := b.Get(ctx, "abc123")
+		return got == nil
+	})
+}
+
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
@@ -2,23 +2,104 @@ package redis
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/bimakw/url-shortener/internal/domain/entity"
 	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 var _ repository.URLCacheRepository = (*URLCacheRepository)(nil)
 
+var errBatchesChanged = errors.New("taken click batches kept changing while counting pending clicks")
+
 const (
-	urlKeyPrefix   = "url:"
-	clickKeyPrefix = "click:"
-	defaultTTL     = 1 * time.Hour
+	urlKeyPrefix     = "url:"
+	pendingClicksKey = "clicks:pending" // hash of URL ID to click count
+	clickBatchesKey  = "clicks:batches" // sorted set of batch IDs by when they were taken
+	clickBatchPrefix = "clicks:batch:"  // hash of URL ID to click count per taken batch
+	notFoundValue    = "-"              // cached in place of a missing link
+	defaultTTL       = 1 * time.Hour
+
+	// pendingClicksChunk is how many URL IDs getPendingScript is given at
+	// once, well below what Lua's unpack can take
+	pendingClicksChunk = 1000
+	// pendingClicksRetries is how often to look up the taken batches again
+	// when they change while counting
+	pendingClicksRetries = 5
+
+	// invalidateChannel carries the short codes removed from the cache, or
+	// flushAll, to the in-process caches of every replica
+	invalidateChannel = "urls:invalidate"
+	flushAll          = "*"
 )
 
+// takeScript renames the pending clicks to a new batch, so no increment
+// lands between reading and removing them, and records when it was taken.
+var takeScript = redis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 0 then
+	return 0
+end
+redis.call('RENAME', KEYS[1], KEYS[3])
+redis.call('ZADD', KEYS[2], ARGV[1], ARGV[2])
+return 1
+`)
+
+// returnScript adds the counts of a batch back to the pending clicks and
+// drops the batch.
+var returnScript = redis.NewScript(`
+local counts = redis.call('HGETALL', KEYS[3])
+for i = 1, #counts, 2 do
+	redis.call('HINCRBY', KEYS[1], counts[i], counts[i + 1])
+end
+redis.call('DEL', KEYS[3])
+redis.call('ZREM', KEYS[2], ARGV[1])
+return 1
+`)
+
+// claimScript marks the batches taken before ARGV[1] as taken at ARGV[2] and
+// returns their IDs.
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1])
+for _, id in ipairs(ids) do
+	redis.call('ZADD', KEYS[1], ARGV[2], id)
+end
+return ids
+`)
+
+// getPendingScript sums the pending and taken clicks of each URL ID in ARGV.
+// KEYS[3] onwards must be the keys of every taken batch, which ARGV[1]
+// prefixes; it returns nil for the caller to retry when they are not.
+var getPendingScript = redis.NewScript(`
+local ids = redis.call('ZRANGE', KEYS[2], 0, -1)
+if #ids ~= #KEYS - 2 then
+	return false
+end
+for i, id in ipairs(ids) do
+	if KEYS[i + 2] ~= ARGV[1] .. id then
+		return false
+	end
+end
+
+local counts = redis.call('HMGET', KEYS[1], unpack(ARGV, 2))
+for k = 3, #KEYS do
+	local taken = redis.call('HMGET', KEYS[k], unpack(ARGV, 2))
+	for i, count in ipairs(taken) do
+		if count then
+			counts[i] = (tonumber(counts[i]) or 0) + tonumber(count)
+		end
+	end
+end
+for i, count in ipairs(counts) do
+	counts[i] = count and tonumber(count) or -1
+end
+return counts
+`)
+
 type URLCacheRepository struct {
 	client *redis.Client
 	ttl    time.Duration
@@ -44,19 +125,17 @@ func (r *URLCacheRepository) Get(ctx context.Context, shortCode string) (*entity
 		}
 		return nil, err
 	}
-
-	var url entity.URL
-	if err := json.Unmarshal(data, &url); err != nil {
-		return nil, err
+	if string(data) == notFoundValue {
+		return nil, repository.ErrCachedNotFound
 	}
 
-	return &url, nil
+	return decodeURL(data)
 }
 
 func (r *URLCacheRepository) Set(ctx context.Context, url *entity.URL) error {
 	key := urlKeyPrefix + url.ShortCode
 
-	data, err := json.Marshal(url)
+	data, err := encodeURL(url)
 	if err != nil {
 		return err
 	}
@@ -73,36 +152,186 @@ func (r *URLCacheRepository) Set(ctx context.Context, url *entity.URL) error {
 	return r.client.Set(ctx, key, data, ttl).Err()
 }
 
+func (r *URLCacheRepository) SetNotFound(ctx context.Context, shortCode string, ttl time.Duration) error {
+	// NX: a link cached meanwhile, e.g. by its creation, wins
+	return r.client.SetNX(ctx, urlKeyPrefix+shortCode, notFoundValue, ttl).Err()
+}
+
+// Delete removes a cached URL here and, through pub/sub, from every
+// replica's in-process cache.
 func (r *URLCacheRepository) Delete(ctx context.Context, shortCode string) error {
 	key := urlKeyPrefix + shortCode
-	return r.client.Del(ctx, key).Err()
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, key)
+		pipe.Publish(ctx, invalidateChannel, shortCode)
+		return nil
+	})
+	return err
+}
+
+// Flush removes every cached URL, including from the in-process caches, and
+// returns how many were removed. Pending clicks are left alone.
+func (r *URLCacheRepository) Flush(ctx context.Context) (int64, error) {
+	// Even a partial flush may have removed links replicas still hold
+	defer r.client.Publish(context.WithoutCancel(ctx), invalidateChannel, flushAll)
+
+	var removed int64
+	iter := r.client.Scan(ctx, 0, urlKeyPrefix+"*", 500).Iterator()
+
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == 500 {
+			n, err := r.client.Del(ctx, keys...).Result()
+			if err != nil {
+				return removed, err
+			}
+			removed += n
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+
+	if len(keys) > 0 {
+		n, err := r.client.Del(ctx, keys...).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+
+	return removed, nil
+}
+
+func (r *URLCacheRepository) AddPendingClicks(ctx context.Context, counts map[string]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for id, delta := range counts {
+			pipe.HIncrBy(ctx, pendingClicksKey, id, delta)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *URLCacheRepository) GetPendingClicks(ctx context.Context, urlIDs []string) (map[string]int64, error) {
+	counts := make(map[string]int64, len(urlIDs))
+	for start := 0; start < len(urlIDs); start += pendingClicksChunk {
+		ids := urlIDs[start:min(start+pendingClicksChunk, len(urlIDs))]
+		if err := r.getPendingClicks(ctx, ids, counts); err != nil {
+			return nil, err
+		}
+	}
+	return counts, nil
 }
 
-func (r *URLCacheRepository) IncrementClickCount(ctx context.Context, shortCode string) (int64, error) {
-	key := clickKeyPrefix + shortCode
-	count, err := r.client.Incr(ctx, key).Result()
+// getPendingClicks adds the counts of urlIDs to counts. The script is given
+// the keys of the batches taken, as it may only touch keys it is given.
+func (r *URLCacheRepository) getPendingClicks(ctx context.Context, urlIDs []string, counts map[string]int64) error {
+	args := make([]any, 0, len(urlIDs)+1)
+	args = append(args, clickBatchPrefix)
+	for _, id := range urlIDs {
+		args = append(args, id)
+	}
+
+	for range pendingClicksRetries {
+		batchIDs, err := r.client.ZRange(ctx, clickBatchesKey, 0, -1).Result()
+		if err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(batchIDs)+2)
+		keys = append(keys, pendingClicksKey, clickBatchesKey)
+		for _, id := range batchIDs {
+			keys = append(keys, clickBatchPrefix+id)
+		}
+
+		values, err := getPendingScript.Run(ctx, r.client, keys, args...).Int64Slice()
+		if err == redis.Nil {
+			continue // a batch was taken or done meanwhile
+		}
+		if err != nil {
+			return err
+		}
+
+		for i, count := range values {
+			if count < 0 {
+				continue // not counted
+			}
+			counts[urlIDs[i]] = count
+		}
+		return nil
+	}
+
+	return errBatchesChanged
+}
+
+func (r *URLCacheRepository) TakePendingClicks(ctx context.Context) (*repository.PendingClickBatch, error) {
+	id := uuid.NewString()
+	keys := []string{pendingClicksKey, clickBatchesKey, clickBatchPrefix + id}
+
+	taken, err := takeScript.Run(ctx, r.client, keys, time.Now().UnixMilli(), id).Bool()
+	if err != nil || !taken {
+		return nil, err
+	}
+
+	return r.getBatch(ctx, id)
+}
+
+func (r *URLCacheRepository) DonePendingClicks(ctx context.Context, batchID string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, clickBatchPrefix+batchID)
+		pipe.ZRem(ctx, clickBatchesKey, batchID)
+		return nil
+	})
+	return err
+}
+
+func (r *URLCacheRepository) ReturnPendingClicks(ctx context.Context, batchID string) error {
+	keys := []string{pendingClicksKey, clickBatchesKey, clickBatchPrefix + batchID}
+	return returnScript.Run(ctx, r.client, keys, batchID).Err()
+}
+
+func (r *URLCacheRepository) ClaimPendingClicks(ctx context.Context, olderThan time.Duration) ([]*repository.PendingClickBatch, error) {
+	now := time.Now()
+	ids, err := claimScript.Run(ctx, r.client, []string{clickBatchesKey}, now.Add(-olderThan).UnixMilli(), now.UnixMilli()).StringSlice()
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	// Set TTL if this is the first increment
-	if count == 1 {
-		r.client.Expire(ctx, key, 24*time.Hour)
+	batches := make([]*repository.PendingClickBatch, 0, len(ids))
+	for _, id := range ids {
+		batch, err := r.getBatch(ctx, id)
+		if err != nil {
+			return batches, err
+		}
+		batches = append(batches, batch)
 	}
 
-	return count, nil
+	return batches, nil
 }
 
-func (r *URLCacheRepository) GetClickCount(ctx context.Context, shortCode string) (int64, error) {
-	key := clickKeyPrefix + shortCode
-	count, err := r.client.Get(ctx, key).Int64()
+func (r *URLCacheRepository) getBatch(ctx context.Context, id string) (*repository.PendingClickBatch, error) {
+	values, err := r.client.HGetAll(ctx, clickBatchPrefix+id).Result()
 	if err != nil {
-		if err == redis.Nil {
-			return 0, nil
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(values))
+	for urlID, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, err
 		}
-		return 0, err
+		counts[urlID] = count
 	}
-	return count, nil
+
+	return &repository.PendingClickBatch{ID: id, Counts: counts}, nil
 }
 
 func NewRedisClient(host, port, password string, db int) (*redis.Client, error) {
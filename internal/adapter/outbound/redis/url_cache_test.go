@@ -0,0 +1,147 @@
+package redis
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/internal/domain/repository/repositorytest"
+)
+
+// newTestClient connects to the Redis at TEST_REDIS_ADDR, or to an
+// in-process server when it is unset, and empties its database.
+func newTestClient(t *testing.T) *redis.Client {
+	t.Helper()
+	client, _ := newTestClientWithClock(t)
+	return client
+}
+
+// newTestClientWithClock is newTestClient that also returns how to let keys
+// expire: the in-process server only expires them when told time passed.
+func newTestClientWithClock(t *testing.T) (*redis.Client, repositorytest.Advance) {
+	t.Helper()
+
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	advance := time.Sleep
+	if addr == "" {
+		server := miniredis.RunT(t)
+		addr, advance = server.Addr(), server.FastForward
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.FlushDB(t.Context()).Err(); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	return client, advance
+}
+
+func TestURLCacheContract(t *testing.T) {
+	repositorytest.RunURLCache(t, func(t *testing.T) (repository.URLCacheRepository, repositorytest.Advance) {
+		client, advance := newTestClientWithClock(t)
+		return NewURLCacheRepository(client, time.Hour), advance
+	})
+}
+
+// Every field of a link must survive the cache, or a cache hit silently
+// drops it. Fields added to entity.URL fail here until cachedURL has them.
+func TestCachedURLKeepsEveryField(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+
+	var url entity.URL
+	v := reflect.ValueOf(&url).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		switch field.Interface().(type) {
+		case string:
+			field.SetString(v.Type().Field(i).Name)
+		case bool:
+			field.SetBool(true)
+		case int, int64:
+			field.SetInt(42)
+		case time.Time:
+			field.Set(reflect.ValueOf(now))
+		case *time.Time:
+			field.Set(reflect.ValueOf(&now))
+		case []entity.TargetingRule:
+			field.Set(reflect.ValueOf([]entity.TargetingRule{{ID: "rule-1", Device: "Mobile", OS: "iOS", Country: "DE", URL: "https://example.com/ios"}}))
+		default:
+			t.Fatalf("no test value for field %s of type %s", v.Type().Field(i).Name, field.Type())
+		}
+	}
+
+	data, err := encodeURL(&url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := decodeURL(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, &url) {
+		t.Errorf("round trip = %+v, want %+v", got, &url)
+	}
+}
+
+func TestURLCacheIgnoresOtherFormats(t *testing.T) {
+	ctx := context.Background()
+	client := newTestClient(t)
+	cache := NewURLCacheRepository(client, time.Hour)
+
+	// Written by a version that cached entity.URL's API JSON, which has no
+	// password hash
+	legacy := `{"id":"url-1","short_code":"secret","original_url":"https://example.com","is_active":true}`
+	if err := client.Set(ctx, urlKeyPrefix+"secret", legacy, time.Hour).Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := cache.Get(ctx, "secret"); got != nil || err != nil {
+		t.Errorf("Get() = %v, %v, want a miss", got, err)
+	}
+}
+
+// More IDs than Lua's unpack takes at once are counted in chunks
+func TestGetPendingClicksManyIDs(t *testing.T) {
+	ctx := context.Background()
+	cache := NewURLCacheRepository(newTestClient(t), time.Hour)
+
+	taken := make(map[string]int64)
+	pending := make(map[string]int64)
+	ids := make([]string, 0, 10000)
+	for i := range 10000 {
+		id := "url-" + strconv.Itoa(i)
+		ids = append(ids, id)
+		taken[id] = 1
+		if i%2 == 0 {
+			pending[id] = 2
+		}
+	}
+
+	if err := cache.AddPendingClicks(ctx, taken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.TakePendingClicks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := cache.AddPendingClicks(ctx, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := cache.GetPendingClicks(ctx, ids)
+	if err != nil {
+		t.Fatalf("GetPendingClicks() error = %v", err)
+	}
+	if len(got) != len(ids) || got["url-0"] != 3 || got["url-1"] != 1 || got["url-9998"] != 3 || got["url-9999"] != 1 {
+		t.Errorf("GetPendingClicks() = %d counts, url-0 %d, url-1 %d, url-9998 %d, url-9999 %d, want all with 3 for even and 1 for odd IDs",
+			len(got), got["url-0"], got["url-1"], got["url-9998"], got["url-9999"])
+	}
+}
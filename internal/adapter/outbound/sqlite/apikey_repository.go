@@ -0,0 +1,214 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.APIKeyRepository = (*APIKeyRepository)(nil)
+
+const apiKeyColumns = `id, key_hash, key_prefix, name, user_id, workspace_id, scopes, rate_limit, expires_at, created_at, last_used, is_active, replaced_by`
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+func (r *APIKeyRepository) Create(ctx context.Context, key *entity.APIKey) error {
+	return insertAPIKey(ctx, r.db, key)
+}
+
+func insertAPIKey(ctx context.Context, db execer, key *entity.APIKey) error {
+	if key.ID == "" {
+		key.ID = uuid.New().String()
+	}
+	key.CreatedAt = now()
+	key.IsActive = true
+
+	scopesJSON, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO api_keys (id, key_hash, key_prefix, name, user_id, workspace_id, scopes, rate_limit, expires_at, created_at, is_active)
+		VALUES (?1, ?2, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11)
+	`
+
+	_, err = db.ExecContext(ctx, query,
+		key.ID,
+		key.KeyHash,
+		key.KeyPrefix,
+		key.Name,
+		key.UserID,
+		nullString(key.WorkspaceID),
+		scopesJSON,
+		key.RateLimit,
+		nullTime(key.ExpiresAt),
+		key.CreatedAt,
+		key.IsActive,
+	)
+
+	return err
+}
+
+func (r *APIKeyRepository) GetByKeyHash(ctx context.Context, keyHash string) (*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = ?1`
+
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+func (r *APIKeyRepository) GetByID(ctx context.Context, id string) (*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = ?1`
+
+	apiKey, err := scanAPIKey(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+func (r *APIKeyRepository) GetByUserID(ctx context.Context, userID string) ([]*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = ?1 ORDER BY created_at DESC`
+	return r.list(ctx, query, userID)
+}
+
+func (r *APIKeyRepository) GetByWorkspaceID(ctx context.Context, workspaceID string) ([]*entity.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE workspace_id = ?1 ORDER BY created_at DESC`
+	return r.list(ctx, query, workspaceID)
+}
+
+func (r *APIKeyRepository) list(ctx context.Context, query string, args ...any) ([]*entity.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*entity.APIKey
+	for rows.Next() {
+		apiKey, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, apiKey)
+	}
+
+	return keys, rows.Err()
+}
+
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET last_used = ?2 WHERE id = ?1`
+	_, err := r.db.ExecContext(ctx, query, id, now())
+	return err
+}
+
+func (r *APIKeyRepository) Delete(ctx context.Context, id string) error {
+	query := `DELETE FROM api_keys WHERE id = ?1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+func (r *APIKeyRepository) Deactivate(ctx context.Context, id string) error {
+	query := `UPDATE api_keys SET is_active = false WHERE id = ?1`
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// Rotate inserts the new key first, then replaces the old one only if no
+// other rotation got to it.
+func (r *APIKeyRepository) Rotate(ctx context.Context, id string, newKey *entity.APIKey, expiresAt time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := insertAPIKey(ctx, tx, newKey); err != nil {
+		return err
+	}
+
+	query := `UPDATE api_keys SET replaced_by = ?2, expires_at = ?3 WHERE id = ?1 AND replaced_by IS NULL`
+	result, err := tx.ExecContext(ctx, query, id, newKey.ID, expiresAt.UTC())
+	if err != nil {
+		return err
+	}
+	replaced, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if replaced == 0 {
+		return repository.ErrAPIKeyReplaced
+	}
+
+	return tx.Commit()
+}
+
+// execer runs statements on the database or in a transaction.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func scanAPIKey(row rowScanner) (*entity.APIKey, error) {
+	apiKey := &entity.APIKey{}
+	var scopesJSON []byte
+	var expiresAt, lastUsed sql.NullTime
+	var workspaceID, replacedBy sql.NullString
+
+	err := row.Scan(
+		&apiKey.ID,
+		&apiKey.KeyHash,
+		&apiKey.KeyPrefix,
+		&apiKey.Name,
+		&apiKey.UserID,
+		&workspaceID,
+		&scopesJSON,
+		&apiKey.RateLimit,
+		&expiresAt,
+		&apiKey.CreatedAt,
+		&lastUsed,
+		&apiKey.IsActive,
+		&replacedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	apiKey.WorkspaceID = workspaceID.String
+	apiKey.ReplacedBy = replacedBy.String
+
+	if err := json.Unmarshal(scopesJSON, &apiKey.Scopes); err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		apiKey.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsed.Valid {
+		apiKey.LastUsed = &lastUsed.Time
+	}
+
+	return apiKey, nil
+}
@@ -0,0 +1,8 @@
+DROP TABLE IF EXISTS audit_events;
+DROP TABLE IF EXISTS workspace_members;
+DROP TABLE IF EXISTS workspaces;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS url_revisions;
+DROP TABLE IF EXISTS api_keys;
+DROP TABLE IF EXISTS clicks;
+DROP TABLE IF EXISTS urls;
@@ -0,0 +1,117 @@
+-- SQLite has no native time type. Timestamps are stored as UTC text, which
+-- sorts and compares in time order.
+
+CREATE TABLE IF NOT EXISTS urls (
+	id TEXT PRIMARY KEY,
+	short_code TEXT UNIQUE NOT NULL,
+	original_url TEXT NOT NULL,
+	custom_alias TEXT UNIQUE,
+	user_id TEXT,
+	workspace_id TEXT,
+	expires_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	click_count INTEGER NOT NULL DEFAULT 0,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	password_hash TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_urls_user_id ON urls(user_id);
+CREATE INDEX IF NOT EXISTS idx_urls_workspace_id ON urls(workspace_id);
+CREATE INDEX IF NOT EXISTS idx_urls_created_at ON urls(created_at);
+
+CREATE TABLE IF NOT EXISTS clicks (
+	id TEXT PRIMARY KEY,
+	url_id TEXT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+	short_code TEXT NOT NULL,
+	ip_address TEXT,
+	user_agent TEXT,
+	referrer TEXT,
+	country TEXT,
+	city TEXT,
+	device TEXT,
+	browser TEXT,
+	os TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_clicks_url_id ON clicks(url_id, created_at);
+
+CREATE TABLE IF NOT EXISTS api_keys (
+	id TEXT PRIMARY KEY,
+	key_hash TEXT UNIQUE NOT NULL,
+	key_prefix TEXT NOT NULL DEFAULT '',
+	name TEXT NOT NULL,
+	user_id TEXT NOT NULL,
+	workspace_id TEXT,
+	scopes TEXT NOT NULL DEFAULT '[]',
+	rate_limit INTEGER NOT NULL DEFAULT 100,
+	expires_at TIMESTAMP,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	last_used TIMESTAMP,
+	is_active BOOLEAN NOT NULL DEFAULT TRUE,
+	replaced_by TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys(user_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_workspace_id ON api_keys(workspace_id);
+
+CREATE TABLE IF NOT EXISTS url_revisions (
+	id TEXT PRIMARY KEY,
+	url_id TEXT NOT NULL REFERENCES urls(id) ON DELETE CASCADE,
+	revision INTEGER NOT NULL,
+	action TEXT NOT NULL,
+	short_code TEXT NOT NULL,
+	original_url TEXT NOT NULL,
+	custom_alias TEXT,
+	expires_at TIMESTAMP,
+	is_active BOOLEAN NOT NULL,
+	password_hash TEXT,
+	changed_by TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	UNIQUE (url_id, revision)
+);
+
+CREATE TABLE IF NOT EXISTS users (
+	id TEXT PRIMARY KEY,
+	email TEXT UNIQUE NOT NULL,
+	name TEXT,
+	password_hash TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS workspaces (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	created_by TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS workspace_members (
+	workspace_id TEXT NOT NULL REFERENCES workspaces(id) ON DELETE CASCADE,
+	user_id TEXT NOT NULL,
+	role TEXT NOT NULL,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (workspace_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_workspace_members_user_id ON workspace_members(user_id);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	actor_user_id TEXT,
+	actor_api_key_id TEXT,
+	action TEXT NOT NULL,
+	target_type TEXT NOT NULL,
+	target_id TEXT NOT NULL,
+	workspace_id TEXT,
+	ip_address TEXT,
+	changes TEXT,
+	created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_actor_user_id ON audit_events(actor_user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_workspace_id ON audit_events(workspace_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_audit_events_target ON audit_events(target_type, target_id);
@@ -0,0 +1,2 @@
+ALTER TABLE url_revisions DROP COLUMN redirect_type;
+ALTER TABLE urls DROP COLUMN redirect_type;
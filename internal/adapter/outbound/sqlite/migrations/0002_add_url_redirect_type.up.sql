@@ -0,0 +1,3 @@
+-- 0 follows the server's default redirect type
+ALTER TABLE urls ADD COLUMN redirect_type INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE url_revisions ADD COLUMN redirect_type INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+ALTER TABLE url_revisions DROP COLUMN targeting_rules;
+ALTER TABLE urls DROP COLUMN targeting_rules;
@@ -0,0 +1,3 @@
+-- JSON array of rules, NULL when the link has none
+ALTER TABLE urls ADD COLUMN targeting_rules TEXT;
+ALTER TABLE url_revisions ADD COLUMN targeting_rules TEXT;
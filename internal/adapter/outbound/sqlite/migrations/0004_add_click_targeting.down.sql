@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN targeting_rule_id;
+ALTER TABLE clicks DROP COLUMN country_code;
@@ -0,0 +1,2 @@
+ALTER TABLE clicks ADD COLUMN country_code TEXT NOT NULL DEFAULT '';
+ALTER TABLE clicks ADD COLUMN targeting_rule_id TEXT NOT NULL DEFAULT '';
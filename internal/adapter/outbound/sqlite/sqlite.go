@@ -0,0 +1,73 @@
+// Package sqlite stores the shortener's data in a single SQLite file, for
+// deployments that should not depend on a database server.
+package sqlite
+
+import (
+	"database/sql"
+	"embed"
+	"io/fs"
+	"net/url"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"github.com/bimakw/url-shortener/pkg/migrate"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// SQLite serializes writers on its own, so migrations need no extra lock.
+var dialect = migrate.Dialect{
+	CreateTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	Insert: `INSERT INTO schema_migrations (version, name) VALUES (?, ?)`,
+	Delete: `DELETE FROM schema_migrations WHERE version = ?`,
+}
+
+// Open opens the database file at path, creating it if needed.
+func Open(path string) (*sql.DB, error) {
+	params := url.Values{}
+	params.Set("_time_format", "sqlite")
+	params.Set("_txlock", "immediate")
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Add("_pragma", "journal_mode(WAL)")
+
+	return sql.Open("sqlite", "file:"+path+"?"+params.Encode())
+}
+
+func NewMigrator(db *sql.DB) (*migrate.Migrator, error) {
+	fsys, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, fsys, dialect)
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// now returns the current time in UTC. Timestamps are stored as text, so
+// they must all be in the same zone to compare correctly.
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func nullString(s string) sql.NullString {
+	if s == "" {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: s, Valid: true}
+}
+
+func nullTime(t *time.Time) sql.NullTime {
+	if t == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: t.UTC(), Valid: true}
+}
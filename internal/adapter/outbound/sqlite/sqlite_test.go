@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository/repositorytest"
+)
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Up() error = %v", err)
+	}
+
+	return db
+}
+
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	db := openTestDB(t)
+
+	migrator, err := NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Running again is a no-op
+	applied, err := migrator.Up(ctx)
+	if err != nil || len(applied) != 0 {
+		t.Fatalf("second Up() = %d applied, %v", len(applied), err)
+	}
+
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		t.Fatalf("Status() error = %v", err)
+	}
+	for _, s := range statuses {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %04d_%s is pending after Up()", s.Version, s.Name)
+		}
+	}
+
+	reverted, err := migrator.Down(ctx, len(statuses))
+	if err != nil || len(reverted) != len(statuses) {
+		t.Fatalf("Down() = %d reverted, %v", len(reverted), err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Up() after Down() error = %v", err)
+	}
+}
+
+func TestContract(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+		db := openTestDB(t)
+		return repositorytest.Repositories{
+			URLs:       NewURLRepository(db),
+			Clicks:     NewClickRepository(db),
+			APIKeys:    NewAPIKeyRepository(db),
+			Users:      NewUserRepository(db),
+			Revisions:  NewURLRevisionRepository(db),
+			Workspaces: NewWorkspaceRepository(db),
+			Audit:      NewAuditRepository(db),
+		}
+	})
+}
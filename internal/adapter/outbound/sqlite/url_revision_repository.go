@@ -0,0 +1,186 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/google/uuid"
+)
+
+var _ repository.URLRevisionRepository = (*URLRevisionRepository)(nil)
+
+type URLRevisionRepository struct {
+	db *sql.DB
+}
+
+func NewURLRevisionRepository(db *sql.DB) *URLRevisionRepository {
+	return &URLRevisionRepository{db: db}
+}
+
+func (r *URLRevisionRepository) Create(ctx context.Context, rev *entity.URLRevision) error {
+	return insertURLRevision(ctx, r.db, rev)
+}
+
+// Edit runs in a transaction, which SQLite begins by taking the write lock,
+// so no other edit can come between reading the link and saving its revision.
+func (r *URLRevisionRepository) Edit(ctx context.Context, urlID, action, changedBy string, edit func(url *entity.URL) error) (*entity.URL, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	url, err := scanURL(tx.QueryRowContext(ctx, `SELECT `+urlColumns+` FROM urls WHERE id = ?1`, urlID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := edit(url); err != nil {
+		return nil, err
+	}
+
+	if err := updateURL(ctx, tx, url); err != nil {
+		return nil, err
+	}
+	if err := insertURLRevision(ctx, tx, entity.NewURLRevision(url, action, changedBy)); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return url, nil
+}
+
+// rowQueryer is a database or a transaction.
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func insertURLRevision(ctx context.Context, db rowQueryer, rev *entity.URLRevision) error {
+	rules, err := encodeTargetingRules(rev.TargetingRules)
+	if err != nil {
+		return err
+	}
+
+	if rev.ID == "" {
+		rev.ID = uuid.New().String()
+	}
+	rev.CreatedAt = now()
+
+	// Revision numbers are sequential per URL. SQLite runs one write at a
+	// time, so two edits cannot pick the same number.
+	query := `
+		INSERT INTO url_revisions (id, url_id, revision, action, short_code, original_url, custom_alias, expires_at, is_active, password_hash, redirect_type, changed_by, created_at, targeting_rules)
+		SELECT ?1, ?2, COALESCE(MAX(revision), 0) + 1, ?3, ?4, ?5, ?6, ?7, ?8, ?9, ?10, ?11, ?12, ?13
+		FROM url_revisions
+		WHERE url_id = ?2
+		RETURNING revision
+	`
+
+	return db.QueryRowContext(ctx, query,
+		rev.ID,
+		rev.URLID,
+		rev.Action,
+		rev.ShortCode,
+		rev.OriginalURL,
+		nullString(rev.CustomAlias),
+		nullTime(rev.ExpiresAt),
+		rev.IsActive,
+		nullString(rev.PasswordHash),
+		rev.RedirectType,
+		nullString(rev.ChangedBy),
+		rev.CreatedAt,
+		rules,
+	).Scan(&rev.Revision)
+}
+
+func (r *URLRevisionRepository) GetByURLID(ctx context.Context, urlID string) ([]*entity.URLRevision, error) {
+	query := `
+		SELECT id, url_id, revision, action, short_code, original_url, custom_alias, expires_at, is_active, password_hash, redirect_type, changed_by, created_at, targeting_rules
+		FROM url_revisions
+		WHERE url_id = ?1
+		ORDER BY revision DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, urlID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*entity.URLRevision
+	for rows.Next() {
+		rev, err := scanURLRevision(rows)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+
+	return revisions, rows.Err()
+}
+
+func (r *URLRevisionRepository) GetByRevision(ctx context.Context, urlID string, revision int) (*entity.URLRevision, error) {
+	query := `
+		SELECT id, url_id, revision, action, short_code, original_url, custom_alias, expires_at, is_active, password_hash, redirect_type, changed_by, created_at, targeting_rules
+		FROM url_revisions
+		WHERE url_id = ?1 AND revision = ?2
+	`
+
+	rev, err := scanURLRevision(r.db.QueryRowContext(ctx, query, urlID, revision))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return rev, nil
+}
+
+func scanURLRevision(row rowScanner) (*entity.URLRevision, error) {
+	rev := &entity.URLRevision{}
+	var customAlias, passwordHash, changedBy sql.NullString
+	var expiresAt sql.NullTime
+	var rules []byte
+
+	err := row.Scan(
+		&rev.ID,
+		&rev.URLID,
+		&rev.Revision,
+		&rev.Action,
+		&rev.ShortCode,
+		&rev.OriginalURL,
+		&customAlias,
+		&expiresAt,
+		&rev.IsActive,
+		&passwordHash,
+		&rev.RedirectType,
+		&changedBy,
+		&rev.CreatedAt,
+		&rules,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rev.CustomAlias = customAlias.String
+	rev.PasswordHash = passwordHash.String
+	rev.ChangedBy = changedBy.String
+	rev.TargetingRules, err = decodeTargetingRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		rev.ExpiresAt = &expiresAt.Time
+	}
+
+	return rev, nil
+}
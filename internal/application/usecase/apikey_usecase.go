@@ -3,6 +3,7 @@ package usecase
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"time"
@@ -15,34 +16,83 @@ var (
 	ErrAPIKeyNotFound = errors.New("api key not found")
 	ErrAPIKeyExpired  = errors.New("api key has expired")
 	ErrAPIKeyInactive = errors.New("api key is inactive")
+	ErrAPIKeyRotated  = errors.New("api key has already been rotated")
+	ErrScopeNotHeld   = errors.New("api key cannot grant a scope it does not have")
+	ErrRateLimitHigh  = errors.New("api key rate limit is above the maximum")
+)
+
+// apiKeyPrefixLength is how much of a key is kept in plaintext so users can
+// tell their keys apart ("sk_" plus 8 hex characters).
+const apiKeyPrefixLength = 11
+
+const (
+	defaultRotationGrace = 24 * time.Hour
+	defaultKeyRateLimit  = 100
+	defaultMaxRateLimit  = 1000
 )
 
 type APIKeyUseCase struct {
-	apiKeyRepo repository.APIKeyRepository
+	apiKeyRepo    repository.APIKeyRepository
+	workspaceRepo repository.WorkspaceRepository
+	audit         auditLog
+	rotationGrace time.Duration
+	maxRateLimit  int
+}
+
+type APIKeyUseCaseConfig struct {
+	APIKeyRepo    repository.APIKeyRepository
+	WorkspaceRepo repository.WorkspaceRepository
+	AuditRepo     repository.AuditRepository
+	RotationGrace time.Duration
+	// MaxRateLimit caps the requests per second a key may be given.
+	MaxRateLimit int
 }
 
-func NewAPIKeyUseCase(repo repository.APIKeyRepository) *APIKeyUseCase {
-	return &APIKeyUseCase{apiKeyRepo: repo}
+func NewAPIKeyUseCase(cfg APIKeyUseCaseConfig) *APIKeyUseCase {
+	if cfg.RotationGrace <= 0 {
+		cfg.RotationGrace = defaultRotationGrace
+	}
+	if cfg.MaxRateLimit <= 0 {
+		cfg.MaxRateLimit = defaultMaxRateLimit
+	}
+	return &APIKeyUseCase{
+		apiKeyRepo:    cfg.APIKeyRepo,
+		workspaceRepo: cfg.WorkspaceRepo,
+		audit:         auditLog{repo: cfg.AuditRepo},
+		rotationGrace: cfg.RotationGrace,
+		maxRateLimit:  cfg.MaxRateLimit,
+	}
 }
 
 func (uc *APIKeyUseCase) CreateAPIKey(ctx context.Context, userID string, req entity.CreateAPIKeyRequest) (*entity.APIKeyResponse, error) {
-	// Generate secure API key
-	keyBytes := make([]byte, 32)
-	if _, err := rand.Read(keyBytes); err != nil {
-		return nil, err
+	if !inKeyWorkspace(ctx, req.WorkspaceID) {
+		return nil, ErrUnauthorized
+	}
+
+	// Workspace keys act on behalf of the whole workspace
+	if req.WorkspaceID != "" {
+		if _, err := requireRole(ctx, uc.workspaceRepo, req.WorkspaceID, userID, entity.RoleAdmin); err != nil {
+			return nil, err
+		}
 	}
-	key := "sk_" + hex.EncodeToString(keyBytes)
 
-	// Set default scopes if not provided
+	// Set default scopes if not provided, as far as the key making the
+	// request has them
 	scopes := req.Scopes
 	if len(scopes) == 0 {
-		scopes = entity.DefaultScopes
+		scopes = heldScopes(ctx, entity.DefaultScopes)
+	}
+	if len(scopes) == 0 || !holdsScopes(ctx, scopes) {
+		return nil, ErrScopeNotHeld
 	}
 
 	// Set default rate limit
 	rateLimit := req.RateLimit
 	if rateLimit <= 0 {
-		rateLimit = 100
+		rateLimit = min(defaultKeyRateLimit, uc.maxRateLimit)
+	}
+	if rateLimit > uc.maxRateLimit {
+		return nil, ErrRateLimitHigh
 	}
 
 	// Calculate expiry
@@ -53,33 +103,99 @@ func (uc *APIKeyUseCase) CreateAPIKey(ctx context.Context, userID string, req en
 	}
 
 	apiKey := &entity.APIKey{
-		Key:       key,
-		Name:      req.Name,
-		UserID:    userID,
-		Scopes:    scopes,
-		RateLimit: rateLimit,
-		ExpiresAt: expiresAt,
-		IsActive:  true,
+		Name:        req.Name,
+		UserID:      userID,
+		WorkspaceID: req.WorkspaceID,
+		Scopes:      scopes,
+		RateLimit:   rateLimit,
+		ExpiresAt:   expiresAt,
+		IsActive:    true,
 	}
 
-	if err := uc.apiKeyRepo.Create(ctx, apiKey); err != nil {
+	key, err := uc.issueKey(ctx, apiKey)
+	if err != nil {
 		return nil, err
 	}
 
-	return &entity.APIKeyResponse{
-		ID:        apiKey.ID,
-		Key:       key, // Only show key on creation
-		Name:      apiKey.Name,
-		Scopes:    apiKey.Scopes,
-		RateLimit: apiKey.RateLimit,
-		ExpiresAt: apiKey.ExpiresAt,
-		CreatedAt: apiKey.CreatedAt,
-		IsActive:  apiKey.IsActive,
-	}, nil
+	uc.audit.record(ctx, userID, entity.AuditActionAPIKeyCreate, entity.AuditTargetAPIKey, apiKey.ID, apiKey.WorkspaceID, nil, apiKey)
+
+	response := toAPIKeyResponse(apiKey)
+	response.Key = key // Only show key on creation
+	return response, nil
+}
+
+// RotateAPIKey issues a new secret with the same settings as the given key.
+// The old secret keeps working until the grace period ends.
+func (uc *APIKeyUseCase) RotateAPIKey(ctx context.Context, id, userID string, req entity.RotateAPIKeyRequest) (*entity.APIKeyResponse, error) {
+	oldKey, err := uc.getAuthorizedKey(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if oldKey.ReplacedBy != "" {
+		return nil, ErrAPIKeyRotated
+	}
+	if !oldKey.IsActive {
+		return nil, ErrAPIKeyInactive
+	}
+	if oldKey.IsExpired() {
+		return nil, ErrAPIKeyExpired
+	}
+	if !holdsScopes(ctx, oldKey.Scopes) {
+		return nil, ErrScopeNotHeld
+	}
+
+	grace := uc.rotationGrace
+	if req.GracePeriod != nil {
+		grace = time.Duration(*req.GracePeriod) * time.Hour
+	}
+
+	newKey := &entity.APIKey{
+		Name:        oldKey.Name,
+		UserID:      oldKey.UserID,
+		WorkspaceID: oldKey.WorkspaceID,
+		Scopes:      oldKey.Scopes,
+		RateLimit:   oldKey.RateLimit,
+		ExpiresAt:   oldKey.ExpiresAt,
+		IsActive:    true,
+	}
+
+	key, err := newSecret(newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The old key never outlives its original expiry. Without a grace
+	// period it ends now, and a replaced key that has ended is inactive.
+	graceEnd := time.Now().Add(grace)
+	if oldKey.ExpiresAt != nil && oldKey.ExpiresAt.Before(graceEnd) {
+		graceEnd = *oldKey.ExpiresAt
+	}
+
+	before := *oldKey
+
+	if err := uc.apiKeyRepo.Rotate(ctx, oldKey.ID, newKey, graceEnd); err != nil {
+		if errors.Is(err, repository.ErrAPIKeyReplaced) {
+			return nil, ErrAPIKeyRotated
+		}
+		return nil, err
+	}
+	oldKey.ReplacedBy = newKey.ID
+	oldKey.ExpiresAt = &graceEnd
+
+	uc.audit.record(ctx, userID, entity.AuditActionAPIKeyRotate, entity.AuditTargetAPIKey, oldKey.ID, oldKey.WorkspaceID, &before, oldKey)
+
+	response := toAPIKeyResponse(newKey)
+	response.Key = key // Only show key on creation
+	response.PreviousKey = toAPIKeyResponse(oldKey)
+	return response, nil
 }
 
 func (uc *APIKeyUseCase) ValidateAPIKey(ctx context.Context, key string) (*entity.APIKey, error) {
-	apiKey, err := uc.apiKeyRepo.GetByKey(ctx, key)
+	// Keys are looked up by their digest, so how long the lookup takes tells
+	// nothing about a stored secret and there is nothing left to compare in
+	// constant time
+	apiKey, err := uc.apiKeyRepo.GetByKeyHash(ctx, hashAPIKey(key))
 	if err != nil {
 		return nil, err
 	}
@@ -91,7 +207,11 @@ func (uc *APIKeyUseCase) ValidateAPIKey(ctx context.Context, key string) (*entit
 		return nil, ErrAPIKeyInactive
 	}
 
-	if apiKey.ExpiresAt != nil && time.Now().After(*apiKey.ExpiresAt) {
+	if apiKey.IsExpired() {
+		// A rotated key is switched off for good once its grace period ends
+		if apiKey.ReplacedBy != "" {
+			return nil, ErrAPIKeyInactive
+		}
 		return nil, ErrAPIKeyExpired
 	}
 
@@ -104,6 +224,10 @@ func (uc *APIKeyUseCase) ValidateAPIKey(ctx context.Context, key string) (*entit
 }
 
 func (uc *APIKeyUseCase) GetUserAPIKeys(ctx context.Context, userID string) ([]*entity.APIKeyResponse, error) {
+	if !inKeyWorkspace(ctx, "") {
+		return nil, ErrUnauthorized
+	}
+
 	keys, err := uc.apiKeyRepo.GetByUserID(ctx, userID)
 	if err != nil {
 		return nil, err
@@ -111,51 +235,166 @@ func (uc *APIKeyUseCase) GetUserAPIKeys(ctx context.Context, userID string) ([]*
 
 	responses := make([]*entity.APIKeyResponse, len(keys))
 	for i, key := range keys {
-		responses[i] = &entity.APIKeyResponse{
-			ID:        key.ID,
-			Name:      key.Name,
-			Scopes:    key.Scopes,
-			RateLimit: key.RateLimit,
-			ExpiresAt: key.ExpiresAt,
-			CreatedAt: key.CreatedAt,
-			LastUsed:  key.LastUsed,
-			IsActive:  key.IsActive,
-		}
+		responses[i] = toAPIKeyResponse(key)
+	}
+
+	return responses, nil
+}
+
+func (uc *APIKeyUseCase) GetWorkspaceAPIKeys(ctx context.Context, workspaceID, userID string) ([]*entity.APIKeyResponse, error) {
+	if _, err := requireRole(ctx, uc.workspaceRepo, workspaceID, userID, entity.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	keys, err := uc.apiKeyRepo.GetByWorkspaceID(ctx, workspaceID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*entity.APIKeyResponse, len(keys))
+	for i, key := range keys {
+		responses[i] = toAPIKeyResponse(key)
 	}
 
 	return responses, nil
 }
 
 func (uc *APIKeyUseCase) RevokeAPIKey(ctx context.Context, id, userID string) error {
-	apiKey, err := uc.apiKeyRepo.GetByID(ctx, id)
+	apiKey, err := uc.getAuthorizedKey(ctx, id, userID)
 	if err != nil {
 		return err
 	}
-	if apiKey == nil {
-		return ErrAPIKeyNotFound
-	}
 
-	// Check ownership
-	if apiKey.UserID != userID {
-		return errors.New("unauthorized")
+	if err := uc.apiKeyRepo.Deactivate(ctx, id); err != nil {
+		return err
 	}
 
-	return uc.apiKeyRepo.Deactivate(ctx, id)
+	before := *apiKey
+	apiKey.IsActive = false
+	uc.audit.record(ctx, userID, entity.AuditActionAPIKeyRevoke, entity.AuditTargetAPIKey, apiKey.ID, apiKey.WorkspaceID, &before, apiKey)
+	return nil
 }
 
 func (uc *APIKeyUseCase) DeleteAPIKey(ctx context.Context, id, userID string) error {
-	apiKey, err := uc.apiKeyRepo.GetByID(ctx, id)
+	apiKey, err := uc.getAuthorizedKey(ctx, id, userID)
 	if err != nil {
 		return err
 	}
+
+	if err := uc.apiKeyRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionAPIKeyDelete, entity.AuditTargetAPIKey, apiKey.ID, apiKey.WorkspaceID, apiKey, nil)
+	return nil
+}
+
+// getAuthorizedKey loads a key the user may manage: one they created, or any
+// key of a workspace they administer.
+func (uc *APIKeyUseCase) getAuthorizedKey(ctx context.Context, id, userID string) (*entity.APIKey, error) {
+	apiKey, err := uc.apiKeyRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
 	if apiKey == nil {
-		return ErrAPIKeyNotFound
+		return nil, ErrAPIKeyNotFound
+	}
+	if !inKeyWorkspace(ctx, apiKey.WorkspaceID) {
+		return nil, ErrUnauthorized
+	}
+
+	if apiKey.UserID == userID {
+		return apiKey, nil
+	}
+	if apiKey.WorkspaceID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	if _, err := requireRole(ctx, uc.workspaceRepo, apiKey.WorkspaceID, userID, entity.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return apiKey, nil
+}
+
+// holdsScopes reports whether the API key making the request has all of
+// scopes, so it can hand them to a key it manages. Requests made without an
+// API key may grant any.
+func holdsScopes(ctx context.Context, scopes []string) bool {
+	actor := ActorFromContext(ctx)
+	if actor.APIKeyID == "" {
+		return true
+	}
+	for _, scope := range scopes {
+		if !entity.HasScope(actor.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// heldScopes returns those of scopes the API key making the request has.
+func heldScopes(ctx context.Context, scopes []string) []string {
+	actor := ActorFromContext(ctx)
+	if actor.APIKeyID == "" {
+		return scopes
+	}
+	var held []string
+	for _, scope := range scopes {
+		if entity.HasScope(actor.Scopes, scope) {
+			held = append(held, scope)
+		}
+	}
+	return held
+}
+
+// issueKey generates a new secret for apiKey, stores its digest and returns
+// the plaintext secret, which is never persisted.
+func (uc *APIKeyUseCase) issueKey(ctx context.Context, apiKey *entity.APIKey) (string, error) {
+	key, err := newSecret(apiKey)
+	if err != nil {
+		return "", err
 	}
 
-	// Check ownership
-	if apiKey.UserID != userID {
-		return errors.New("unauthorized")
+	if err := uc.apiKeyRepo.Create(ctx, apiKey); err != nil {
+		return "", err
 	}
 
-	return uc.apiKeyRepo.Delete(ctx, id)
+	return key, nil
+}
+
+// newSecret generates a secret for apiKey and sets its hash and prefix.
+func newSecret(apiKey *entity.APIKey) (string, error) {
+	// Generate secure API key
+	keyBytes := make([]byte, 32)
+	if _, err := rand.Read(keyBytes); err != nil {
+		return "", err
+	}
+	key := "sk_" + hex.EncodeToString(keyBytes)
+
+	apiKey.KeyHash = hashAPIKey(key)
+	apiKey.KeyPrefix = key[:apiKeyPrefixLength]
+
+	return key, nil
+}
+
+func toAPIKeyResponse(key *entity.APIKey) *entity.APIKeyResponse {
+	return &entity.APIKeyResponse{
+		ID:          key.ID,
+		KeyPrefix:   key.KeyPrefix,
+		Name:        key.Name,
+		WorkspaceID: key.WorkspaceID,
+		Scopes:      key.Scopes,
+		RateLimit:   key.RateLimit,
+		ExpiresAt:   key.ExpiresAt,
+		CreatedAt:   key.CreatedAt,
+		LastUsed:    key.LastUsed,
+		IsActive:    key.IsActive && !(key.ReplacedBy != "" && key.IsExpired()),
+		ReplacedBy:  key.ReplacedBy,
+	}
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,196 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func newAPIKeyUseCase() (*APIKeyUseCase, *memory.APIKeyRepository, *memory.WorkspaceRepository) {
+	keys := memory.NewAPIKeyRepository()
+	workspaces := memory.NewWorkspaceRepository(nil)
+	uc := NewAPIKeyUseCase(APIKeyUseCaseConfig{
+		APIKeyRepo:    keys,
+		WorkspaceRepo: workspaces,
+		AuditRepo:     memory.NewAuditRepository(),
+	})
+	return uc, keys, workspaces
+}
+
+func TestCreateAndValidateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	uc, _, _ := newAPIKeyUseCase()
+
+	resp, err := uc.CreateAPIKey(ctx, "user-1", entity.CreateAPIKeyRequest{Name: "ci"})
+	if err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if resp.Key == "" || resp.KeyPrefix != resp.Key[:apiKeyPrefixLength] || resp.RateLimit != 100 || len(resp.Scopes) != len(entity.DefaultScopes) {
+		t.Errorf("CreateAPIKey() = %+v", resp)
+	}
+
+	key, err := uc.ValidateAPIKey(ctx, resp.Key)
+	if err != nil || key.ID != resp.ID {
+		t.Errorf("ValidateAPIKey() = %v, %v", key, err)
+	}
+	if _, err := uc.ValidateAPIKey(ctx, "sk_unknown"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("ValidateAPIKey(unknown) error = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	if err := uc.RevokeAPIKey(ctx, resp.ID, "user-2"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("RevokeAPIKey() by another user error = %v, want ErrUnauthorized", err)
+	}
+	if err := uc.RevokeAPIKey(ctx, resp.ID, "user-1"); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if _, err := uc.ValidateAPIKey(ctx, resp.Key); !errors.Is(err, ErrAPIKeyInactive) {
+		t.Errorf("ValidateAPIKey(revoked) error = %v, want ErrAPIKeyInactive", err)
+	}
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	ctx := context.Background()
+	uc, _, _ := newAPIKeyUseCase()
+
+	old, _ := uc.CreateAPIKey(ctx, "user-1", entity.CreateAPIKeyRequest{Name: "ci", Scopes: []string{entity.ScopeURLsRead}})
+
+	rotated, err := uc.RotateAPIKey(ctx, old.ID, "user-1", entity.RotateAPIKeyRequest{})
+	if err != nil {
+		t.Fatalf("RotateAPIKey() error = %v", err)
+	}
+	if rotated.Key == old.Key || rotated.PreviousKey == nil || rotated.PreviousKey.ReplacedBy != rotated.ID {
+		t.Errorf("RotateAPIKey() = %+v", rotated)
+	}
+	if len(rotated.Scopes) != 1 || rotated.Scopes[0] != entity.ScopeURLsRead {
+		t.Errorf("rotated Scopes = %v, want the old key's", rotated.Scopes)
+	}
+
+	// Both secrets work during the grace period
+	for _, key := range []string{old.Key, rotated.Key} {
+		if _, err := uc.ValidateAPIKey(ctx, key); err != nil {
+			t.Errorf("ValidateAPIKey() during grace error = %v", err)
+		}
+	}
+
+	if _, err := uc.RotateAPIKey(ctx, old.ID, "user-1", entity.RotateAPIKeyRequest{}); !errors.Is(err, ErrAPIKeyRotated) {
+		t.Errorf("second RotateAPIKey() error = %v, want ErrAPIKeyRotated", err)
+	}
+
+	// Without a grace period the old secret stops working at once
+	noGrace := 0
+	next, err := uc.RotateAPIKey(ctx, rotated.ID, "user-1", entity.RotateAPIKeyRequest{GracePeriod: &noGrace})
+	if err != nil {
+		t.Fatalf("RotateAPIKey(no grace) error = %v", err)
+	}
+	if _, err := uc.ValidateAPIKey(ctx, rotated.Key); !errors.Is(err, ErrAPIKeyInactive) {
+		t.Errorf("ValidateAPIKey(replaced) error = %v, want ErrAPIKeyInactive", err)
+	}
+	if _, err := uc.ValidateAPIKey(ctx, next.Key); err != nil {
+		t.Errorf("ValidateAPIKey(new) error = %v", err)
+	}
+}
+
+func TestWorkspaceAPIKeys(t *testing.T) {
+	ctx := context.Background()
+	uc, _, workspaces := newAPIKeyUseCase()
+
+	workspace := &entity.Workspace{Name: "Marketing", CreatedBy: "owner"}
+	_ = workspaces.Create(ctx, workspace)
+	_ = workspaces.AddMember(ctx, &entity.WorkspaceMember{WorkspaceID: workspace.ID, UserID: "editor", Role: entity.RoleEditor})
+	_ = workspaces.AddMember(ctx, &entity.WorkspaceMember{WorkspaceID: workspace.ID, UserID: "admin", Role: entity.RoleAdmin})
+
+	req := entity.CreateAPIKeyRequest{Name: "shared", WorkspaceID: workspace.ID}
+	if _, err := uc.CreateAPIKey(ctx, "editor", req); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("CreateAPIKey() by editor error = %v, want ErrUnauthorized", err)
+	}
+
+	resp, err := uc.CreateAPIKey(ctx, "owner", req)
+	if err != nil {
+		t.Fatalf("CreateAPIKey() by owner error = %v", err)
+	}
+
+	// Admins manage every key of the workspace, not only their own
+	keys, err := uc.GetWorkspaceAPIKeys(ctx, workspace.ID, "admin")
+	if err != nil || len(keys) != 1 {
+		t.Errorf("GetWorkspaceAPIKeys() by admin = %v, %v", keys, err)
+	}
+	if err := uc.DeleteAPIKey(ctx, resp.ID, "editor"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("DeleteAPIKey() by editor error = %v, want ErrUnauthorized", err)
+	}
+	if err := uc.DeleteAPIKey(ctx, resp.ID, "admin"); err != nil {
+		t.Errorf("DeleteAPIKey() by admin error = %v", err)
+	}
+}
+
+func TestWorkspaceKeyContext(t *testing.T) {
+	ctx := context.Background()
+	uc, _, workspaces := newAPIKeyUseCase()
+
+	marketing := &entity.Workspace{Name: "Marketing", CreatedBy: "owner"}
+	sales := &entity.Workspace{Name: "Sales", CreatedBy: "owner"}
+	_ = workspaces.Create(ctx, marketing)
+	_ = workspaces.Create(ctx, sales)
+
+	personal, _ := uc.CreateAPIKey(ctx, "owner", entity.CreateAPIKeyRequest{Name: "personal"})
+	salesKey, _ := uc.CreateAPIKey(ctx, "owner", entity.CreateAPIKeyRequest{Name: "sales", WorkspaceID: sales.ID})
+	marketingKey, _ := uc.CreateAPIKey(ctx, "owner", entity.CreateAPIKeyRequest{Name: "marketing", WorkspaceID: marketing.ID})
+
+	// Requests made with the marketing key
+	keyCtx := WithActor(ctx, Actor{UserID: "owner", APIKeyID: marketingKey.ID, WorkspaceID: marketing.ID})
+
+	for _, id := range []string{personal.ID, salesKey.ID} {
+		if err := uc.RevokeAPIKey(keyCtx, id, "owner"); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("RevokeAPIKey(%s) with a workspace key error = %v, want ErrUnauthorized", id, err)
+		}
+	}
+	if _, err := uc.CreateAPIKey(keyCtx, "owner", entity.CreateAPIKeyRequest{Name: "escape"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("CreateAPIKey() personal with a workspace key error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := uc.GetUserAPIKeys(keyCtx, "owner"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetUserAPIKeys() with a workspace key error = %v, want ErrUnauthorized", err)
+	}
+	if err := uc.RevokeAPIKey(keyCtx, marketingKey.ID, "owner"); err != nil {
+		t.Errorf("RevokeAPIKey() of its own workspace error = %v", err)
+	}
+}
+
+func TestAPIKeyScopeLimits(t *testing.T) {
+	ctx := context.Background()
+	uc, _, workspaces := newAPIKeyUseCase()
+
+	workspace := &entity.Workspace{Name: "Marketing", CreatedBy: "owner"}
+	_ = workspaces.Create(ctx, workspace)
+
+	admin, _ := uc.CreateAPIKey(ctx, "owner", entity.CreateAPIKeyRequest{Name: "admin", Scopes: []string{entity.ScopeAuditRead, entity.ScopeWorkspacesManage}})
+	manager, _ := uc.CreateAPIKey(ctx, "owner", entity.CreateAPIKeyRequest{Name: "manager", Scopes: []string{entity.ScopeKeysManage, entity.ScopeURLsRead}})
+
+	// Requests made with the manager key
+	keyCtx := WithActor(ctx, Actor{UserID: "owner", APIKeyID: manager.ID, Scopes: manager.Scopes})
+
+	for _, req := range []entity.CreateAPIKeyRequest{
+		{Name: "escalate", Scopes: []string{entity.ScopeURLsRead, entity.ScopeAuditRead}},
+		{Name: "workspace", Scopes: []string{entity.ScopeWorkspacesManage}, WorkspaceID: workspace.ID},
+	} {
+		if _, err := uc.CreateAPIKey(keyCtx, "owner", req); !errors.Is(err, ErrScopeNotHeld) {
+			t.Errorf("CreateAPIKey(%v) error = %v, want ErrScopeNotHeld", req.Scopes, err)
+		}
+	}
+	if _, err := uc.RotateAPIKey(keyCtx, admin.ID, "owner", entity.RotateAPIKeyRequest{}); !errors.Is(err, ErrScopeNotHeld) {
+		t.Errorf("RotateAPIKey() of a key with more scopes error = %v, want ErrScopeNotHeld", err)
+	}
+
+	// Default scopes are cut down to those the key has
+	resp, err := uc.CreateAPIKey(keyCtx, "owner", entity.CreateAPIKeyRequest{Name: "defaults"})
+	if err != nil || len(resp.Scopes) != 1 || resp.Scopes[0] != entity.ScopeURLsRead {
+		t.Errorf("CreateAPIKey() with default scopes = %v, %v, want urls:read only", resp, err)
+	}
+	if _, err := uc.CreateAPIKey(keyCtx, "owner", entity.CreateAPIKeyRequest{Name: "subset", Scopes: []string{entity.ScopeKeysManage}}); err != nil {
+		t.Errorf("CreateAPIKey() with scopes the key has error = %v", err)
+	}
+	if _, err := uc.RotateAPIKey(keyCtx, manager.ID, "owner", entity.RotateAPIKeyRequest{}); err != nil {
+		t.Errorf("RotateAPIKey() of itself error = %v", err)
+	}
+}
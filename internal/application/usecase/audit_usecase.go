@@ -0,0 +1,162 @@
+package usecase
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/pkg/jsondiff"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// Actor identifies who is making a request, for the audit log and to keep
+// API keys inside their workspace and scopes.
+type Actor struct {
+	UserID    string
+	APIKeyID  string
+	IPAddress string
+
+	// WorkspaceID is the workspace of the API key used, if it belongs to one
+	WorkspaceID string
+	// Scopes are those of the API key used, which keys it manages cannot
+	// exceed
+	Scopes []string
+}
+
+type actorContextKey struct{}
+
+// WithActor returns a context carrying the actor of the current request.
+func WithActor(ctx context.Context, actor Actor) context.Context {
+	return context.WithValue(ctx, actorContextKey{}, actor)
+}
+
+func ActorFromContext(ctx context.Context) Actor {
+	actor, _ := ctx.Value(actorContextKey{}).(Actor)
+	return actor
+}
+
+// auditLog records mutating use case calls. It does nothing without a
+// repository.
+type auditLog struct {
+	repo repository.AuditRepository
+}
+
+// record stores an audit event with the fields that differ between before and
+// after. Pass nil as before for creations and as after for deletions. It is
+// called once the change is made, so a failure to store the event is logged
+// rather than failing a request whose change went through.
+func (a auditLog) record(ctx context.Context, userID, action, targetType, targetID, workspaceID string, before, after any) {
+	if a.repo == nil {
+		return
+	}
+
+	actor := ActorFromContext(ctx)
+	if userID == "" {
+		userID = actor.UserID
+	}
+
+	event := &entity.AuditEvent{
+		ActorUserID:   userID,
+		ActorAPIKeyID: actor.APIKeyID,
+		Action:        action,
+		TargetType:    targetType,
+		TargetID:      targetID,
+		WorkspaceID:   workspaceID,
+		IPAddress:     actor.IPAddress,
+	}
+	if err := a.store(ctx, event, before, after); err != nil {
+		slog.ErrorContext(ctx, "failed to write audit event",
+			slog.String("action", action),
+			slog.String("target_type", targetType),
+			slog.String("target_id", targetID),
+			slog.Any("error", err),
+		)
+	}
+}
+
+func (a auditLog) store(ctx context.Context, event *entity.AuditEvent, before, after any) error {
+	diff, err := jsondiff.Diff(before, after)
+	if err != nil {
+		return err
+	}
+	if len(diff) > 0 {
+		event.Changes, err = json.Marshal(diff)
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.repo.Create(ctx, event)
+}
+
+type AuditUseCase struct {
+	auditRepo     repository.AuditRepository
+	workspaceRepo repository.WorkspaceRepository
+}
+
+type AuditUseCaseConfig struct {
+	AuditRepo     repository.AuditRepository
+	WorkspaceRepo repository.WorkspaceRepository
+}
+
+func NewAuditUseCase(cfg AuditUseCaseConfig) *AuditUseCase {
+	return &AuditUseCase{
+		auditRepo:     cfg.AuditRepo,
+		workspaceRepo: cfg.WorkspaceRepo,
+	}
+}
+
+// ListEvents returns a page of the audit log. Workspace admins can see every
+// event of their workspace; everyone else only their own actions. A workspace
+// API key only sees the events of its workspace.
+func (uc *AuditUseCase) ListEvents(ctx context.Context, userID string, filter entity.AuditFilter) (*entity.AuditEventPage, error) {
+	if keyWorkspace := ActorFromContext(ctx).WorkspaceID; keyWorkspace != "" {
+		if filter.WorkspaceID != "" && filter.WorkspaceID != keyWorkspace {
+			return nil, ErrUnauthorized
+		}
+		filter.WorkspaceID = keyWorkspace
+	}
+
+	if filter.WorkspaceID != "" {
+		if _, err := requireRole(ctx, uc.workspaceRepo, filter.WorkspaceID, userID, entity.RoleAdmin); err != nil {
+			return nil, err
+		}
+	} else {
+		if filter.ActorUserID != "" && filter.ActorUserID != userID {
+			return nil, ErrUnauthorized
+		}
+		filter.ActorUserID = userID
+	}
+
+	if filter.Limit <= 0 {
+		filter.Limit = defaultAuditPageSize
+	}
+	filter.Limit = min(filter.Limit, maxAuditPageSize)
+
+	// Fetch one extra event to know whether there is a next page
+	pageSize := filter.Limit
+	filter.Limit++
+
+	events, err := uc.auditRepo.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &entity.AuditEventPage{Events: events}
+	if len(events) > pageSize {
+		page.Events = events[:pageSize]
+		page.NextCursor = strconv.FormatInt(page.Events[pageSize-1].ID, 10)
+	}
+	if page.Events == nil {
+		page.Events = []*entity.AuditEvent{}
+	}
+
+	return page, nil
+}
@@ -0,0 +1,51 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func TestListEventsWithWorkspaceKey(t *testing.T) {
+	ctx := context.Background()
+
+	users := memory.NewUserRepository()
+	workspaces := memory.NewWorkspaceRepository(users)
+	audit := memory.NewAuditRepository()
+	uc := NewAuditUseCase(AuditUseCaseConfig{AuditRepo: audit, WorkspaceRepo: workspaces})
+
+	marketing := &entity.Workspace{Name: "Marketing", CreatedBy: "owner"}
+	sales := &entity.Workspace{Name: "Sales", CreatedBy: "owner"}
+	_ = workspaces.Create(ctx, marketing)
+	_ = workspaces.Create(ctx, sales)
+	_ = workspaces.AddMember(ctx, &entity.WorkspaceMember{WorkspaceID: marketing.ID, UserID: "editor", Role: entity.RoleEditor})
+
+	for _, workspaceID := range []string{"", marketing.ID, sales.ID} {
+		event := &entity.AuditEvent{ActorUserID: "owner", Action: entity.AuditActionURLCreate, WorkspaceID: workspaceID}
+		if err := audit.Create(ctx, event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keyCtx := WithActor(ctx, Actor{UserID: "owner", APIKeyID: "key-1", WorkspaceID: marketing.ID})
+
+	page, err := uc.ListEvents(keyCtx, "owner", entity.AuditFilter{})
+	if err != nil {
+		t.Fatalf("ListEvents() with a workspace key error = %v", err)
+	}
+	if len(page.Events) != 1 || page.Events[0].WorkspaceID != marketing.ID {
+		t.Errorf("ListEvents() with a workspace key = %v, want only the marketing event", page.Events)
+	}
+
+	if _, err := uc.ListEvents(keyCtx, "owner", entity.AuditFilter{WorkspaceID: sales.ID}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("ListEvents() of another workspace error = %v, want ErrUnauthorized", err)
+	}
+
+	editorCtx := WithActor(ctx, Actor{UserID: "editor", APIKeyID: "key-2", WorkspaceID: marketing.ID})
+	if _, err := uc.ListEvents(editorCtx, "editor", entity.AuditFilter{}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("ListEvents() by an editor's workspace key error = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,159 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/pkg/jwt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	ErrEmailExists        = errors.New("email already registered")
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	ErrInvalidToken       = errors.New("invalid or expired token")
+	ErrUserNotFound       = errors.New("user not found")
+)
+
+const defaultSessionTTL = 24 * time.Hour
+
+// dummyPasswordHash is compared against when a login email is unknown, so
+// the response time does not reveal which emails are registered.
+var dummyPasswordHash = []byte("$2a$10$LAGvkQcqI6PgmkK2SqtCTeRBUBRvzZiVQglCKYVZhbfjDuh0KEGRe")
+
+type AuthUseCase struct {
+	userRepo   repository.UserRepository
+	audit      auditLog
+	secret     []byte
+	sessionTTL time.Duration
+}
+
+type AuthUseCaseConfig struct {
+	UserRepo   repository.UserRepository
+	AuditRepo  repository.AuditRepository
+	Secret     string
+	SessionTTL time.Duration
+}
+
+func NewAuthUseCase(cfg AuthUseCaseConfig) *AuthUseCase {
+	if cfg.SessionTTL <= 0 {
+		cfg.SessionTTL = defaultSessionTTL
+	}
+	return &AuthUseCase{
+		userRepo:   cfg.UserRepo,
+		audit:      auditLog{repo: cfg.AuditRepo},
+		secret:     []byte(cfg.Secret),
+		sessionTTL: cfg.SessionTTL,
+	}
+}
+
+func (uc *AuthUseCase) Signup(ctx context.Context, req entity.SignupRequest) (*entity.AuthResponse, error) {
+	email := normalizeEmail(req.Email)
+
+	exists, err := uc.userRepo.EmailExists(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrEmailExists
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &entity.User{
+		Email:        email,
+		Name:         strings.TrimSpace(req.Name),
+		PasswordHash: string(hash),
+	}
+
+	// A signup for the same email may have got in since the check
+	if err := uc.userRepo.Create(ctx, user); err != nil {
+		if errors.Is(err, repository.ErrEmailTaken) {
+			return nil, ErrEmailExists
+		}
+		return nil, err
+	}
+
+	uc.audit.record(ctx, user.ID, entity.AuditActionUserSignup, entity.AuditTargetUser, user.ID, "", nil, user)
+
+	return uc.issueSession(user)
+}
+
+func (uc *AuthUseCase) Login(ctx context.Context, req entity.LoginRequest) (*entity.AuthResponse, error) {
+	user, err := uc.userRepo.GetByEmail(ctx, normalizeEmail(req.Email))
+	if err != nil {
+		return nil, err
+	}
+
+	if user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		return nil, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	return uc.issueSession(user)
+}
+
+// ValidateToken checks a session token and returns the user ID it was issued to.
+func (uc *AuthUseCase) ValidateToken(token string) (string, error) {
+	claims, err := jwt.Parse(token, uc.secret)
+	if err != nil || claims.Subject == "" {
+		return "", ErrInvalidToken
+	}
+	return claims.Subject, nil
+}
+
+func (uc *AuthUseCase) GetUser(ctx context.Context, id string) (*entity.UserResponse, error) {
+	user, err := uc.userRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return toUserResponse(user), nil
+}
+
+func (uc *AuthUseCase) issueSession(user *entity.User) (*entity.AuthResponse, error) {
+	now := time.Now()
+	expiresAt := now.Add(uc.sessionTTL)
+
+	token, err := jwt.Sign(jwt.Claims{
+		Subject:   user.ID,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, uc.secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &entity.AuthResponse{
+		Token:     token,
+		ExpiresAt: expiresAt,
+		User:      toUserResponse(user),
+	}, nil
+}
+
+func toUserResponse(user *entity.User) *entity.UserResponse {
+	return &entity.UserResponse{
+		ID:        user.ID,
+		Email:     user.Email,
+		Name:      user.Name,
+		CreatedAt: user.CreatedAt,
+	}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
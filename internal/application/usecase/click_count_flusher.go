@@ -0,0 +1,137 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+const (
+	defaultClickCountFlushInterval = 10 * time.Second
+
+	// A batch still taken after twice this was left by a flush that stopped
+	clickCountWriteTimeout = 30 * time.Second
+)
+
+// ClickCountFlusher periodically moves the click counts pending in the cache
+// into the database. Every replica may run one.
+type ClickCountFlusher struct {
+	urlRepo    repository.URLRepository
+	urlCache   repository.URLCacheRepository
+	interval   time.Duration
+	claimAfter time.Duration
+
+	mu   sync.Mutex // serializes flushes
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+type ClickCountFlusherConfig struct {
+	URLRepo  repository.URLRepository
+	URLCache repository.URLCacheRepository
+	Interval time.Duration
+}
+
+func NewClickCountFlusher(cfg ClickCountFlusherConfig) *ClickCountFlusher {
+	if cfg.Interval <= 0 {
+		cfg.Interval = defaultClickCountFlushInterval
+	}
+
+	f := &ClickCountFlusher{
+		urlRepo:    cfg.URLRepo,
+		urlCache:   cfg.URLCache,
+		interval:   cfg.Interval,
+		claimAfter: 2 * clickCountWriteTimeout,
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	_, _ = f.Flush(context.Background())
+	go f.run()
+
+	return f
+}
+
+// Flush returns how many clicks it moved.
+func (f *ClickCountFlusher) Flush(ctx context.Context) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	batches, err := f.urlCache.ClaimPendingClicks(ctx, f.claimAfter)
+	if err != nil {
+		return 0, err
+	}
+
+	batch, err := f.urlCache.TakePendingClicks(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if batch != nil {
+		batches = append(batches, batch)
+	}
+
+	var moved int64
+	var errs []error
+	for _, batch := range batches {
+		n, err := f.write(ctx, batch)
+		moved += n
+		errs = append(errs, err)
+	}
+	return moved, errors.Join(errs...)
+}
+
+// A batch that fails to be dropped from the cache is written again once
+// claimed, counting it twice rather than losing it.
+func (f *ClickCountFlusher) write(ctx context.Context, batch *repository.PendingClickBatch) (int64, error) {
+	writeCtx, cancel := context.WithTimeout(ctx, clickCountWriteTimeout)
+	defer cancel()
+
+	// Use a fresh context for the cache, the failure may be ctx running out
+	if err := f.urlRepo.AddClickCounts(writeCtx, batch.Counts); err != nil {
+		if returnErr := f.urlCache.ReturnPendingClicks(context.Background(), batch.ID); returnErr != nil {
+			return 0, errors.Join(err, returnErr)
+		}
+		return 0, err
+	}
+	if err := f.urlCache.DonePendingClicks(context.Background(), batch.ID); err != nil {
+		return 0, err
+	}
+
+	var moved int64
+	for _, count := range batch.Counts {
+		moved += count
+	}
+	return moved, nil
+}
+
+func (f *ClickCountFlusher) Shutdown(ctx context.Context) error {
+	f.once.Do(func() { close(f.stop) })
+
+	select {
+	case <-f.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	_, err := f.Flush(ctx)
+	return err
+}
+
+func (f *ClickCountFlusher) run() {
+	defer close(f.done)
+
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_, _ = f.Flush(context.Background())
+		case <-f.stop:
+			return
+		}
+	}
+}
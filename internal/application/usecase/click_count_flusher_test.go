@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// failingCounts rejects every AddClickCounts call.
+type failingCounts struct {
+	*memory.URLRepository
+}
+
+func (r failingCounts) AddClickCounts(ctx context.Context, counts map[string]int64) error {
+	return errors.New("database is down")
+}
+
+func TestClickCountFlusher(t *testing.T) {
+	ctx := context.Background()
+	urls := memory.NewURLRepository()
+	cache := memory.NewURLCacheRepository(time.Hour)
+
+	url := &entity.URL{ShortCode: "abc123", OriginalURL: "https://example.com"}
+	if err := urls.Create(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+
+	flusher := NewClickCountFlusher(ClickCountFlusherConfig{URLRepo: urls, URLCache: cache, Interval: time.Hour})
+	t.Cleanup(func() { _ = flusher.Shutdown(ctx) })
+
+	_ = cache.AddPendingClicks(ctx, map[string]int64{url.ID: 3})
+	if moved, err := flusher.Flush(ctx); err != nil || moved != 3 {
+		t.Fatalf("Flush() = %d, %v, want 3", moved, err)
+	}
+	if got, _ := urls.GetByID(ctx, url.ID); got.ClickCount != 3 {
+		t.Errorf("ClickCount = %d, want 3", got.ClickCount)
+	}
+
+	// Shutdown flushes what arrived since
+	_ = cache.AddPendingClicks(ctx, map[string]int64{url.ID: 2})
+	if err := flusher.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if got, _ := urls.GetByID(ctx, url.ID); got.ClickCount != 5 {
+		t.Errorf("ClickCount after Shutdown() = %d, want 5", got.ClickCount)
+	}
+	if pending, _ := cache.GetPendingClicks(ctx, []string{url.ID}); len(pending) != 0 {
+		t.Errorf("pending after Shutdown() = %v, want none", pending)
+	}
+}
+
+func TestClickCountFlusherInterval(t *testing.T) {
+	ctx := context.Background()
+	urls := memory.NewURLRepository()
+	cache := memory.NewURLCacheRepository(time.Hour)
+
+	url := &entity.URL{ShortCode: "abc123", OriginalURL: "https://example.com"}
+	if err := urls.Create(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+
+	flusher := NewClickCountFlusher(ClickCountFlusherConfig{URLRepo: urls, URLCache: cache, Interval: 10 * time.Millisecond})
+	t.Cleanup(func() { _ = flusher.Shutdown(ctx) })
+
+	_ = cache.AddPendingClicks(ctx, map[string]int64{url.ID: 1})
+	eventually(t, func() bool {
+		got, _ := urls.GetByID(ctx, url.ID)
+		return got.ClickCount == 1
+	})
+}
+
+func TestClickCountFlusherRestoresOnFailure(t *testing.T) {
+	ctx := context.Background()
+	cache := memory.NewURLCacheRepository(time.Hour)
+
+	flusher := NewClickCountFlusher(ClickCountFlusherConfig{
+		URLRepo:  failingCounts{memory.NewURLRepository()},
+		URLCache: cache,
+		Interval: time.Hour,
+	})
+	t.Cleanup(func() { _ = flusher.Shutdown(ctx) })
+
+	_ = cache.AddPendingClicks(ctx, map[string]int64{"a": 2})
+	if _, err := flusher.Flush(ctx); err == nil {
+		t.Fatal("Flush() error = nil, want the database error")
+	}
+
+	// Clicks counted meanwhile are kept alongside the restored ones
+	_ = cache.AddPendingClicks(ctx, map[string]int64{"a": 1})
+	if pending, _ := cache.GetPendingClicks(ctx, []string{"a"}); pending["a"] != 3 {
+		t.Errorf("pending = %v, want a:3", pending)
+	}
+}
+
+func TestClickCountFlusherWritesLeftBatches(t *testing.T) {
+	ctx := context.Background()
+	urls := memory.NewURLRepository()
+	cache := memory.NewURLCacheRepository(time.Hour)
+
+	url := &entity.URL{ShortCode: "abc123", OriginalURL: "https://example.com"}
+	if err := urls.Create(ctx, url); err != nil {
+		t.Fatal(err)
+	}
+
+	// A flush that took its clicks and stopped before writing them
+	_ = cache.AddPendingClicks(ctx, map[string]int64{url.ID: 4})
+	if batch, err := cache.TakePendingClicks(ctx); batch == nil || err != nil {
+		t.Fatalf("TakePendingClicks() = %v, %v", batch, err)
+	}
+	if got, _ := urls.GetByID(ctx, url.ID); got.ClickCount != 0 {
+		t.Fatalf("ClickCount = %d, want 0", got.ClickCount)
+	}
+
+	// is not picked up by a flush that may still be running it
+	flusher := NewClickCountFlusher(ClickCountFlusherConfig{URLRepo: urls, URLCache: cache, Interval: time.Hour})
+	t.Cleanup(func() { _ = flusher.Shutdown(ctx) })
+	if pending, _ := cache.GetPendingClicks(ctx, []string{url.ID}); pending[url.ID] != 4 {
+		t.Errorf("pending = %v, want the left batch kept", pending)
+	}
+
+	// but by the first flush after that one must have stopped
+	flusher.mu.Lock()
+	flusher.claimAfter = time.Millisecond
+	flusher.mu.Unlock()
+	time.Sleep(5 * time.Millisecond)
+
+	_ = cache.AddPendingClicks(ctx, map[string]int64{url.ID: 1})
+	if moved, err := flusher.Flush(ctx); err != nil || moved != 5 {
+		t.Fatalf("Flush() = %d, %v, want 5", moved, err)
+	}
+	if got, _ := urls.GetByID(ctx, url.ID); got.ClickCount != 5 {
+		t.Errorf("ClickCount = %d, want 5", got.ClickCount)
+	}
+	if pending, _ := cache.GetPendingClicks(ctx, []string{url.ID}); len(pending) != 0 {
+		t.Errorf("pending after Flush() = %v, want none", pending)
+	}
+}
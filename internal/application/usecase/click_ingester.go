@@ -0,0 +1,345 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/pkg/geoip"
+)
+
+var (
+	ErrClickQueueFull      = errors.New("click queue is full")
+	ErrClickIngesterClosed = errors.New("click ingester is shut down")
+)
+
+const (
+	defaultClickWorkers       = 4
+	defaultClickQueueSize     = 10000
+	defaultClickBatchSize     = 100
+	defaultClickFlushInterval = time.Second
+	defaultGeoLookupTimeout   = 2 * time.Second
+
+	// geoLookupConcurrency bounds the lookups in flight for one batch
+	geoLookupConcurrency = 8
+)
+
+// GeoLocator looks up where an IP address is, such as *geoip.Client. Lookup
+// must return once ctx is done.
+type GeoLocator interface {
+	Lookup(ctx context.Context, ip string) (*geoip.GeoInfo, error)
+}
+
+// ClickIngester records clicks off the request path. Clicks wait in a
+// bounded queue and a fixed number of workers write them in batches, so a
+// traffic spike costs queue slots instead of goroutines and connections.
+type ClickIngester struct {
+	writer         clickWriter
+	queue          chan *entity.Click
+	batchSize      int
+	flushInterval  time.Duration
+	enqueueTimeout time.Duration
+
+	// mu guards closed; Enqueue holds it for reading so the queue is never
+	// closed while a click is being sent
+	mu     sync.RWMutex
+	closed bool
+	wg     sync.WaitGroup
+
+	accepted atomic.Int64
+	dropped  atomic.Int64
+	written  atomic.Int64
+	failed   atomic.Int64
+}
+
+type ClickIngesterConfig struct {
+	URLRepo     repository.URLRepository
+	ClickRepo   repository.ClickRepository
+	GeoIPClient GeoLocator
+	// GeoLookupTimeout bounds how long a batch waits for GeoIPClient; clicks
+	// it has not located by then are written without a city.
+	GeoLookupTimeout time.Duration
+	// URLCache, if set, counts clicks as pending for a ClickCountFlusher
+	// instead of updating the links directly.
+	URLCache repository.URLCacheRepository
+
+	Workers       int
+	QueueSize     int
+	BatchSize     int
+	FlushInterval time.Duration
+	// EnqueueTimeout is how long Enqueue waits for room in a full queue
+	// before dropping the click. Zero drops at once.
+	EnqueueTimeout time.Duration
+}
+
+// ClickIngesterStats are counters since the ingester started.
+type ClickIngesterStats struct {
+	Queued   int   `json:"queued"`
+	Accepted int64 `json:"accepted"`
+	Dropped  int64 `json:"dropped"`
+	Written  int64 `json:"written"`
+	Failed   int64 `json:"failed"`
+}
+
+// NewClickIngester starts the workers. Call Shutdown to stop them.
+func NewClickIngester(cfg ClickIngesterConfig) *ClickIngester {
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultClickWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultClickQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultClickBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultClickFlushInterval
+	}
+	if cfg.GeoLookupTimeout <= 0 {
+		cfg.GeoLookupTimeout = defaultGeoLookupTimeout
+	}
+
+	i := &ClickIngester{
+		writer: clickWriter{
+			urlRepo:     cfg.URLRepo,
+			urlCache:    cfg.URLCache,
+			clickRepo:   cfg.ClickRepo,
+			geoipClient: cfg.GeoIPClient,
+			geoTimeout:  cfg.GeoLookupTimeout,
+		},
+		queue:          make(chan *entity.Click, cfg.QueueSize),
+		batchSize:      cfg.BatchSize,
+		flushInterval:  cfg.FlushInterval,
+		enqueueTimeout: cfg.EnqueueTimeout,
+	}
+
+	i.wg.Add(cfg.Workers)
+	for range cfg.Workers {
+		go i.run()
+	}
+
+	return i
+}
+
+// Enqueue queues a click for writing. It returns ErrClickQueueFull if the
+// queue stays full for longer than the enqueue timeout, in which case the
+// click is dropped and counted.
+func (i *ClickIngester) Enqueue(click *entity.Click) error {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if i.closed {
+		i.dropped.Add(1)
+		return ErrClickIngesterClosed
+	}
+
+	select {
+	case i.queue <- click:
+		i.accepted.Add(1)
+		return nil
+	default:
+	}
+
+	if i.enqueueTimeout > 0 {
+		timer := time.NewTimer(i.enqueueTimeout)
+		defer timer.Stop()
+
+		select {
+		case i.queue <- click:
+			i.accepted.Add(1)
+			return nil
+		case <-timer.C:
+		}
+	}
+
+	i.dropped.Add(1)
+	return ErrClickQueueFull
+}
+
+// Shutdown stops accepting clicks and waits until the queued ones are
+// written or ctx is done, whichever comes first.
+func (i *ClickIngester) Shutdown(ctx context.Context) error {
+	i.mu.Lock()
+	if !i.closed {
+		i.closed = true
+		close(i.queue)
+	}
+	i.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		i.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (i *ClickIngester) Stats() ClickIngesterStats {
+	return ClickIngesterStats{
+		Queued:   len(i.queue),
+		Accepted: i.accepted.Load(),
+		Dropped:  i.dropped.Load(),
+		Written:  i.written.Load(),
+		Failed:   i.failed.Load(),
+	}
+}
+
+// run collects clicks into a batch and writes it once it is full or the
+// flush interval has passed, until the queue is closed and drained.
+func (i *ClickIngester) run() {
+	defer i.wg.Done()
+
+	batch := make([]*entity.Click, 0, i.batchSize)
+	ticker := time.NewTicker(i.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case click, ok := <-i.queue:
+			if !ok {
+				i.flush(batch)
+				return
+			}
+			batch = append(batch, click)
+			if len(batch) >= i.batchSize {
+				i.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			i.flush(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (i *ClickIngester) flush(batch []*entity.Click) {
+	if len(batch) == 0 {
+		return
+	}
+
+	written, err := i.writer.write(context.Background(), batch)
+	if err != nil {
+		i.failed.Add(int64(len(batch)))
+		return
+	}
+	i.written.Add(int64(written))
+	i.failed.Add(int64(len(batch) - written))
+}
+
+// clickWriter stores clicks and adds them to their links' click counts.
+type clickWriter struct {
+	urlRepo     repository.URLRepository
+	urlCache    repository.URLCacheRepository
+	clickRepo   repository.ClickRepository
+	geoipClient GeoLocator
+	geoTimeout  time.Duration
+}
+
+// write stores clicks and counts those it stored, and returns how many that
+// is.
+func (w clickWriter) write(ctx context.Context, clicks []*entity.Click) (int, error) {
+	w.locate(ctx, clicks)
+
+	if w.clickRepo != nil {
+		var err error
+		if clicks, err = w.store(ctx, clicks); len(clicks) == 0 {
+			return 0, err
+		}
+	}
+
+	counts := make(map[string]int64)
+	for _, click := range clicks {
+		counts[click.URLID]++
+	}
+
+	// Counting in the cache keeps hot links from contending on their row;
+	// the database is only updated directly when the cache is unavailable
+	if w.urlCache != nil {
+		if err := w.urlCache.AddPendingClicks(ctx, counts); err == nil {
+			return len(clicks), nil
+		}
+	}
+	return len(clicks), w.urlRepo.AddClickCounts(ctx, counts)
+}
+
+// store writes clicks in one batch and returns those stored. Should the batch
+// fail, each click is tried on its own, so one whose link was deleted while
+// it was queued does not take the rest down with it.
+func (w clickWriter) store(ctx context.Context, clicks []*entity.Click) ([]*entity.Click, error) {
+	err := w.clickRepo.CreateBatch(ctx, clicks)
+	if err == nil {
+		return clicks, nil
+	}
+	if len(clicks) == 1 {
+		return nil, err
+	}
+
+	var stored []*entity.Click
+	for _, click := range clicks {
+		if err = w.clickRepo.CreateBatch(ctx, []*entity.Click{click}); err == nil {
+			stored = append(stored, click)
+		}
+	}
+	return stored, err
+}
+
+// locate fills in where clicks came from. Each address is looked up once,
+// a few at a time, and clicks whose lookup has not come back within the geo
+// timeout are left as they are, so a slow lookup service cannot hold up
+// writing the batch.
+func (w clickWriter) locate(ctx context.Context, clicks []*entity.Click) {
+	if w.geoipClient == nil {
+		return
+	}
+
+	byIP := make(map[string][]*entity.Click)
+	for _, click := range clicks {
+		if click.IPAddress != "" {
+			byIP[click.IPAddress] = append(byIP[click.IPAddress], click)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.geoTimeout)
+	defer cancel()
+
+	// Every lookup writes to its own clicks only
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, geoLookupConcurrency)
+lookups:
+	for ip, located := range byIP {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break lookups
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			geoInfo, err := w.geoipClient.Lookup(ctx, ip)
+			if err != nil || geoInfo == nil {
+				return
+			}
+			for _, click := range located {
+				click.Country = geoInfo.Country
+				click.City = geoInfo.City
+				if click.CountryCode == "" {
+					click.CountryCode = geoInfo.CountryCode
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
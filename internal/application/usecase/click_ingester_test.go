@@ -0,0 +1,296 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/pkg/geoip"
+)
+
+// batchRecorder records the size of every batch and can hold writes until
+// release is closed.
+type batchRecorder struct {
+	*memory.ClickRepository
+	batches chan int
+	release chan struct{}
+}
+
+func newBatchRecorder() *batchRecorder {
+	r := &batchRecorder{
+		ClickRepository: memory.NewClickRepository(),
+		batches:         make(chan int, 100),
+		release:         make(chan struct{}),
+	}
+	close(r.release)
+	return r
+}
+
+func (r *batchRecorder) CreateBatch(ctx context.Context, clicks []*entity.Click) error {
+	<-r.release
+	r.batches <- len(clicks)
+	return r.ClickRepository.CreateBatch(ctx, clicks)
+}
+
+func newIngesterFixture(t *testing.T, cfg ClickIngesterConfig) (*ClickIngester, *memory.URLRepository, *entity.URL) {
+	t.Helper()
+
+	urls := memory.NewURLRepository()
+	url := &entity.URL{ShortCode: "abc123", OriginalURL: "https://example.com"}
+	if err := urls.Create(context.Background(), url); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.URLRepo = urls
+	ingester := NewClickIngester(cfg)
+	t.Cleanup(func() { _ = ingester.Shutdown(context.Background()) })
+	return ingester, urls, url
+}
+
+func TestClickIngesterBatches(t *testing.T) {
+	clicks := newBatchRecorder()
+	ingester, urls, url := newIngesterFixture(t, ClickIngesterConfig{
+		ClickRepo:     clicks,
+		Workers:       1,
+		BatchSize:     10,
+		FlushInterval: time.Hour,
+	})
+
+	for range 25 {
+		if err := ingester.Enqueue(&entity.Click{URLID: url.ID, ShortCode: url.ShortCode}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	// Two full batches are written right away, the rest on shutdown
+	for range 2 {
+		if size := <-clicks.batches; size != 10 {
+			t.Errorf("batch size = %d, want 10", size)
+		}
+	}
+	if err := ingester.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if size := <-clicks.batches; size != 5 {
+		t.Errorf("last batch size = %d, want 5", size)
+	}
+
+	got, _ := urls.GetByID(context.Background(), url.ID)
+	if got.ClickCount != 25 {
+		t.Errorf("ClickCount = %d, want 25", got.ClickCount)
+	}
+	if stats := ingester.Stats(); stats.Accepted != 25 || stats.Written != 25 || stats.Dropped != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	if err := ingester.Enqueue(&entity.Click{URLID: url.ID}); !errors.Is(err, ErrClickIngesterClosed) {
+		t.Errorf("Enqueue() after Shutdown() error = %v, want ErrClickIngesterClosed", err)
+	}
+}
+
+func TestClickIngesterFlushInterval(t *testing.T) {
+	clicks := newBatchRecorder()
+	ingester, _, url := newIngesterFixture(t, ClickIngesterConfig{
+		ClickRepo:     clicks,
+		BatchSize:     100,
+		FlushInterval: 10 * time.Millisecond,
+	})
+
+	_ = ingester.Enqueue(&entity.Click{URLID: url.ID})
+
+	select {
+	case size := <-clicks.batches:
+		if size != 1 {
+			t.Errorf("batch size = %d, want 1", size)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("partial batch not flushed")
+	}
+}
+
+func TestClickIngesterDropsWhenFull(t *testing.T) {
+	clicks := newBatchRecorder()
+	clicks.release = make(chan struct{}) // writes block until released
+
+	ingester, _, url := newIngesterFixture(t, ClickIngesterConfig{
+		ClickRepo:      clicks,
+		Workers:        1,
+		QueueSize:      2,
+		BatchSize:      1,
+		EnqueueTimeout: time.Millisecond,
+	})
+
+	// One click is held by the blocked worker, two fill the queue
+	_ = ingester.Enqueue(&entity.Click{URLID: url.ID})
+	eventually(t, func() bool { return ingester.Stats().Queued == 0 })
+	for range 2 {
+		if err := ingester.Enqueue(&entity.Click{URLID: url.ID}); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+
+	if err := ingester.Enqueue(&entity.Click{URLID: url.ID}); !errors.Is(err, ErrClickQueueFull) {
+		t.Errorf("Enqueue() on a full queue error = %v, want ErrClickQueueFull", err)
+	}
+	if stats := ingester.Stats(); stats.Accepted != 3 || stats.Dropped != 1 || stats.Queued != 2 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+
+	close(clicks.release)
+	if err := ingester.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+	if stats := ingester.Stats(); stats.Written != 3 {
+		t.Errorf("Written = %d after draining, want 3", stats.Written)
+	}
+}
+
+func TestClickIngesterShutdownTimeout(t *testing.T) {
+	clicks := newBatchRecorder()
+	clicks.release = make(chan struct{})
+	defer close(clicks.release)
+
+	ingester, _, url := newIngesterFixture(t, ClickIngesterConfig{ClickRepo: clicks, Workers: 1, BatchSize: 1})
+	_ = ingester.Enqueue(&entity.Click{URLID: url.ID})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := ingester.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// linkedClickRepository rejects a batch with a click on a link that is gone,
+// as the foreign key on clicks.url_id does.
+type linkedClickRepository struct {
+	*memory.ClickRepository
+	urls *memory.URLRepository
+}
+
+func (r *linkedClickRepository) CreateBatch(ctx context.Context, clicks []*entity.Click) error {
+	for _, click := range clicks {
+		if url, _ := r.urls.GetByID(ctx, click.URLID); url == nil {
+			return fmt.Errorf("link %s of click not found", click.URLID)
+		}
+	}
+	return r.ClickRepository.CreateBatch(ctx, clicks)
+}
+
+func TestClickIngesterLinkDeletedWhileQueued(t *testing.T) {
+	ctx := context.Background()
+	clicks := &linkedClickRepository{ClickRepository: memory.NewClickRepository()}
+	ingester, urls, url := newIngesterFixture(t, ClickIngesterConfig{
+		ClickRepo:     clicks,
+		Workers:       1,
+		BatchSize:     100,
+		FlushInterval: time.Hour,
+	})
+	clicks.urls = urls
+
+	deleted := &entity.URL{ShortCode: "gone", OriginalURL: "https://example.com"}
+	if err := urls.Create(ctx, deleted); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 10 {
+		click := &entity.Click{URLID: url.ID, ShortCode: url.ShortCode}
+		if i%5 == 0 {
+			click = &entity.Click{URLID: deleted.ID, ShortCode: deleted.ShortCode}
+		}
+		if err := ingester.Enqueue(click); err != nil {
+			t.Fatalf("Enqueue() error = %v", err)
+		}
+	}
+	if err := urls.Delete(ctx, deleted.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ingester.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	// Only the clicks on the deleted link are lost
+	if stats := ingester.Stats(); stats.Written != 8 || stats.Failed != 2 {
+		t.Errorf("Stats() = %+v, want 8 written and 2 failed", stats)
+	}
+	if stored, _ := clicks.GetByURLID(ctx, url.ID, 100, 0); len(stored) != 8 {
+		t.Errorf("%d clicks stored, want 8", len(stored))
+	}
+	if got, _ := urls.GetByID(ctx, url.ID); got.ClickCount != 8 {
+		t.Errorf("ClickCount = %d, want 8", got.ClickCount)
+	}
+}
+
+// slowLocator answers lookups of 192.0.2.x at once and hangs on any other
+// address until the lookup is cancelled.
+type slowLocator struct {
+	inFlight, maxInFlight atomic.Int64
+}
+
+func (l *slowLocator) Lookup(ctx context.Context, ip string) (*geoip.GeoInfo, error) {
+	n := l.inFlight.Add(1)
+	defer l.inFlight.Add(-1)
+	for {
+		m := l.maxInFlight.Load()
+		if n <= m || l.maxInFlight.CompareAndSwap(m, n) {
+			break
+		}
+	}
+
+	if strings.HasPrefix(ip, "192.0.2.") {
+		return &geoip.GeoInfo{Country: "Germany", CountryCode: "DE", City: "Berlin"}, nil
+	}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestClickWriterGeoLookupsDoNotHoldUpBatch(t *testing.T) {
+	locator := &slowLocator{}
+	clicks := memory.NewClickRepository()
+	w := clickWriter{
+		urlRepo:     memory.NewURLRepository(),
+		clickRepo:   clicks,
+		geoipClient: locator,
+		geoTimeout:  100 * time.Millisecond,
+	}
+
+	var batch []*entity.Click
+	for i := range 50 {
+		batch = append(batch, &entity.Click{URLID: "url-1", IPAddress: fmt.Sprintf("198.51.100.%d", i)})
+	}
+
+	start := time.Now()
+	if _, err := w.write(context.Background(), batch); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("write() took %v, want it bounded by the geo timeout", elapsed)
+	}
+	if got := locator.maxInFlight.Load(); got > geoLookupConcurrency {
+		t.Errorf("%d lookups in flight, want at most %d", got, geoLookupConcurrency)
+	}
+	if stored, _ := clicks.GetByURLID(context.Background(), "url-1", 100, 0); len(stored) != len(batch) {
+		t.Errorf("%d clicks stored, want %d", len(stored), len(batch))
+	}
+
+	// Lookups that come back in time are not held up by those that do not
+	located := []*entity.Click{
+		{URLID: "url-2", IPAddress: "192.0.2.1"},
+		{URLID: "url-2", IPAddress: "192.0.2.1"},
+		{URLID: "url-2", IPAddress: "198.51.100.1"},
+	}
+	if _, err := w.write(context.Background(), located); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	for _, click := range located[:2] {
+		if click.City != "Berlin" || click.CountryCode != "DE" {
+			t.Errorf("located click = %q, %q, want Berlin, DE", click.City, click.CountryCode)
+		}
+	}
+}
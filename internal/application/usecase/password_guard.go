@@ -0,0 +1,241 @@
+package usecase
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/netip"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+const (
+	defaultFreeAttemptsPerLink = 20
+	defaultFreeAttemptsPerIP   = 5
+	defaultBaseLockout         = 30 * time.Second
+	defaultMaxLockout          = time.Hour
+	defaultAttemptWindow       = 24 * time.Hour
+
+	lockoutScopeLink = "link"
+	lockoutScopeIP   = "ip"
+	lockoutScopeTry  = "try"
+)
+
+// LockoutError wraps ErrTooManyAttempts with how long the lockout lasts.
+type LockoutError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LockoutError) Error() string {
+	return fmt.Sprintf("%v, retry in %v", ErrTooManyAttempts, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LockoutError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// PasswordLockoutConfig sets how wrong passwords are throttled. Every failure
+// past the free ones doubles the lockout, from BaseLockout up to MaxLockout.
+type PasswordLockoutConfig struct {
+	FreeAttemptsPerLink int
+	FreeAttemptsPerIP   int
+	BaseLockout         time.Duration
+	MaxLockout          time.Duration
+	Window              time.Duration // from the first failure
+}
+
+type passwordGuard struct {
+	attempts repository.PasswordAttemptRepository
+	audit    auditLog
+	cfg      PasswordLockoutConfig
+}
+
+func newPasswordGuard(attempts repository.PasswordAttemptRepository, audit auditLog, cfg PasswordLockoutConfig) passwordGuard {
+	if cfg.FreeAttemptsPerLink <= 0 {
+		cfg.FreeAttemptsPerLink = defaultFreeAttemptsPerLink
+	}
+	if cfg.FreeAttemptsPerIP <= 0 {
+		cfg.FreeAttemptsPerIP = defaultFreeAttemptsPerIP
+	}
+	if cfg.BaseLockout <= 0 {
+		cfg.BaseLockout = defaultBaseLockout
+	}
+	if cfg.MaxLockout <= 0 {
+		cfg.MaxLockout = defaultMaxLockout
+	}
+	cfg.MaxLockout = max(cfg.MaxLockout, cfg.BaseLockout)
+	if cfg.Window <= 0 {
+		cfg.Window = defaultAttemptWindow
+	}
+	return passwordGuard{attempts: attempts, audit: audit, cfg: cfg}
+}
+
+type attemptKey struct {
+	key   string
+	scope string
+	free  int64
+}
+
+func (g passwordGuard) keys(ctx context.Context, url *entity.URL) []attemptKey {
+	var keys []attemptKey
+	if ip := ActorFromContext(ctx).IPAddress; ip != "" {
+		client := clientNetwork(ip)
+		keys = append(keys,
+			attemptKey{key: "ip:" + client, scope: lockoutScopeIP, free: int64(g.cfg.FreeAttemptsPerIP)},
+			// Only counted, to tell a client's first try on the link
+			attemptKey{key: "try:" + client + ":" + url.ID, scope: lockoutScopeTry, free: math.MaxInt32},
+		)
+	}
+	return append(keys, attemptKey{key: "code:" + url.ID, scope: lockoutScopeLink, free: int64(g.cfg.FreeAttemptsPerLink)})
+}
+
+// clientNetwork counts IPv6 clients per /64, as a host is usually handed a
+// whole one.
+func clientNetwork(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ip
+	}
+	addr = addr.WithZone("").Unmap()
+	if addr.Is4() {
+		return addr.String()
+	}
+	prefix, _ := addr.Prefix(64)
+	return prefix.String()
+}
+
+func (g passwordGuard) policy(k attemptKey) repository.AttemptPolicy {
+	return repository.AttemptPolicy{
+		Free:        k.free,
+		Window:      g.cfg.Window,
+		BaseLockout: g.cfg.BaseLockout,
+		MaxLockout:  g.cfg.MaxLockout,
+	}
+}
+
+type reservedAttempt struct {
+	attemptKey
+	repository.AttemptReservation
+}
+
+// reserve counts an attempt before the password is checked, so concurrent
+// guesses cannot all get in ahead of a lockout. A failing store lets the
+// attempt through. A client's first try on the link counts towards the
+// link's lockout but is not held to it, so guessing cannot lock everyone out.
+func (g passwordGuard) reserve(ctx context.Context, url *entity.URL) ([]reservedAttempt, error) {
+	if g.attempts == nil {
+		return nil, nil
+	}
+
+	var reserved []reservedAttempt
+	firstTry := false
+	for _, k := range g.keys(ctx, url) {
+		count := g.attempts.Reserve
+		if k.scope == lockoutScopeLink && firstTry {
+			count = g.attempts.Count
+		}
+
+		reservation, err := count(ctx, k.key, g.policy(k))
+		if err != nil {
+			continue
+		}
+		if !reservation.Allowed {
+			// No password is checked, so give back the other keys' attempts
+			g.release(ctx, reserved)
+			return nil, &LockoutError{RetryAfter: reservation.LockedFor}
+		}
+		reserved = append(reserved, reservedAttempt{attemptKey: k, AttemptReservation: reservation})
+		firstTry = k.scope == lockoutScopeTry && reservation.Attempts == 1
+	}
+	return reserved, nil
+}
+
+func (g passwordGuard) release(ctx context.Context, reserved []reservedAttempt) {
+	for _, r := range reserved {
+		_ = g.attempts.Release(ctx, r.key, g.policy(r.attemptKey))
+	}
+}
+
+func (g passwordGuard) fail(ctx context.Context, url *entity.URL, reserved []reservedAttempt) error {
+	if lockedFor := g.recordLockouts(ctx, url, reserved); lockedFor > 0 {
+		return &LockoutError{RetryAfter: lockedFor}
+	}
+	return ErrInvalidPassword
+}
+
+// succeed keeps the client's earlier failures, so unlocking a link of one's
+// own does not buy guesses on others.
+func (g passwordGuard) succeed(ctx context.Context, url *entity.URL, reserved []reservedAttempt) {
+	if g.attempts == nil {
+		return
+	}
+	for _, k := range g.keys(ctx, url) {
+		if k.scope != lockoutScopeIP {
+			_ = g.attempts.Reset(ctx, k.key)
+		}
+	}
+	for _, r := range reserved {
+		if r.scope == lockoutScopeIP {
+			_ = g.attempts.Release(ctx, r.key, g.policy(r.attemptKey))
+		}
+	}
+}
+
+// recordLockouts returns the longest lockout the attempts started.
+func (g passwordGuard) recordLockouts(ctx context.Context, url *entity.URL, reserved []reservedAttempt) time.Duration {
+	var lockedFor time.Duration
+	for _, r := range reserved {
+		if r.LockedFor <= 0 {
+			continue
+		}
+		lockedFor = max(lockedFor, r.LockedFor)
+
+		g.audit.record(ctx, "", entity.AuditActionURLPasswordLockout, entity.AuditTargetURL, url.ID, url.WorkspaceID, nil, passwordLockout{
+			Scope:     r.scope,
+			Failures:  r.Attempts,
+			LockedFor: r.LockedFor.String(),
+		})
+	}
+	return lockedFor
+}
+
+type passwordLockout struct {
+	Scope     string `json:"scope"`
+	Failures  int64  `json:"failures"`
+	LockedFor string `json:"locked_for"`
+}
+
+func (g passwordGuard) countLockouts(ctx context.Context, url *entity.URL, from, to time.Time) (int64, *time.Time, error) {
+	if g.audit.repo == nil {
+		return 0, nil, nil
+	}
+
+	filter := entity.AuditFilter{
+		Action:     entity.AuditActionURLPasswordLockout,
+		TargetType: entity.AuditTargetURL,
+		TargetID:   url.ID,
+		From:       &from,
+		To:         &to,
+		Limit:      maxAuditPageSize,
+	}
+
+	var count int64
+	var last *time.Time
+	for {
+		events, err := g.audit.repo.List(ctx, filter)
+		if err != nil {
+			return 0, nil, err
+		}
+		if last == nil && len(events) > 0 {
+			last = &events[0].CreatedAt
+		}
+		count += int64(len(events))
+
+		if len(events) < filter.Limit {
+			return count, last, nil
+		}
+		filter.Cursor = events[len(events)-1].ID
+	}
+}
@@ -0,0 +1,161 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/pkg/nanoid"
+)
+
+const (
+	maxTargetingRules  = 20
+	targetingRuleIDLen = 8
+)
+
+var (
+	ErrTargetingRuleNotFound    = errors.New("targeting rule not found")
+	ErrTooManyTargetingRules    = errors.New("too many targeting rules")
+	ErrEmptyTargetingRule       = errors.New("targeting rule needs a device, an os or a country")
+	ErrCountryTargetingDisabled = errors.New("country targeting needs a country database")
+)
+
+// Route returns where a visit to url goes: the destination of the first
+// targeting rule the visitor matches, or the link's original URL, which is
+// the fallback destination, when none does. It records the visitor's country
+// and the matched rule on click.
+func (uc *URLUseCase) Route(url *entity.URL, click *entity.Click) string {
+	// The lookup is local, so every click gets a country, not only those
+	// on links with country rules
+	if uc.countries != nil && click.CountryCode == "" {
+		click.CountryCode = uc.countries.Country(click.IPAddress)
+	}
+
+	rule := url.MatchTargetingRule(click)
+	if rule == nil {
+		return url.OriginalURL
+	}
+	click.TargetingRuleID = rule.ID
+	return rule.URL
+}
+
+// GetTargetingRules returns the rules of a link in the order they are tried.
+func (uc *URLUseCase) GetTargetingRules(ctx context.Context, id, userID string) ([]entity.TargetingRule, error) {
+	url, err := uc.getAuthorizedURL(ctx, id, userID, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	if url.TargetingRules == nil {
+		return []entity.TargetingRule{}, nil
+	}
+	return url.TargetingRules, nil
+}
+
+// AddTargetingRule appends a rule, so it is tried after the existing ones.
+func (uc *URLUseCase) AddTargetingRule(ctx context.Context, id, userID string, req entity.TargetingRuleRequest) (*entity.TargetingRule, error) {
+	if err := uc.validateTargetingRule(req); err != nil {
+		return nil, err
+	}
+
+	ruleID, err := nanoid.Generate(targetingRuleIDLen)
+	if err != nil {
+		return nil, err
+	}
+	rule := newTargetingRule(ruleID, req)
+
+	err = uc.editTargetingRules(ctx, id, userID, func(rules []entity.TargetingRule) ([]entity.TargetingRule, error) {
+		if len(rules) >= maxTargetingRules {
+			return nil, ErrTooManyTargetingRules
+		}
+		return append(slices.Clone(rules), rule), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// UpdateTargetingRule replaces the conditions and destination of a rule,
+// keeping its place in the order.
+func (uc *URLUseCase) UpdateTargetingRule(ctx context.Context, id, ruleID, userID string, req entity.TargetingRuleRequest) (*entity.TargetingRule, error) {
+	if err := uc.validateTargetingRule(req); err != nil {
+		return nil, err
+	}
+
+	rule := newTargetingRule(ruleID, req)
+
+	err := uc.editTargetingRules(ctx, id, userID, func(rules []entity.TargetingRule) ([]entity.TargetingRule, error) {
+		i := indexTargetingRule(rules, ruleID)
+		if i < 0 {
+			return nil, ErrTargetingRuleNotFound
+		}
+		rules = slices.Clone(rules)
+		rules[i] = rule
+		return rules, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (uc *URLUseCase) DeleteTargetingRule(ctx context.Context, id, ruleID, userID string) error {
+	return uc.editTargetingRules(ctx, id, userID, func(rules []entity.TargetingRule) ([]entity.TargetingRule, error) {
+		i := indexTargetingRule(rules, ruleID)
+		if i < 0 {
+			return nil, ErrTargetingRuleNotFound
+		}
+		return slices.Delete(slices.Clone(rules), i, i+1), nil
+	})
+}
+
+// editTargetingRules stores a link's new rules as an update of the link, so
+// they show up in its revisions and audit log like any other edit. edit must
+// not change the rules it is given.
+func (uc *URLUseCase) editTargetingRules(ctx context.Context, id, userID string, edit func(rules []entity.TargetingRule) ([]entity.TargetingRule, error)) error {
+	before, url, err := uc.editURL(ctx, id, userID, entity.RevisionActionUpdate, func(url *entity.URL) error {
+		rules, err := edit(url.TargetingRules)
+		if err != nil {
+			return err
+		}
+		url.TargetingRules = rules
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionURLUpdate, entity.AuditTargetURL, url.ID, url.WorkspaceID, before, url)
+	return nil
+}
+
+func (uc *URLUseCase) validateTargetingRule(req entity.TargetingRuleRequest) error {
+	if req.Device == "" && req.OS == "" && req.Country == "" {
+		return ErrEmptyTargetingRule
+	}
+	// A country rule that can never match would be a silent no-op
+	if req.Country != "" && uc.countries == nil {
+		return ErrCountryTargetingDisabled
+	}
+	if !isValidURL(req.URL) {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+func newTargetingRule(id string, req entity.TargetingRuleRequest) entity.TargetingRule {
+	return entity.TargetingRule{
+		ID:      id,
+		Device:  req.Device,
+		OS:      req.OS,
+		Country: strings.ToUpper(req.Country),
+		URL:     req.URL,
+	}
+}
+
+func indexTargetingRule(rules []entity.TargetingRule, id string) int {
+	return slices.IndexFunc(rules, func(r entity.TargetingRule) bool { return r.ID == id })
+}
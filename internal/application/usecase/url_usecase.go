@@ -2,6 +2,10 @@ package usecase
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"strings"
 	"time"
@@ -9,52 +13,134 @@ import (
 	"github.com/bimakw/url-shortener/internal/domain/entity"
 	"github.com/bimakw/url-shortener/internal/domain/repository"
 	"github.com/bimakw/url-shortener/pkg/geoip"
+	"github.com/bimakw/url-shortener/pkg/jwt"
 	"github.com/bimakw/url-shortener/pkg/nanoid"
 	"github.com/bimakw/url-shortener/pkg/preview"
 	"github.com/bimakw/url-shortener/pkg/utm"
 	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/singleflight"
 )
 
 var (
-	ErrURLNotFound         = errors.New("url not found")
-	ErrURLExpired          = errors.New("url has expired")
-	ErrURLInactive         = errors.New("url is inactive")
-	ErrAliasExists         = errors.New("custom alias already exists")
-	ErrInvalidURL          = errors.New("invalid url")
-	ErrShortCodeExists     = errors.New("short code already exists")
-	ErrPasswordRequired    = errors.New("password required")
-	ErrInvalidPassword     = errors.New("invalid password")
+	ErrURLNotFound      = errors.New("url not found")
+	ErrURLExpired       = errors.New("url has expired")
+	ErrURLInactive      = errors.New("url is inactive")
+	ErrAliasExists      = errors.New("custom alias already exists")
+	ErrInvalidURL       = errors.New("invalid url")
+	ErrShortCodeExists  = errors.New("short code already exists")
+	ErrPasswordRequired = errors.New("password required")
+	ErrInvalidPassword  = errors.New("invalid password")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrRevisionNotFound = errors.New("revision not found")
+	ErrTooManyAttempts  = errors.New("too many password attempts")
+)
+
+const (
+	defaultNotFoundTTL    = 30 * time.Second
+	defaultUnlockTTL      = 10 * time.Minute
+	defaultRedirectType   = entity.RedirectMovedPermanently
+	defaultRedirectMaxAge = time.Hour
 )
 
 type URLUseCase struct {
-	urlRepo      repository.URLRepository
-	urlCache     repository.URLCacheRepository
-	clickRepo    repository.ClickRepository
-	geoipClient  *geoip.Client
-	baseURL      string
-	codeLength   int
+	urlRepo       repository.URLRepository
+	urlCache      repository.URLCacheRepository
+	clickRepo     repository.ClickRepository
+	revisionRepo  repository.URLRevisionRepository
+	workspaceRepo repository.WorkspaceRepository
+	audit         auditLog
+	passwords     passwordGuard
+	clicks        clickWriter
+	clickIngester *ClickIngester
+	notFoundTTL   time.Duration
+	lookups       singleflight.Group // database lookups by short code
+	unlockKey     []byte
+	unlockTTL     time.Duration
+	redirectType  int
+	redirectAge   time.Duration
+	countries     *geoip.CountryDB
+	baseURL       string
+	codeLength    int
 }
 
 type URLUseCaseConfig struct {
-	URLRepo     repository.URLRepository
-	URLCache    repository.URLCacheRepository
-	ClickRepo   repository.ClickRepository
-	GeoIPClient *geoip.Client
-	BaseURL     string
-	CodeLength  int
+	URLRepo       repository.URLRepository
+	URLCache      repository.URLCacheRepository
+	ClickRepo     repository.ClickRepository
+	RevisionRepo  repository.URLRevisionRepository
+	WorkspaceRepo repository.WorkspaceRepository
+	AuditRepo     repository.AuditRepository
+	GeoIPClient   GeoLocator
+	// ClickIngester records clicks in the background. Without one, clicks
+	// are written before RecordClick returns.
+	ClickIngester *ClickIngester
+	// NotFoundTTL is how long the cache remembers that a short code does
+	// not exist.
+	NotFoundTTL time.Duration
+	// UnlockSecret signs the tokens that open password-protected links
+	// after the password was entered. Without one a random secret is used.
+	UnlockSecret string
+	UnlockTTL    time.Duration
+	BaseURL      string
+	CodeLength   int
+	// DefaultRedirectType is the status links redirect with unless they set
+	// their own. RedirectMaxAge is how long browsers may keep a permanent
+	// redirect.
+	DefaultRedirectType int
+	RedirectMaxAge      time.Duration
+	// PasswordAttemptRepo counts wrong passwords to lock out guessing.
+	// Without one, attempts are only limited by the global rate limit.
+	PasswordAttemptRepo repository.PasswordAttemptRepository
+	PasswordLockout     PasswordLockoutConfig
+	// CountryDB resolves visitors' countries while redirecting, for country
+	// targeting rules. Without one such rules cannot be added.
+	CountryDB *geoip.CountryDB
 }
 
 func NewURLUseCase(cfg URLUseCaseConfig) *URLUseCase {
 	if cfg.CodeLength <= 0 {
 		cfg.CodeLength = 8
 	}
+	if cfg.NotFoundTTL <= 0 {
+		cfg.NotFoundTTL = defaultNotFoundTTL
+	}
+	if cfg.UnlockTTL <= 0 {
+		cfg.UnlockTTL = defaultUnlockTTL
+	}
+	if cfg.UnlockSecret == "" {
+		cfg.UnlockSecret = rand.Text()
+	}
+	if !isRedirectType(cfg.DefaultRedirectType) {
+		cfg.DefaultRedirectType = defaultRedirectType
+	}
+	if cfg.RedirectMaxAge <= 0 {
+		cfg.RedirectMaxAge = defaultRedirectMaxAge
+	}
+	audit := auditLog{repo: cfg.AuditRepo}
 	return &URLUseCase{
-		urlRepo:     cfg.URLRepo,
-		urlCache:    cfg.URLCache,
-		clickRepo:   cfg.ClickRepo,
-		geoipClient: cfg.GeoIPClient,
-		baseURL:     strings.TrimSuffix(cfg.BaseURL, "/"),
-		codeLength:  cfg.CodeLength,
+		urlRepo:       cfg.URLRepo,
+		urlCache:      cfg.URLCache,
+		clickRepo:     cfg.ClickRepo,
+		revisionRepo:  cfg.RevisionRepo,
+		workspaceRepo: cfg.WorkspaceRepo,
+		audit:         audit,
+		passwords:     newPasswordGuard(cfg.PasswordAttemptRepo, audit, cfg.PasswordLockout),
+		clicks: clickWriter{
+			urlRepo:     cfg.URLRepo,
+			urlCache:    cfg.URLCache,
+			clickRepo:   cfg.ClickRepo,
+			geoipClient: cfg.GeoIPClient,
+			geoTimeout:  defaultGeoLookupTimeout,
+		},
+		clickIngester: cfg.ClickIngester,
+		notFoundTTL:   cfg.NotFoundTTL,
+		unlockKey:     deriveKey(cfg.UnlockSecret, "url-unlock"),
+		unlockTTL:     cfg.UnlockTTL,
+		redirectType:  cfg.DefaultRedirectType,
+		redirectAge:   cfg.RedirectMaxAge,
+		countries:     cfg.CountryDB,
+		baseURL:       strings.TrimSuffix(cfg.BaseURL, "/"),
+		codeLength:    cfg.CodeLength,
 	}
 }
 
@@ -64,6 +150,17 @@ func (uc *URLUseCase) CreateShortURL(ctx context.Context, req entity.CreateURLRe
 		return nil, ErrInvalidURL
 	}
 
+	if !inKeyWorkspace(ctx, req.WorkspaceID) {
+		return nil, ErrUnauthorized
+	}
+
+	// Only editors may add links to a workspace
+	if req.WorkspaceID != "" {
+		if _, err := requireRole(ctx, uc.workspaceRepo, req.WorkspaceID, req.UserID, entity.RoleEditor); err != nil {
+			return nil, err
+		}
+	}
+
 	// Generate or use custom alias
 	shortCode := req.CustomAlias
 	if shortCode == "" {
@@ -106,9 +203,11 @@ func (uc *URLUseCase) CreateShortURL(ctx context.Context, req entity.CreateURLRe
 		OriginalURL:  req.OriginalURL,
 		CustomAlias:  req.CustomAlias,
 		UserID:       req.UserID,
+		WorkspaceID:  req.WorkspaceID,
 		ExpiresAt:    expiresAt,
 		IsActive:     true,
 		PasswordHash: passwordHash,
+		RedirectType: req.RedirectType,
 	}
 
 	// Save to database
@@ -116,6 +215,12 @@ func (uc *URLUseCase) CreateShortURL(ctx context.Context, req entity.CreateURLRe
 		return nil, err
 	}
 
+	if err := uc.recordRevision(ctx, url, entity.RevisionActionCreate, req.UserID); err != nil {
+		return nil, err
+	}
+
+	uc.audit.record(ctx, req.UserID, entity.AuditActionURLCreate, entity.AuditTargetURL, url.ID, url.WorkspaceID, nil, url)
+
 	// Cache the URL
 	if uc.urlCache != nil {
 		_ = uc.urlCache.Set(ctx, url)
@@ -128,6 +233,9 @@ func (uc *URLUseCase) GetOriginalURL(ctx context.Context, shortCode string) (*en
 	// Try cache first
 	if uc.urlCache != nil {
 		url, err := uc.urlCache.Get(ctx, shortCode)
+		if errors.Is(err, repository.ErrCachedNotFound) {
+			return nil, ErrURLNotFound
+		}
 		if err == nil && url != nil {
 			if !url.CanRedirect() {
 				if url.IsExpired() {
@@ -140,7 +248,7 @@ func (uc *URLUseCase) GetOriginalURL(ctx context.Context, shortCode string) (*en
 	}
 
 	// Get from database
-	url, err := uc.urlRepo.GetByShortCode(ctx, shortCode)
+	url, err := uc.lookupShortCode(ctx, shortCode)
 	if err != nil {
 		return nil, err
 	}
@@ -156,47 +264,58 @@ func (uc *URLUseCase) GetOriginalURL(ctx context.Context, shortCode string) (*en
 		return nil, ErrURLInactive
 	}
 
-	// Cache for next time
-	if uc.urlCache != nil {
-		_ = uc.urlCache.Set(ctx, url)
-	}
-
 	return url, nil
 }
 
-func (uc *URLUseCase) RecordClick(ctx context.Context, click *entity.Click) error {
-	// Increment click count in cache
-	if uc.urlCache != nil {
-		_, _ = uc.urlCache.IncrementClickCount(ctx, click.ShortCode)
-	}
+// lookupShortCode reads a link from the database and caches the result,
+// including that it does not exist. Concurrent lookups for the same code
+// share one query, so a popular link dropping out of the cache does not
+// send every waiting redirect to the database.
+func (uc *URLUseCase) lookupShortCode(ctx context.Context, shortCode string) (*entity.URL, error) {
+	// The query is shared, so one caller going away must not cancel it
+	ctx = context.WithoutCancel(ctx)
 
-	// Increment in database
-	url, err := uc.urlRepo.GetByShortCode(ctx, click.ShortCode)
-	if err != nil || url == nil {
-		return err
-	}
-
-	click.URLID = url.ID
-
-	// Record click asynchronously (fire and forget)
-	go func() {
-		ctx := context.Background()
+	v, err, _ := uc.lookups.Do(shortCode, func() (any, error) {
+		url, err := uc.urlRepo.GetByShortCode(ctx, shortCode)
+		if err != nil {
+			return nil, err
+		}
 
-		// GeoIP lookup
-		if uc.geoipClient != nil && click.IPAddress != "" {
-			if geoInfo, err := uc.geoipClient.Lookup(ctx, click.IPAddress); err == nil && geoInfo != nil {
-				click.Country = geoInfo.Country
-				click.City = geoInfo.City
+		if uc.urlCache != nil {
+			switch {
+			case url == nil:
+				_ = uc.urlCache.SetNotFound(ctx, shortCode, uc.notFoundTTL)
+			case url.CanRedirect():
+				_ = uc.urlCache.Set(ctx, url)
 			}
 		}
+		return url, nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		_ = uc.urlRepo.IncrementClickCount(ctx, url.ID)
-		if uc.clickRepo != nil {
-			_ = uc.clickRepo.Create(ctx, click)
-		}
-	}()
+	shared := v.(*entity.URL)
+	if shared == nil {
+		return nil, nil
+	}
+	// Every caller gets its own copy
+	url := *shared
+	return &url, nil
+}
 
-	return nil
+// RecordClick records a click on url, as resolved by GetOriginalURL or
+// VerifyPassword. It does not look the link up again, so a redirect served
+// from the cache does not touch the database.
+func (uc *URLUseCase) RecordClick(ctx context.Context, url *entity.URL, click *entity.Click) error {
+	click.URLID = url.ID
+	click.CreatedAt = time.Now()
+
+	if uc.clickIngester != nil {
+		return uc.clickIngester.Enqueue(click)
+	}
+	_, err := uc.clicks.write(ctx, []*entity.Click{click})
+	return err
 }
 
 func (uc *URLUseCase) GetURLByID(ctx context.Context, id string) (*entity.URLResponse, error) {
@@ -208,9 +327,12 @@ func (uc *URLUseCase) GetURLByID(ctx context.Context, id string) (*entity.URLRes
 		return nil, ErrURLNotFound
 	}
 
+	uc.addPendingClicks(ctx, url)
 	return uc.toResponse(url), nil
 }
 
+// GetURLByShortCode returns the public info of a link. It leaves out the
+// ID, which only the link's owners need to manage it.
 func (uc *URLUseCase) GetURLByShortCode(ctx context.Context, shortCode string) (*entity.URLResponse, error) {
 	url, err := uc.urlRepo.GetByShortCode(ctx, shortCode)
 	if err != nil {
@@ -220,10 +342,17 @@ func (uc *URLUseCase) GetURLByShortCode(ctx context.Context, shortCode string) (
 		return nil, ErrURLNotFound
 	}
 
-	return uc.toResponse(url), nil
+	uc.addPendingClicks(ctx, url)
+	response := uc.toResponse(url)
+	response.ID = ""
+	return response, nil
 }
 
 func (uc *URLUseCase) GetUserURLs(ctx context.Context, userID string, limit, offset int) ([]*entity.URLResponse, error) {
+	if !inKeyWorkspace(ctx, "") {
+		return nil, ErrUnauthorized
+	}
+
 	if limit <= 0 {
 		limit = 20
 	}
@@ -233,6 +362,8 @@ func (uc *URLUseCase) GetUserURLs(ctx context.Context, userID string, limit, off
 		return nil, err
 	}
 
+	uc.addPendingClicks(ctx, urls...)
+
 	responses := make([]*entity.URLResponse, len(urls))
 	for i, url := range urls {
 		responses[i] = uc.toResponse(url)
@@ -241,26 +372,277 @@ func (uc *URLUseCase) GetUserURLs(ctx context.Context, userID string, limit, off
 	return responses, nil
 }
 
-func (uc *URLUseCase) DeleteURL(ctx context.Context, id, userID string) error {
+func (uc *URLUseCase) GetWorkspaceURLs(ctx context.Context, workspaceID, userID string, limit, offset int) ([]*entity.URLResponse, error) {
+	if _, err := requireRole(ctx, uc.workspaceRepo, workspaceID, userID, entity.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = 20
+	}
+
+	urls, err := uc.urlRepo.GetByWorkspaceID(ctx, workspaceID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	uc.addPendingClicks(ctx, urls...)
+
+	responses := make([]*entity.URLResponse, len(urls))
+	for i, url := range urls {
+		responses[i] = uc.toResponse(url)
+	}
+
+	return responses, nil
+}
+
+func (uc *URLUseCase) UpdateURL(ctx context.Context, id, userID string, req entity.UpdateURLRequest) (*entity.URLResponse, error) {
+	if req.OriginalURL != nil && !isValidURL(*req.OriginalURL) {
+		return nil, ErrInvalidURL
+	}
+
+	// Hashed ahead, so the link is not locked meanwhile
+	var passwordHash string
+	if !req.RemovePassword && req.Password != nil && *req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = string(hash)
+	}
+
+	before, url, err := uc.editURL(ctx, id, userID, entity.RevisionActionUpdate, func(url *entity.URL) error {
+		if req.OriginalURL != nil {
+			url.OriginalURL = *req.OriginalURL
+		}
+
+		// A custom alias doubles as the short code, so changing it moves the link
+		if req.CustomAlias != nil && *req.CustomAlias != "" && *req.CustomAlias != url.ShortCode {
+			exists, err := uc.urlRepo.ShortCodeExists(ctx, *req.CustomAlias)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrAliasExists
+			}
+			url.ShortCode = *req.CustomAlias
+			url.CustomAlias = *req.CustomAlias
+		}
+
+		if req.ExpiresIn != nil {
+			if *req.ExpiresIn > 0 {
+				exp := time.Now().Add(time.Duration(*req.ExpiresIn) * time.Hour)
+				url.ExpiresAt = &exp
+			} else {
+				url.ExpiresAt = nil
+			}
+		}
+
+		if req.IsActive != nil {
+			url.IsActive = *req.IsActive
+		}
+
+		if req.RemovePassword {
+			url.PasswordHash = ""
+		} else if passwordHash != "" {
+			url.PasswordHash = passwordHash
+		}
+
+		if req.RedirectType != nil {
+			url.RedirectType = *req.RedirectType
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionURLUpdate, entity.AuditTargetURL, url.ID, url.WorkspaceID, before, url)
+
+	uc.addPendingClicks(ctx, url)
+	return uc.toResponse(url), nil
+}
+
+func (uc *URLUseCase) GetURLRevisions(ctx context.Context, id, userID string) ([]*entity.URLRevisionResponse, error) {
+	url, err := uc.getAuthorizedURL(ctx, id, userID, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	if uc.revisionRepo == nil {
+		return []*entity.URLRevisionResponse{}, nil
+	}
+
+	revisions, err := uc.revisionRepo.GetByURLID(ctx, url.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*entity.URLRevisionResponse, len(revisions))
+	for i, rev := range revisions {
+		responses[i] = &entity.URLRevisionResponse{
+			Revision:          rev.Revision,
+			Action:            rev.Action,
+			ShortCode:         rev.ShortCode,
+			OriginalURL:       rev.OriginalURL,
+			ExpiresAt:         rev.ExpiresAt,
+			IsActive:          rev.IsActive,
+			PasswordProtected: rev.PasswordHash != "",
+			RedirectType:      rev.RedirectType,
+			ChangedBy:         rev.ChangedBy,
+			CreatedAt:         rev.CreatedAt,
+		}
+	}
+
+	return responses, nil
+}
+
+func (uc *URLUseCase) RestoreURLRevision(ctx context.Context, id, userID string, revision int) (*entity.URLResponse, error) {
+	if uc.revisionRepo == nil {
+		return nil, ErrRevisionNotFound
+	}
+
+	before, url, err := uc.editURL(ctx, id, userID, entity.RevisionActionRestore, func(url *entity.URL) error {
+		rev, err := uc.revisionRepo.GetByRevision(ctx, url.ID, revision)
+		if err != nil {
+			return err
+		}
+		if rev == nil {
+			return ErrRevisionNotFound
+		}
+
+		// The old code may have been taken by another link since
+		if rev.ShortCode != url.ShortCode {
+			exists, err := uc.urlRepo.ShortCodeExists(ctx, rev.ShortCode)
+			if err != nil {
+				return err
+			}
+			if exists {
+				return ErrAliasExists
+			}
+		}
+
+		url.ShortCode = rev.ShortCode
+		url.OriginalURL = rev.OriginalURL
+		url.CustomAlias = rev.CustomAlias
+		url.ExpiresAt = rev.ExpiresAt
+		url.IsActive = rev.IsActive
+		url.PasswordHash = rev.PasswordHash
+		url.RedirectType = rev.RedirectType
+		url.TargetingRules = rev.TargetingRules
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionURLRestore, entity.AuditTargetURL, url.ID, url.WorkspaceID, before, url)
+
+	uc.addPendingClicks(ctx, url)
+	return uc.toResponse(url), nil
+}
+
+// editURL lets edit change the link with the given ID, which userID must be
+// allowed to edit, and saves the change along with a new revision recording
+// action. With revisions the link is locked from being read until it is
+// saved, so concurrent edits apply one after the other and never save a
+// link without its revision. It returns the link before and after the edit
+// and invalidates the cache for both their codes.
+func (uc *URLUseCase) editURL(ctx context.Context, id, userID, action string, edit func(url *entity.URL) error) (*entity.URL, *entity.URL, error) {
+	// Edits do not move links between owners or workspaces, so who may edit
+	// one can be checked ahead
+	url, err := uc.getAuthorizedURL(ctx, id, userID, entity.RoleEditor)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var before entity.URL
+	change := func(url *entity.URL) error {
+		before = *url
+		return edit(url)
+	}
+
+	if uc.revisionRepo == nil {
+		if err := change(url); err != nil {
+			return nil, nil, err
+		}
+		if err := uc.urlRepo.Update(ctx, url); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		url, err = uc.revisionRepo.Edit(ctx, id, action, userID, change)
+		if err != nil {
+			return nil, nil, err
+		}
+		if url == nil {
+			return nil, nil, ErrURLNotFound
+		}
+	}
+
+	if uc.urlCache != nil {
+		_ = uc.urlCache.Delete(ctx, before.ShortCode)
+		if url.ShortCode != before.ShortCode {
+			_ = uc.urlCache.Delete(ctx, url.ShortCode)
+		}
+	}
+
+	return &before, url, nil
+}
+
+func (uc *URLUseCase) recordRevision(ctx context.Context, url *entity.URL, action, userID string) error {
+	if uc.revisionRepo == nil {
+		return nil
+	}
+	return uc.revisionRepo.Create(ctx, entity.NewURLRevision(url, action, userID))
+}
+
+// getAuthorizedURL loads a URL the user may act on. Workspace links need at
+// least the given role in the workspace; personal links only their creator.
+// Links created anonymously have no one who may act on them.
+func (uc *URLUseCase) getAuthorizedURL(ctx context.Context, id, userID string, min entity.WorkspaceRole) (*entity.URL, error) {
 	url, err := uc.urlRepo.GetByID(ctx, id)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	if url == nil {
-		return ErrURLNotFound
+		return nil, ErrURLNotFound
+	}
+	if !inKeyWorkspace(ctx, url.WorkspaceID) {
+		return nil, ErrUnauthorized
+	}
+
+	if url.WorkspaceID != "" {
+		if _, err := requireRole(ctx, uc.workspaceRepo, url.WorkspaceID, userID, min); err != nil {
+			return nil, err
+		}
+		return url, nil
+	}
+
+	if userID == "" || url.UserID != userID {
+		return nil, ErrUnauthorized
 	}
 
-	// Check ownership
-	if url.UserID != "" && url.UserID != userID {
-		return errors.New("unauthorized")
+	return url, nil
+}
+
+func (uc *URLUseCase) DeleteURL(ctx context.Context, id, userID string) error {
+	url, err := uc.getAuthorizedURL(ctx, id, userID, entity.RoleEditor)
+	if err != nil {
+		return err
 	}
 
-	// Delete from cache
+	if err := uc.urlRepo.Delete(ctx, id); err != nil {
+		return err
+	}
+
+	// Delete from cache once the row is gone, so a redirect in between
+	// cannot cache the link again
 	if uc.urlCache != nil {
 		_ = uc.urlCache.Delete(ctx, url.ShortCode)
 	}
 
-	return uc.urlRepo.Delete(ctx, id)
+	uc.audit.record(ctx, userID, entity.AuditActionURLDelete, entity.AuditTargetURL, url.ID, url.WorkspaceID, url, nil)
+	return nil
 }
 
 func (uc *URLUseCase) GetStats(ctx context.Context, shortCode string, from, to time.Time) (*entity.ClickStats, error) {
@@ -272,13 +654,47 @@ func (uc *URLUseCase) GetStats(ctx context.Context, shortCode string, from, to t
 		return nil, ErrURLNotFound
 	}
 
+	var stats *entity.ClickStats
 	if uc.clickRepo == nil {
-		return &entity.ClickStats{
+		uc.addPendingClicks(ctx, url)
+		stats = &entity.ClickStats{
 			TotalClicks: url.ClickCount,
-		}, nil
+		}
+	} else {
+		stats, err = uc.clickRepo.GetStatsByURLID(ctx, url.ID, from, to)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return uc.clickRepo.GetStatsByURLID(ctx, url.ID, from, to)
+	stats.PasswordLockouts, stats.LastPasswordLockout, err = uc.passwords.countLockouts(ctx, url, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// addPendingClicks adds the clicks still waiting in the cache to the links'
+// persisted counts, so responses are not behind by a flush interval. Without
+// the cache the counts are left as they are.
+func (uc *URLUseCase) addPendingClicks(ctx context.Context, urls ...*entity.URL) {
+	if uc.urlCache == nil || len(urls) == 0 {
+		return
+	}
+
+	ids := make([]string, len(urls))
+	for i, url := range urls {
+		ids[i] = url.ID
+	}
+
+	pending, err := uc.urlCache.GetPendingClicks(ctx, ids)
+	if err != nil {
+		return
+	}
+	for _, url := range urls {
+		url.ClickCount += pending[url.ID]
+	}
 }
 
 func (uc *URLUseCase) generateUniqueShortCode(ctx context.Context) (string, error) {
@@ -309,6 +725,7 @@ func (uc *URLUseCase) toResponse(url *entity.URL) *entity.URLResponse {
 	}
 
 	return &entity.URLResponse{
+		ID:                url.ID,
 		ShortCode:         url.ShortCode,
 		ShortURL:          shortURL,
 		OriginalURL:       url.OriginalURL,
@@ -317,9 +734,54 @@ func (uc *URLUseCase) toResponse(url *entity.URL) *entity.URLResponse {
 		ClickCount:        url.ClickCount,
 		QRCodeURL:         uc.baseURL + "/api/urls/" + url.ShortCode + "/qr",
 		PasswordProtected: url.PasswordHash != "",
+		IsActive:          url.IsActive,
+		WorkspaceID:       url.WorkspaceID,
+		RedirectType:      uc.redirectTypeOf(url),
 	}
 }
 
+// RedirectFor returns the status to redirect to url with and, for permanent
+// redirects, how long browsers may cache it; zero means not at all.
+// Password-protected links always redirect temporarily, or the browser would
+// skip the password check from then on. Links with targeting rules are never
+// cached, or a visitor would keep the destination chosen for them even after
+// their device, OS or country changes.
+func (uc *URLUseCase) RedirectFor(url *entity.URL) (int, time.Duration) {
+	status := uc.redirectTypeOf(url)
+	if status != entity.RedirectMovedPermanently && status != entity.RedirectPermanentRedirect {
+		return status, 0
+	}
+	if len(url.TargetingRules) > 0 {
+		return status, 0
+	}
+
+	// A browser must come back once the link expires
+	maxAge := uc.redirectAge
+	if url.ExpiresAt != nil {
+		maxAge = min(maxAge, time.Until(*url.ExpiresAt))
+	}
+	return status, max(maxAge, 0)
+}
+
+func (uc *URLUseCase) redirectTypeOf(url *entity.URL) int {
+	switch {
+	case url.PasswordHash != "":
+		return entity.RedirectFound
+	case isRedirectType(url.RedirectType):
+		return url.RedirectType
+	default:
+		return uc.redirectType
+	}
+}
+
+func isRedirectType(status int) bool {
+	switch status {
+	case entity.RedirectMovedPermanently, entity.RedirectFound, entity.RedirectTemporaryRedirect, entity.RedirectPermanentRedirect:
+		return true
+	}
+	return false
+}
+
 func isValidURL(u string) bool {
 	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://")
 }
@@ -341,6 +803,8 @@ func (uc *URLUseCase) BulkCreateShortURLs(ctx context.Context, req entity.BulkCr
 				result.Error = "custom alias already exists"
 			case ErrInvalidURL:
 				result.Error = "invalid URL format"
+			case ErrUnauthorized:
+				result.Error = "not allowed to add URLs to this workspace"
 			default:
 				result.Error = "failed to create short URL"
 			}
@@ -397,13 +861,60 @@ func (uc *URLUseCase) VerifyPassword(ctx context.Context, shortCode, password st
 		return nil, ErrPasswordRequired
 	}
 
+	reserved, err := uc.passwords.reserve(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(url.PasswordHash), []byte(password)); err != nil {
-		return nil, ErrInvalidPassword
+		return nil, uc.passwords.fail(ctx, url, reserved)
 	}
+	uc.passwords.succeed(ctx, url, reserved)
 
 	return url, nil
 }
 
+// UnlockToken returns a token proving the password of url was entered. It
+// expires after the unlock TTL and as soon as the password changes.
+func (uc *URLUseCase) UnlockToken(url *entity.URL) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(uc.unlockTTL)
+
+	token, err := jwt.Sign(jwt.Claims{
+		Subject:   unlockSubject(url),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiresAt.Unix(),
+	}, uc.unlockKey)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return token, expiresAt, nil
+}
+
+// IsUnlocked reports whether token came from UnlockToken for url and is
+// still valid.
+func (uc *URLUseCase) IsUnlocked(url *entity.URL, token string) bool {
+	claims, err := jwt.Parse(token, uc.unlockKey)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(claims.Subject), []byte(unlockSubject(url)))
+}
+
+// unlockSubject ties a token to the link and its current password.
+func unlockSubject(url *entity.URL) string {
+	sum := sha256.Sum256([]byte(url.PasswordHash))
+	return url.ID + ":" + hex.EncodeToString(sum[:8])
+}
+
+// deriveKey derives a key for one purpose from a shared secret, so tokens
+// signed for one purpose are never accepted for another.
+func deriveKey(secret, purpose string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
 func (uc *URLUseCase) IsPasswordProtected(ctx context.Context, shortCode string) (bool, error) {
 	url, err := uc.urlRepo.GetByShortCode(ctx, shortCode)
 	if err != nil {
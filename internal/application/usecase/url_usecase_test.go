@@ -0,0 +1,981 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/pkg/geoip"
+)
+
+type urlFixture struct {
+	uc         *URLUseCase
+	urls       *memory.URLRepository
+	cache      *memory.URLCacheRepository
+	clicks     *memory.ClickRepository
+	revisions  *memory.URLRevisionRepository
+	workspaces *memory.WorkspaceRepository
+	audit      *memory.AuditRepository
+}
+
+func newURLFixture() *urlFixture {
+	urls := memory.NewURLRepository()
+	f := &urlFixture{
+		urls:       urls,
+		cache:      memory.NewURLCacheRepository(time.Hour),
+		clicks:     memory.NewClickRepository(),
+		revisions:  memory.NewURLRevisionRepository(urls),
+		workspaces: memory.NewWorkspaceRepository(nil),
+		audit:      memory.NewAuditRepository(),
+	}
+	f.uc = NewURLUseCase(URLUseCaseConfig{
+		URLRepo:       f.urls,
+		URLCache:      f.cache,
+		ClickRepo:     f.clicks,
+		RevisionRepo:  f.revisions,
+		WorkspaceRepo: f.workspaces,
+		AuditRepo:     f.audit,
+		BaseURL:       "https://sho.rt/",
+		CodeLength:    6,
+	})
+	return f
+}
+
+// eventually polls cond until it holds, for work the use case does in the
+// background.
+func eventually(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestCreateShortURL(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, err := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() error = %v", err)
+	}
+	if len(resp.ShortCode) != 6 || resp.ShortURL != "https://sho.rt/"+resp.ShortCode || !resp.IsActive {
+		t.Errorf("CreateShortURL() = %+v", resp)
+	}
+
+	if cached, _ := f.cache.Get(ctx, resp.ShortCode); cached == nil {
+		t.Error("new link was not cached")
+	}
+	if revisions, _ := f.revisions.GetByURLID(ctx, resp.ID); len(revisions) != 1 || revisions[0].Action != entity.RevisionActionCreate {
+		t.Errorf("revisions = %v, want one create revision", revisions)
+	}
+	if events, _ := f.audit.List(ctx, entity.AuditFilter{Limit: 10}); len(events) != 1 || events[0].Action != entity.AuditActionURLCreate {
+		t.Errorf("audit events = %v, want one url.create", events)
+	}
+}
+
+// failingAudit rejects every audit event.
+type failingAudit struct {
+	*memory.AuditRepository
+}
+
+func (r failingAudit) Create(ctx context.Context, event *entity.AuditEvent) error {
+	return errors.New("database is down")
+}
+
+func TestAuditFailureKeepsChange(t *testing.T) {
+	ctx := context.Background()
+	urls := memory.NewURLRepository()
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:    urls,
+		AuditRepo:  failingAudit{memory.NewAuditRepository()},
+		BaseURL:    "https://sho.rt/",
+		CodeLength: 6,
+	})
+
+	// The change is made before the audit event fails, so it is reported
+	resp, err := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", UserID: "user-1"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() error = %v", err)
+	}
+	if err := uc.DeleteURL(ctx, resp.ID, "user-1"); err != nil {
+		t.Fatalf("DeleteURL() error = %v", err)
+	}
+	if got, _ := urls.GetByID(ctx, resp.ID); got != nil {
+		t.Errorf("link still exists after DeleteURL()")
+	}
+}
+
+func TestCreateShortURLErrors(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	if _, err := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "taken"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  entity.CreateURLRequest
+		want error
+	}{
+		{"invalid url", entity.CreateURLRequest{OriginalURL: "ftp://example.com"}, ErrInvalidURL},
+		{"alias taken", entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "taken"}, ErrAliasExists},
+		{"foreign workspace", entity.CreateURLRequest{OriginalURL: "https://example.com", WorkspaceID: "ws-1", UserID: "user-1"}, ErrUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := f.uc.CreateShortURL(ctx, tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("CreateShortURL() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetOriginalURL(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	past := time.Now().Add(-time.Hour)
+	for _, url := range []*entity.URL{
+		{ShortCode: "live", OriginalURL: "https://example.com"},
+		{ShortCode: "expired", OriginalURL: "https://example.com", ExpiresAt: &past},
+		{ShortCode: "disabled", OriginalURL: "https://example.com"},
+	} {
+		if err := f.urls.Create(ctx, url); err != nil {
+			t.Fatal(err)
+		}
+	}
+	disabled, _ := f.urls.GetByShortCode(ctx, "disabled")
+	disabled.IsActive = false
+	_ = f.urls.Update(ctx, disabled)
+
+	tests := []struct {
+		code string
+		want error
+	}{
+		{"live", nil},
+		{"expired", ErrURLExpired},
+		{"disabled", ErrURLInactive},
+		{"missing", ErrURLNotFound},
+	}
+
+	for _, tt := range tests {
+		url, err := f.uc.GetOriginalURL(ctx, tt.code)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("GetOriginalURL(%q) error = %v, want %v", tt.code, err, tt.want)
+		}
+		if tt.want == nil && (url == nil || url.OriginalURL != "https://example.com") {
+			t.Errorf("GetOriginalURL(%q) = %v", tt.code, url)
+		}
+	}
+
+	// A cache hit is checked just like a database row
+	if cached, _ := f.cache.Get(ctx, "live"); cached == nil {
+		t.Fatal("GetOriginalURL() did not cache the link")
+	}
+	_ = f.urls.Delete(ctx, mustGetURL(t, f, "live").ID)
+	if _, err := f.uc.GetOriginalURL(ctx, "live"); err != nil {
+		t.Errorf("GetOriginalURL() from cache error = %v", err)
+	}
+}
+
+func TestRecordClick(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com"})
+	url := mustGetURL(t, f, resp.ShortCode)
+	if err := f.uc.RecordClick(ctx, url, &entity.Click{ShortCode: resp.ShortCode, IPAddress: "10.0.0.1"}); err != nil {
+		t.Fatalf("RecordClick() error = %v", err)
+	}
+
+	if count, _ := f.clicks.CountByURLID(ctx, resp.ID); count != 1 {
+		t.Errorf("stored clicks = %d, want 1", count)
+	}
+
+	// The count waits in the cache, but responses include it already
+	if got := mustGetURL(t, f, resp.ShortCode); got.ClickCount != 0 {
+		t.Errorf("persisted ClickCount = %d, want 0 before a flush", got.ClickCount)
+	}
+	if got, _ := f.uc.GetURLByID(ctx, resp.ID); got.ClickCount != 1 {
+		t.Errorf("GetURLByID() ClickCount = %d, want 1", got.ClickCount)
+	}
+	if got, _ := f.uc.GetUserURLs(ctx, "", 10, 0); len(got) != 1 || got[0].ClickCount != 1 {
+		t.Errorf("GetUserURLs() = %v, want one link with 1 click", got)
+	}
+}
+
+// lookupCounter counts the links read from the database. If release is
+// set, lookups by short code wait until it is closed.
+type lookupCounter struct {
+	*memory.URLRepository
+	lookups atomic.Int64
+	release chan struct{}
+}
+
+func (r *lookupCounter) GetByShortCode(ctx context.Context, shortCode string) (*entity.URL, error) {
+	r.lookups.Add(1)
+	if r.release != nil {
+		<-r.release
+	}
+	return r.URLRepository.GetByShortCode(ctx, shortCode)
+}
+
+func (r *lookupCounter) GetByID(ctx context.Context, id string) (*entity.URL, error) {
+	r.lookups.Add(1)
+	return r.URLRepository.GetByID(ctx, id)
+}
+
+func TestCachedRedirectSkipsDatabase(t *testing.T) {
+	ctx := context.Background()
+	urls := &lookupCounter{URLRepository: memory.NewURLRepository()}
+	clicks := memory.NewClickRepository()
+	ingester := NewClickIngester(ClickIngesterConfig{URLRepo: urls, ClickRepo: clicks, FlushInterval: time.Hour})
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:       urls,
+		URLCache:      memory.NewURLCacheRepository(time.Hour),
+		ClickRepo:     clicks,
+		ClickIngester: ingester,
+	})
+
+	resp, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com"})
+	urls.lookups.Store(0)
+
+	for range 3 {
+		url, err := uc.GetOriginalURL(ctx, resp.ShortCode)
+		if err != nil {
+			t.Fatalf("GetOriginalURL() error = %v", err)
+		}
+		if err := uc.RecordClick(ctx, url, &entity.Click{ShortCode: resp.ShortCode}); err != nil {
+			t.Fatalf("RecordClick() error = %v", err)
+		}
+	}
+	if n := urls.lookups.Load(); n != 0 {
+		t.Errorf("database lookups = %d, want 0", n)
+	}
+
+	if err := ingester.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if count, _ := clicks.CountByURLID(ctx, resp.ID); count != 3 {
+		t.Errorf("stored clicks = %d, want 3", count)
+	}
+}
+
+func TestGetOriginalURLCachesNotFound(t *testing.T) {
+	ctx := context.Background()
+	urls := &lookupCounter{URLRepository: memory.NewURLRepository()}
+	uc := NewURLUseCase(URLUseCaseConfig{URLRepo: urls, URLCache: memory.NewURLCacheRepository(time.Hour)})
+
+	for range 3 {
+		if _, err := uc.GetOriginalURL(ctx, "wp-admin"); !errors.Is(err, ErrURLNotFound) {
+			t.Fatalf("GetOriginalURL() error = %v, want ErrURLNotFound", err)
+		}
+	}
+	if n := urls.lookups.Load(); n != 1 {
+		t.Errorf("database lookups = %d, want 1", n)
+	}
+
+	// A link created with the code is found right away
+	if _, err := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "wp-admin"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := uc.GetOriginalURL(ctx, "wp-admin"); err != nil {
+		t.Errorf("GetOriginalURL() after create error = %v", err)
+	}
+}
+
+func TestGetOriginalURLCoalescesLookups(t *testing.T) {
+	ctx := context.Background()
+	urls := &lookupCounter{URLRepository: memory.NewURLRepository(), release: make(chan struct{})}
+	uc := NewURLUseCase(URLUseCaseConfig{URLRepo: urls, URLCache: memory.NewURLCacheRepository(time.Hour)})
+
+	if err := urls.Create(ctx, &entity.URL{ShortCode: "viral", OriginalURL: "https://example.com", IsActive: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	const redirects = 20
+	var wg sync.WaitGroup
+	for range redirects {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if url, err := uc.GetOriginalURL(ctx, "viral"); err != nil || url.OriginalURL != "https://example.com" {
+				t.Errorf("GetOriginalURL() = %v, %v", url, err)
+			}
+		}()
+	}
+
+	// Let every redirect queue up behind the first lookup
+	eventually(t, func() bool { return urls.lookups.Load() == 1 })
+	time.Sleep(50 * time.Millisecond)
+	close(urls.release)
+	wg.Wait()
+
+	if n := urls.lookups.Load(); n != 1 {
+		t.Errorf("database lookups = %d, want 1", n)
+	}
+}
+
+func TestRecordClickWithoutCache(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+	f.uc.urlCache = nil
+	f.uc.clicks.urlCache = nil
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com"})
+	url := mustGetURL(t, f, resp.ShortCode)
+	if err := f.uc.RecordClick(ctx, url, &entity.Click{ShortCode: resp.ShortCode}); err != nil {
+		t.Fatalf("RecordClick() error = %v", err)
+	}
+
+	if got := mustGetURL(t, f, resp.ShortCode); got.ClickCount != 1 {
+		t.Errorf("ClickCount = %d, want 1", got.ClickCount)
+	}
+}
+
+func TestUpdateURL(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", CustomAlias: "before", UserID: "user-1"})
+
+	alias := "after"
+	if _, err := f.uc.UpdateURL(ctx, resp.ID, "user-2", entity.UpdateURLRequest{CustomAlias: &alias}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("UpdateURL() by another user error = %v, want ErrUnauthorized", err)
+	}
+
+	updated, err := f.uc.UpdateURL(ctx, resp.ID, "user-1", entity.UpdateURLRequest{CustomAlias: &alias})
+	if err != nil {
+		t.Fatalf("UpdateURL() error = %v", err)
+	}
+	if updated.ShortCode != "after" {
+		t.Errorf("ShortCode = %q, want after", updated.ShortCode)
+	}
+	if cached, _ := f.cache.Get(ctx, "before"); cached != nil {
+		t.Error("old code still cached after the alias changed")
+	}
+
+	restored, err := f.uc.RestoreURLRevision(ctx, resp.ID, "user-1", 1)
+	if err != nil {
+		t.Fatalf("RestoreURLRevision() error = %v", err)
+	}
+	if restored.ShortCode != "before" {
+		t.Errorf("restored ShortCode = %q, want before", restored.ShortCode)
+	}
+
+	revisions, _ := f.uc.GetURLRevisions(ctx, resp.ID, "user-1")
+	if len(revisions) != 3 || revisions[0].Action != entity.RevisionActionRestore {
+		t.Errorf("GetURLRevisions() = %v, want create, update and restore", revisions)
+	}
+
+	if _, err := f.uc.RestoreURLRevision(ctx, resp.ID, "user-1", 9); !errors.Is(err, ErrRevisionNotFound) {
+		t.Errorf("RestoreURLRevision(9) error = %v, want ErrRevisionNotFound", err)
+	}
+	anonymous, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com"})
+	for _, userID := range []string{"", "user-1"} {
+		if _, err := f.uc.UpdateURL(ctx, anonymous.ID, userID, entity.UpdateURLRequest{CustomAlias: &alias}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("UpdateURL() of an anonymous link by %q error = %v, want ErrUnauthorized", userID, err)
+		}
+		if err := f.uc.DeleteURL(ctx, anonymous.ID, userID); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("DeleteURL() of an anonymous link by %q error = %v, want ErrUnauthorized", userID, err)
+		}
+	}
+}
+
+func TestRedirectFor(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	soon := time.Now().Add(10 * time.Minute)
+	tests := []struct {
+		name       string
+		url        entity.URL
+		wantStatus int
+		maxAge     time.Duration // upper bound, 0 for none
+	}{
+		{"server default", entity.URL{}, entity.RedirectMovedPermanently, defaultRedirectMaxAge},
+		{"temporary", entity.URL{RedirectType: entity.RedirectTemporaryRedirect}, entity.RedirectTemporaryRedirect, 0},
+		{"found", entity.URL{RedirectType: entity.RedirectFound}, entity.RedirectFound, 0},
+		{"permanent until expiry", entity.URL{RedirectType: entity.RedirectPermanentRedirect, ExpiresAt: &soon}, entity.RedirectPermanentRedirect, 10 * time.Minute},
+		{"password protected", entity.URL{RedirectType: entity.RedirectMovedPermanently, PasswordHash: "hash"}, entity.RedirectFound, 0},
+		{"targeted", entity.URL{RedirectType: entity.RedirectMovedPermanently, TargetingRules: []entity.TargetingRule{{OS: "iOS"}}}, entity.RedirectMovedPermanently, 0},
+	}
+	for _, tt := range tests {
+		status, maxAge := f.uc.RedirectFor(&tt.url)
+		if status != tt.wantStatus || maxAge > tt.maxAge || (tt.maxAge > 0) != (maxAge > 0) {
+			t.Errorf("%s: RedirectFor() = %d, %v, want %d, up to %v", tt.name, status, maxAge, tt.wantStatus, tt.maxAge)
+		}
+	}
+
+	uc := NewURLUseCase(URLUseCaseConfig{URLRepo: f.urls, RevisionRepo: f.revisions, DefaultRedirectType: entity.RedirectFound})
+	if status, maxAge := uc.RedirectFor(&entity.URL{}); status != entity.RedirectFound || maxAge != 0 {
+		t.Errorf("RedirectFor() with a temporary default = %d, %v", status, maxAge)
+	}
+
+	// Links can switch back to the server default, and revisions keep the type
+	resp, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", RedirectType: entity.RedirectPermanentRedirect, UserID: "user-1"})
+	if resp.RedirectType != entity.RedirectPermanentRedirect {
+		t.Errorf("created RedirectType = %d, want 308", resp.RedirectType)
+	}
+	serverDefault := 0
+	updated, err := uc.UpdateURL(ctx, resp.ID, "user-1", entity.UpdateURLRequest{RedirectType: &serverDefault})
+	if err != nil || updated.RedirectType != entity.RedirectFound {
+		t.Errorf("UpdateURL() = %+v, %v, want the server default", updated, err)
+	}
+	restored, err := uc.RestoreURLRevision(ctx, resp.ID, "user-1", 1)
+	if err != nil || restored.RedirectType != entity.RedirectPermanentRedirect {
+		t.Errorf("RestoreURLRevision() = %+v, %v, want 308", restored, err)
+	}
+}
+
+func TestTargetingRules(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", UserID: "user-1"})
+
+	ios := entity.TargetingRuleRequest{OS: "iOS", URL: "https://apps.apple.com/app/id1"}
+	if _, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-2", ios); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("AddTargetingRule() by another user error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", entity.TargetingRuleRequest{URL: "https://example.org"}); !errors.Is(err, ErrEmptyTargetingRule) {
+		t.Errorf("AddTargetingRule() without conditions error = %v, want ErrEmptyTargetingRule", err)
+	}
+	if _, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", entity.TargetingRuleRequest{OS: "iOS", URL: "ftp://example.org"}); !errors.Is(err, ErrInvalidURL) {
+		t.Errorf("AddTargetingRule() to ftp error = %v, want ErrInvalidURL", err)
+	}
+
+	first, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", ios)
+	if err != nil || first.ID == "" {
+		t.Fatalf("AddTargetingRule() = %+v, %v", first, err)
+	}
+	second, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", entity.TargetingRuleRequest{OS: "Android", URL: "https://play.google.com/store/apps"})
+	if err != nil {
+		t.Fatalf("AddTargetingRule() error = %v", err)
+	}
+
+	// Rules are part of the link, so cached copies must not keep the old ones
+	_, _ = f.uc.GetOriginalURL(ctx, resp.ShortCode)
+	updated, err := f.uc.UpdateTargetingRule(ctx, resp.ID, first.ID, "user-1", entity.TargetingRuleRequest{Device: "Tablet", OS: "iOS", URL: "https://apps.apple.com/app/id2"})
+	if err != nil || updated.ID != first.ID {
+		t.Fatalf("UpdateTargetingRule() = %+v, %v", updated, err)
+	}
+	url, _ := f.uc.GetOriginalURL(ctx, resp.ShortCode)
+	if len(url.TargetingRules) != 2 || url.TargetingRules[0] != *updated || url.TargetingRules[1] != *second {
+		t.Errorf("TargetingRules after update = %+v", url.TargetingRules)
+	}
+
+	if _, err := f.uc.UpdateTargetingRule(ctx, resp.ID, "missing", "user-1", ios); !errors.Is(err, ErrTargetingRuleNotFound) {
+		t.Errorf("UpdateTargetingRule(missing) error = %v, want ErrTargetingRuleNotFound", err)
+	}
+	if err := f.uc.DeleteTargetingRule(ctx, resp.ID, first.ID, "user-1"); err != nil {
+		t.Fatalf("DeleteTargetingRule() error = %v", err)
+	}
+	rules, err := f.uc.GetTargetingRules(ctx, resp.ID, "user-1")
+	if err != nil || len(rules) != 1 || rules[0].ID != second.ID {
+		t.Errorf("GetTargetingRules() = %+v, %v, want the Android rule", rules, err)
+	}
+
+	// Every edit is a revision, so earlier rules can be restored
+	restored, err := f.uc.RestoreURLRevision(ctx, resp.ID, "user-1", 3)
+	if err != nil {
+		t.Fatalf("RestoreURLRevision() error = %v", err)
+	}
+	rules, _ = f.uc.GetTargetingRules(ctx, restored.ID, "user-1")
+	if len(rules) != 2 || rules[0].ID != first.ID || rules[0].Device != "" {
+		t.Errorf("rules after restoring revision 3 = %+v", rules)
+	}
+
+	for range maxTargetingRules - len(rules) {
+		if _, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", ios); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", ios); !errors.Is(err, ErrTooManyTargetingRules) {
+		t.Errorf("AddTargetingRule() past the limit error = %v, want ErrTooManyTargetingRules", err)
+	}
+}
+
+func TestRouteByCountry(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com/sale", UserID: "user-1"})
+	germany := entity.TargetingRuleRequest{Country: "de", URL: "https://example.com/de"}
+	if _, err := f.uc.AddTargetingRule(ctx, resp.ID, "user-1", germany); !errors.Is(err, ErrCountryTargetingDisabled) {
+		t.Errorf("AddTargetingRule() without a country database error = %v, want ErrCountryTargetingDisabled", err)
+	}
+
+	countries, err := geoip.ReadCountryDB(strings.NewReader("5.1.0.0,5.1.255.255,DE\n36.64.0.0,36.95.255.255,ID\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uc := NewURLUseCase(URLUseCaseConfig{URLRepo: f.urls, ClickRepo: f.clicks, CountryDB: countries})
+
+	de, err := uc.AddTargetingRule(ctx, resp.ID, "user-1", germany)
+	if err != nil || de.Country != "DE" {
+		t.Fatalf("AddTargetingRule() = %+v, %v, want country DE", de, err)
+	}
+	id, _ := uc.AddTargetingRule(ctx, resp.ID, "user-1", entity.TargetingRuleRequest{Country: "ID", URL: "https://example.com/id"})
+
+	url := mustGetURL(t, f, resp.ShortCode)
+	tests := []struct {
+		ip       string
+		want     string
+		wantRule string
+	}{
+		{"5.1.42.7", "https://example.com/de", de.ID},
+		{"36.80.1.1", "https://example.com/id", id.ID},
+		{"198.51.100.1", "https://example.com/sale", ""},
+		{"", "https://example.com/sale", ""},
+	}
+	for _, tt := range tests {
+		click := &entity.Click{IPAddress: tt.ip}
+		if got := uc.Route(url, click); got != tt.want || click.TargetingRuleID != tt.wantRule {
+			t.Errorf("Route(%q) = %q with rule %q, want %q with rule %q", tt.ip, got, click.TargetingRuleID, tt.want, tt.wantRule)
+		}
+
+		if err := uc.RecordClick(ctx, url, click); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clicks, _ := f.clicks.GetByURLID(ctx, url.ID, 10, 0)
+	var routed int
+	for _, click := range clicks {
+		if click.TargetingRuleID != "" {
+			routed++
+		}
+	}
+	if len(clicks) != len(tests) || routed != 2 {
+		t.Errorf("recorded %d clicks, %d routed by a rule, want %d and 2", len(clicks), routed, len(tests))
+	}
+
+	// The original URL is the fallback destination, so changing it moves
+	// only the visitors no rule matches
+	fallback := "https://example.com/global"
+	if _, err := uc.UpdateURL(ctx, resp.ID, "user-1", entity.UpdateURLRequest{OriginalURL: &fallback}); err != nil {
+		t.Fatalf("UpdateURL() error = %v", err)
+	}
+	url = mustGetURL(t, f, resp.ShortCode)
+	if got := uc.Route(url, &entity.Click{IPAddress: "198.51.100.1"}); got != fallback {
+		t.Errorf("Route() matching no rule = %q, want the new original URL %q", got, fallback)
+	}
+	if got := uc.Route(url, &entity.Click{IPAddress: "5.1.42.7"}); got != "https://example.com/de" {
+		t.Errorf("Route() matching a rule = %q, want https://example.com/de", got)
+	}
+}
+
+func TestWorkspaceURLs(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	workspace := &entity.Workspace{Name: "Marketing", CreatedBy: "owner"}
+	_ = f.workspaces.Create(ctx, workspace)
+	_ = f.workspaces.AddMember(ctx, &entity.WorkspaceMember{WorkspaceID: workspace.ID, UserID: "viewer", Role: entity.RoleViewer})
+
+	resp, err := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", WorkspaceID: workspace.ID, UserID: "owner"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() by owner error = %v", err)
+	}
+
+	if _, err := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", WorkspaceID: workspace.ID, UserID: "viewer"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("CreateShortURL() by viewer error = %v, want ErrUnauthorized", err)
+	}
+
+	urls, err := f.uc.GetWorkspaceURLs(ctx, workspace.ID, "viewer", 0, 0)
+	if err != nil || len(urls) != 1 {
+		t.Errorf("GetWorkspaceURLs() by viewer = %v, %v", urls, err)
+	}
+
+	if err := f.uc.DeleteURL(ctx, resp.ID, "viewer"); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("DeleteURL() by viewer error = %v, want ErrUnauthorized", err)
+	}
+	if err := f.uc.DeleteURL(ctx, resp.ID, "owner"); err != nil {
+		t.Errorf("DeleteURL() by owner error = %v", err)
+	}
+}
+
+func TestWorkspaceKeyURLs(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	marketing := &entity.Workspace{Name: "Marketing", CreatedBy: "owner"}
+	sales := &entity.Workspace{Name: "Sales", CreatedBy: "owner"}
+	_ = f.workspaces.Create(ctx, marketing)
+	_ = f.workspaces.Create(ctx, sales)
+
+	personal, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", UserID: "owner"})
+	salesLink, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", WorkspaceID: sales.ID, UserID: "owner"})
+
+	// Requests made with an API key of the marketing workspace
+	keyCtx := WithActor(ctx, Actor{UserID: "owner", APIKeyID: "key-1", WorkspaceID: marketing.ID})
+
+	target := "https://evil.example"
+	for _, id := range []string{personal.ID, salesLink.ID} {
+		if _, err := f.uc.UpdateURL(keyCtx, id, "owner", entity.UpdateURLRequest{OriginalURL: &target}); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("UpdateURL(%s) with a workspace key error = %v, want ErrUnauthorized", id, err)
+		}
+		if err := f.uc.DeleteURL(keyCtx, id, "owner"); !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("DeleteURL(%s) with a workspace key error = %v, want ErrUnauthorized", id, err)
+		}
+	}
+	if _, err := f.uc.GetWorkspaceURLs(keyCtx, sales.ID, "owner", 0, 0); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetWorkspaceURLs() of another workspace error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := f.uc.GetUserURLs(keyCtx, "owner", 0, 0); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetUserURLs() with a workspace key error = %v, want ErrUnauthorized", err)
+	}
+	if _, err := f.uc.CreateShortURL(keyCtx, entity.CreateURLRequest{OriginalURL: "https://example.com", UserID: "owner"}); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("CreateShortURL() personal with a workspace key error = %v, want ErrUnauthorized", err)
+	}
+
+	created, err := f.uc.CreateShortURL(keyCtx, entity.CreateURLRequest{OriginalURL: "https://example.com", WorkspaceID: marketing.ID, UserID: "owner"})
+	if err != nil {
+		t.Fatalf("CreateShortURL() in its own workspace error = %v", err)
+	}
+	if _, err := f.uc.UpdateURL(keyCtx, created.ID, "owner", entity.UpdateURLRequest{OriginalURL: &target}); err != nil {
+		t.Errorf("UpdateURL() in its own workspace error = %v", err)
+	}
+}
+
+func TestVerifyPassword(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "secret"})
+	if !resp.PasswordProtected {
+		t.Fatal("link is not password protected")
+	}
+
+	tests := []struct {
+		password string
+		want     error
+	}{
+		{"", ErrPasswordRequired},
+		{"wrong", ErrInvalidPassword},
+		{"secret", nil},
+	}
+
+	for _, tt := range tests {
+		if _, err := f.uc.VerifyPassword(ctx, resp.ShortCode, tt.password); !errors.Is(err, tt.want) {
+			t.Errorf("VerifyPassword(%q) error = %v, want %v", tt.password, err, tt.want)
+		}
+	}
+}
+
+func TestVerifyPasswordLockout(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:             f.urls,
+		AuditRepo:           f.audit,
+		PasswordAttemptRepo: memory.NewPasswordAttemptRepository(),
+		PasswordLockout: PasswordLockoutConfig{
+			FreeAttemptsPerLink: 3,
+			FreeAttemptsPerIP:   2,
+			BaseLockout:         time.Minute,
+		},
+	})
+
+	resp, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter22"})
+	client := WithActor(ctx, Actor{IPAddress: "192.0.2.1"})
+	other := WithActor(ctx, Actor{IPAddress: "192.0.2.2"})
+
+	verify := func(ctx context.Context, password string) error {
+		_, err := uc.VerifyPassword(ctx, resp.ShortCode, password)
+		return err
+	}
+	wantLockout := func(err error, within time.Duration) {
+		t.Helper()
+		var lockout *LockoutError
+		if !errors.As(err, &lockout) || !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("error = %v, want a lockout", err)
+		}
+		if lockout.RetryAfter <= 0 || lockout.RetryAfter > within {
+			t.Errorf("RetryAfter = %v, want up to %v", lockout.RetryAfter, within)
+		}
+	}
+
+	// The right password does not count against the client, but does not
+	// forget its failures either
+	for _, password := range []string{"wrong", "hunter22", "hunter22", "wrong"} {
+		want := ErrInvalidPassword
+		if password == "hunter22" {
+			want = nil
+		}
+		if err := verify(client, password); err != want {
+			t.Fatalf("VerifyPassword(%s) error = %v, want %v", password, err, want)
+		}
+	}
+
+	// Past its free attempts the client is locked out, even with the right
+	// password, but others are not
+	wantLockout(verify(client, "wrong"), time.Minute)
+	wantLockout(verify(client, "hunter22"), time.Minute)
+	if err := verify(other, "hunter22"); err != nil {
+		t.Fatalf("VerifyPassword(other client) error = %v", err)
+	}
+
+	// Past the link's free attempts, first tries included, everyone who got
+	// it wrong before is locked out
+	var guessers []context.Context
+	for _, ip := range []string{"198.51.100.1", "198.51.100.2", "198.51.100.3", "198.51.100.4"} {
+		guessers = append(guessers, WithActor(ctx, Actor{IPAddress: ip}))
+	}
+	for _, guesser := range guessers[:3] {
+		if err := verify(guesser, "wrong"); err != ErrInvalidPassword {
+			t.Fatalf("VerifyPassword(wrong) error = %v, want %v", err, ErrInvalidPassword)
+		}
+	}
+	wantLockout(verify(guessers[3], "wrong"), time.Minute)
+	wantLockout(verify(guessers[0], "hunter22"), time.Minute)
+
+	// but someone trying for the first time still gets in
+	if err := verify(WithActor(ctx, Actor{IPAddress: "203.0.113.1"}), "hunter22"); err != nil {
+		t.Fatalf("VerifyPassword(first try during a link lockout) error = %v", err)
+	}
+
+	stats, err := uc.GetStats(ctx, resp.ShortCode, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	// The client and the link; the guesser who tried again once the link was
+	// locked out had nothing counted, so did not lock itself out
+	if stats.PasswordLockouts != 2 || stats.LastPasswordLockout == nil {
+		t.Errorf("GetStats() lockouts = %d, last %v, want 2", stats.PasswordLockouts, stats.LastPasswordLockout)
+	}
+	events, _ := f.audit.List(ctx, entity.AuditFilter{Action: entity.AuditActionURLPasswordLockout, Limit: 10})
+	if len(events) != 2 || events[0].TargetID != resp.ID || events[0].IPAddress != "198.51.100.4" || events[1].IPAddress != "192.0.2.1" {
+		t.Errorf("lockout events = %v, want 2 for the link", events)
+	}
+}
+
+// Attempts turned away by the link's lockout use up none of the client's own
+// free attempts
+func TestVerifyPasswordLinkLockoutKeepsClientAttempts(t *testing.T) {
+	ctx := context.Background()
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:             memory.NewURLRepository(),
+		PasswordAttemptRepo: memory.NewPasswordAttemptRepository(),
+		PasswordLockout: PasswordLockoutConfig{
+			FreeAttemptsPerLink: 1,
+			FreeAttemptsPerIP:   2,
+			BaseLockout:         time.Minute,
+		},
+	})
+	target, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter22"})
+	other, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter22"})
+
+	guesser := WithActor(ctx, Actor{IPAddress: "198.51.100.1"})
+	client := WithActor(ctx, Actor{IPAddress: "192.0.2.1"})
+
+	if _, err := uc.VerifyPassword(client, target.ShortCode, "wrong"); err != ErrInvalidPassword {
+		t.Fatalf("first try error = %v, want %v", err, ErrInvalidPassword)
+	}
+
+	// The client's first try used up the link's free attempt
+	if _, err := uc.VerifyPassword(guesser, target.ShortCode, "wrong"); !errors.Is(err, ErrTooManyAttempts) {
+		t.Fatalf("guess past the link's free attempts error = %v, want a lockout", err)
+	}
+
+	// The client is not locked out itself, only turned away by the link
+	for range 3 {
+		if _, err := uc.VerifyPassword(client, target.ShortCode, "wrong"); !errors.Is(err, ErrTooManyAttempts) {
+			t.Fatalf("guess during the link lockout error = %v, want a lockout", err)
+		}
+	}
+
+	// so it still has a free attempt left for another link
+	if _, err := uc.VerifyPassword(client, other.ShortCode, "wrong"); err != ErrInvalidPassword {
+		t.Errorf("guess on another link error = %v, want %v", err, ErrInvalidPassword)
+	}
+}
+
+// Guessing from a new address each time counts towards the link's lockout,
+// and first tries let through while it lasts lock it out for longer
+func TestVerifyPasswordRotatingAddresses(t *testing.T) {
+	ctx := context.Background()
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:             memory.NewURLRepository(),
+		AuditRepo:           memory.NewAuditRepository(),
+		PasswordAttemptRepo: memory.NewPasswordAttemptRepository(),
+		PasswordLockout:     PasswordLockoutConfig{FreeAttemptsPerLink: 3, BaseLockout: time.Minute},
+	})
+	resp, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter22"})
+
+	for i := range 5 {
+		client := WithActor(ctx, Actor{IPAddress: fmt.Sprintf("198.51.100.%d", i+1)})
+		_, err := uc.VerifyPassword(client, resp.ShortCode, "wrong")
+		var lockout *LockoutError
+		switch {
+		case i < 3 && err != ErrInvalidPassword:
+			t.Fatalf("guess %d error = %v, want %v", i+1, err, ErrInvalidPassword)
+		case i == 3 && (!errors.As(err, &lockout) || lockout.RetryAfter != time.Minute):
+			t.Fatalf("guess %d error = %v, want a minute lockout", i+1, err)
+		case i == 4 && (!errors.As(err, &lockout) || lockout.RetryAfter != 2*time.Minute):
+			t.Fatalf("guess %d during the lockout error = %v, want a two minute lockout", i+1, err)
+		}
+	}
+
+	stats, err := uc.GetStats(ctx, resp.ShortCode, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("GetStats() error = %v", err)
+	}
+	if stats.PasswordLockouts != 2 {
+		t.Errorf("GetStats() lockouts = %d, want 2", stats.PasswordLockouts)
+	}
+}
+
+// Unlocking a link of one's own between guesses on another does not lift the
+// lockouts the guesses lead to
+func TestVerifyPasswordLockoutAcrossLinks(t *testing.T) {
+	ctx := context.Background()
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:             memory.NewURLRepository(),
+		PasswordAttemptRepo: memory.NewPasswordAttemptRepository(),
+		PasswordLockout:     PasswordLockoutConfig{FreeAttemptsPerLink: 3, FreeAttemptsPerIP: 100},
+	})
+	own, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "correct horse"})
+
+	tests := []struct {
+		name    string
+		clients []string
+	}{
+		{"one address", []string{"192.0.2.1"}},
+		{"rotating IPv6 addresses", []string{"2001:db8::1", "2001:db8::2", "2001:db8::3", "2001:db8::4", "2001:db8::5"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter22"})
+
+			// The 3 free ones, the first try included, and the one that locks
+			// the link out
+			for i := range 4 {
+				client := WithActor(ctx, Actor{IPAddress: tt.clients[i%len(tt.clients)]})
+				_, err := uc.VerifyPassword(client, target.ShortCode, "wrong")
+				if i < 3 && err != ErrInvalidPassword {
+					t.Fatalf("guess %d error = %v, want %v", i+1, err, ErrInvalidPassword)
+				}
+				if i == 3 && !errors.Is(err, ErrTooManyAttempts) {
+					t.Fatalf("guess %d error = %v, want a lockout", i+1, err)
+				}
+				if _, err := uc.VerifyPassword(client, own.ShortCode, "correct horse"); err != nil {
+					t.Fatalf("unlocking the client's own link error = %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordLockoutBackoff(t *testing.T) {
+	g := newPasswordGuard(nil, auditLog{}, PasswordLockoutConfig{BaseLockout: time.Minute, MaxLockout: 5 * time.Minute})
+	policy := g.policy(attemptKey{free: 3})
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 5 * time.Minute, 5 * time.Minute}
+	for i, want := range want {
+		if got := policy.Lockout(int64(i + 1)); got != want {
+			t.Errorf("Lockout(%d) = %v, want %v", i+1, got, want)
+		}
+	}
+	if got := policy.Lockout(1000); got != 5*time.Minute {
+		t.Errorf("Lockout(1000) = %v, want the maximum", got)
+	}
+}
+
+// Guesses made at the same time are counted before any password is checked,
+// so only the first try, the free ones and the one that locks the link out
+// get checked
+func TestVerifyPasswordConcurrentGuesses(t *testing.T) {
+	ctx := context.Background()
+	audit := memory.NewAuditRepository()
+	uc := NewURLUseCase(URLUseCaseConfig{
+		URLRepo:             memory.NewURLRepository(),
+		AuditRepo:           audit,
+		PasswordAttemptRepo: memory.NewPasswordAttemptRepository(),
+		PasswordLockout:     PasswordLockoutConfig{FreeAttemptsPerLink: 3, FreeAttemptsPerIP: 100},
+	})
+	resp, _ := uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter22"})
+
+	client := WithActor(ctx, Actor{IPAddress: "192.0.2.1"})
+	var wrong atomic.Int64
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := uc.VerifyPassword(client, resp.ShortCode, "wrong"); err == ErrInvalidPassword {
+				wrong.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := wrong.Load(); got != 3 {
+		t.Errorf("%d guesses were told the password is wrong, want the 3 free ones", got)
+	}
+	events, _ := audit.List(ctx, entity.AuditFilter{Action: entity.AuditActionURLPasswordLockout, Limit: 10})
+	if len(events) != 1 {
+		t.Errorf("%d lockouts recorded, want 1", len(events))
+	}
+}
+
+func TestUnlockToken(t *testing.T) {
+	ctx := context.Background()
+	f := newURLFixture()
+
+	resp, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter2", UserID: "user-1"})
+	other, _ := f.uc.CreateShortURL(ctx, entity.CreateURLRequest{OriginalURL: "https://example.com", Password: "hunter2", UserID: "user-1"})
+	url := mustGetURL(t, f, resp.ShortCode)
+
+	token, expiresAt, err := f.uc.UnlockToken(url)
+	if err != nil {
+		t.Fatalf("UnlockToken() error = %v", err)
+	}
+	if until := time.Until(expiresAt); until <= 0 || until > defaultUnlockTTL {
+		t.Errorf("expires in %v, want within %v", until, defaultUnlockTTL)
+	}
+
+	if !f.uc.IsUnlocked(url, token) {
+		t.Error("IsUnlocked() = false for its own link")
+	}
+	if f.uc.IsUnlocked(mustGetURL(t, f, other.ShortCode), token) {
+		t.Error("IsUnlocked() = true for another link")
+	}
+
+	// Another instance with its own secret does not accept it
+	if NewURLUseCase(URLUseCaseConfig{URLRepo: f.urls}).IsUnlocked(url, token) {
+		t.Error("IsUnlocked() = true under another secret")
+	}
+
+	// Changing the password locks everyone out again
+	password := "correct horse"
+	if _, err := f.uc.UpdateURL(ctx, resp.ID, "user-1", entity.UpdateURLRequest{Password: &password}); err != nil {
+		t.Fatal(err)
+	}
+	if f.uc.IsUnlocked(mustGetURL(t, f, resp.ShortCode), token) {
+		t.Error("IsUnlocked() = true after the password changed")
+	}
+}
+
+func mustGetURL(t *testing.T, f *urlFixture, shortCode string) *entity.URL {
+	t.Helper()
+	url, err := f.urls.GetByShortCode(context.Background(), shortCode)
+	if err != nil || url == nil {
+		t.Fatalf("GetByShortCode(%q) = %v, %v", shortCode, url, err)
+	}
+	return url
+}
@@ -0,0 +1,289 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+var (
+	ErrWorkspaceNotFound = errors.New("workspace not found")
+	ErrMemberNotFound    = errors.New("workspace member not found")
+	ErrMemberExists      = errors.New("user is already a workspace member")
+	ErrLastOwner         = errors.New("workspace must keep at least one owner")
+)
+
+type WorkspaceUseCase struct {
+	workspaceRepo repository.WorkspaceRepository
+	userRepo      repository.UserRepository
+	audit         auditLog
+}
+
+type WorkspaceUseCaseConfig struct {
+	WorkspaceRepo repository.WorkspaceRepository
+	UserRepo      repository.UserRepository
+	AuditRepo     repository.AuditRepository
+}
+
+func NewWorkspaceUseCase(cfg WorkspaceUseCaseConfig) *WorkspaceUseCase {
+	return &WorkspaceUseCase{
+		workspaceRepo: cfg.WorkspaceRepo,
+		userRepo:      cfg.UserRepo,
+		audit:         auditLog{repo: cfg.AuditRepo},
+	}
+}
+
+func (uc *WorkspaceUseCase) CreateWorkspace(ctx context.Context, userID string, req entity.CreateWorkspaceRequest) (*entity.WorkspaceResponse, error) {
+	if userID == "" {
+		return nil, ErrUnauthorized
+	}
+
+	workspace := &entity.Workspace{
+		Name:      req.Name,
+		CreatedBy: userID,
+	}
+
+	if err := uc.workspaceRepo.Create(ctx, workspace); err != nil {
+		return nil, err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionWorkspaceCreate, entity.AuditTargetWorkspace, workspace.ID, workspace.ID, nil, workspace)
+
+	return toWorkspaceResponse(workspace, entity.RoleOwner), nil
+}
+
+// GetUserWorkspaces lists the workspaces the user belongs to. A workspace API
+// key only sees its own workspace.
+func (uc *WorkspaceUseCase) GetUserWorkspaces(ctx context.Context, userID string) ([]*entity.WorkspaceResponse, error) {
+	workspaces, err := uc.workspaceRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*entity.WorkspaceResponse, 0, len(workspaces))
+	for _, workspace := range workspaces {
+		if !inKeyWorkspace(ctx, workspace.ID) {
+			continue
+		}
+		member, err := uc.workspaceRepo.GetMember(ctx, workspace.ID, userID)
+		if err != nil {
+			return nil, err
+		}
+		if member == nil {
+			continue
+		}
+		responses = append(responses, toWorkspaceResponse(workspace, member.Role))
+	}
+
+	return responses, nil
+}
+
+func (uc *WorkspaceUseCase) GetWorkspace(ctx context.Context, id, userID string) (*entity.WorkspaceResponse, error) {
+	workspace, err := uc.workspaceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	member, err := requireRole(ctx, uc.workspaceRepo, id, userID, entity.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+
+	return toWorkspaceResponse(workspace, member.Role), nil
+}
+
+func (uc *WorkspaceUseCase) GetMembers(ctx context.Context, id, userID string) ([]*entity.WorkspaceMember, error) {
+	if _, err := uc.getWorkspaceAs(ctx, id, userID, entity.RoleViewer); err != nil {
+		return nil, err
+	}
+
+	return uc.workspaceRepo.GetMembers(ctx, id)
+}
+
+// AddMember invites an existing user by email. Admins may add anyone up to
+// their own role; only owners can add other owners.
+func (uc *WorkspaceUseCase) AddMember(ctx context.Context, id, userID string, req entity.AddWorkspaceMemberRequest) (*entity.WorkspaceMember, error) {
+	actor, err := uc.getWorkspaceAs(ctx, id, userID, entity.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.AtLeast(req.Role) {
+		return nil, ErrUnauthorized
+	}
+
+	user, err := uc.userRepo.GetByEmail(ctx, normalizeEmail(req.Email))
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	existing, err := uc.workspaceRepo.GetMember(ctx, id, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrMemberExists
+	}
+
+	member := &entity.WorkspaceMember{
+		WorkspaceID: id,
+		UserID:      user.ID,
+		Email:       user.Email,
+		Role:        req.Role,
+	}
+
+	if err := uc.workspaceRepo.AddMember(ctx, member); err != nil {
+		return nil, err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionWorkspaceMemberAdd, entity.AuditTargetWorkspace, id, id, nil, member)
+
+	return member, nil
+}
+
+func (uc *WorkspaceUseCase) UpdateMemberRole(ctx context.Context, id, userID, memberID string, req entity.UpdateWorkspaceMemberRequest) (*entity.WorkspaceMember, error) {
+	actor, err := uc.getWorkspaceAs(ctx, id, userID, entity.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := uc.getManagedMember(ctx, actor, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.AtLeast(req.Role) {
+		return nil, ErrUnauthorized
+	}
+
+	if member.Role == entity.RoleOwner && req.Role != entity.RoleOwner {
+		if err := uc.ensureAnotherOwner(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := uc.workspaceRepo.UpdateMemberRole(ctx, id, memberID, req.Role); err != nil {
+		return nil, err
+	}
+
+	before := *member
+	member.Role = req.Role
+
+	uc.audit.record(ctx, userID, entity.AuditActionWorkspaceMemberUpdate, entity.AuditTargetWorkspace, id, id, &before, member)
+
+	return member, nil
+}
+
+// RemoveMember removes a member from the workspace. Members may always leave
+// on their own, as long as they are not the last owner.
+func (uc *WorkspaceUseCase) RemoveMember(ctx context.Context, id, userID, memberID string) error {
+	minRole := entity.RoleAdmin
+	if memberID == userID {
+		minRole = entity.RoleViewer
+	}
+
+	actor, err := uc.getWorkspaceAs(ctx, id, userID, minRole)
+	if err != nil {
+		return err
+	}
+
+	member, err := uc.getManagedMember(ctx, actor, memberID)
+	if err != nil {
+		return err
+	}
+
+	if member.Role == entity.RoleOwner {
+		if err := uc.ensureAnotherOwner(ctx, id); err != nil {
+			return err
+		}
+	}
+
+	if err := uc.workspaceRepo.RemoveMember(ctx, id, memberID); err != nil {
+		return err
+	}
+
+	uc.audit.record(ctx, userID, entity.AuditActionWorkspaceMemberRemove, entity.AuditTargetWorkspace, id, id, member, nil)
+	return nil
+}
+
+func (uc *WorkspaceUseCase) getWorkspaceAs(ctx context.Context, id, userID string, min entity.WorkspaceRole) (*entity.WorkspaceMember, error) {
+	workspace, err := uc.workspaceRepo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if workspace == nil {
+		return nil, ErrWorkspaceNotFound
+	}
+
+	return requireRole(ctx, uc.workspaceRepo, id, userID, min)
+}
+
+// getManagedMember loads a member the actor is allowed to change, which is
+// themselves or anyone whose role does not outrank their own.
+func (uc *WorkspaceUseCase) getManagedMember(ctx context.Context, actor *entity.WorkspaceMember, memberID string) (*entity.WorkspaceMember, error) {
+	member, err := uc.workspaceRepo.GetMember(ctx, actor.WorkspaceID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil {
+		return nil, ErrMemberNotFound
+	}
+
+	if member.UserID != actor.UserID && !actor.Role.AtLeast(member.Role) {
+		return nil, ErrUnauthorized
+	}
+
+	return member, nil
+}
+
+func (uc *WorkspaceUseCase) ensureAnotherOwner(ctx context.Context, id string) error {
+	owners, err := uc.workspaceRepo.CountOwners(ctx, id)
+	if err != nil {
+		return err
+	}
+	if owners <= 1 {
+		return ErrLastOwner
+	}
+	return nil
+}
+
+// requireRole returns the user's membership in the workspace, or
+// ErrUnauthorized if they are not a member with at least the given role.
+func requireRole(ctx context.Context, repo repository.WorkspaceRepository, workspaceID, userID string, min entity.WorkspaceRole) (*entity.WorkspaceMember, error) {
+	if repo == nil || userID == "" || !inKeyWorkspace(ctx, workspaceID) {
+		return nil, ErrUnauthorized
+	}
+
+	member, err := repo.GetMember(ctx, workspaceID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil || !member.Role.AtLeast(min) {
+		return nil, ErrUnauthorized
+	}
+
+	return member, nil
+}
+
+// inKeyWorkspace reports whether the request may act on something in the
+// given workspace, "" for personal resources. A workspace API key acts for
+// its workspace only, not with the full access of the user who created it.
+func inKeyWorkspace(ctx context.Context, workspaceID string) bool {
+	keyWorkspace := ActorFromContext(ctx).WorkspaceID
+	return keyWorkspace == "" || keyWorkspace == workspaceID
+}
+
+func toWorkspaceResponse(workspace *entity.Workspace, role entity.WorkspaceRole) *entity.WorkspaceResponse {
+	return &entity.WorkspaceResponse{
+		ID:        workspace.ID,
+		Name:      workspace.Name,
+		Role:      role,
+		CreatedAt: workspace.CreatedAt,
+	}
+}
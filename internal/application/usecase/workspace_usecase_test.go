@@ -0,0 +1,145 @@
+package usecase
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/memory"
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// newWorkspaceFixture returns a use case with a workspace owned by "owner"
+// and the users owner, admin, editor and outsider, each with an
+// @example.com address.
+func newWorkspaceFixture(t *testing.T) (*WorkspaceUseCase, string, map[string]string) {
+	t.Helper()
+	ctx := context.Background()
+
+	users := memory.NewUserRepository()
+	uc := NewWorkspaceUseCase(WorkspaceUseCaseConfig{
+		WorkspaceRepo: memory.NewWorkspaceRepository(users),
+		UserRepo:      users,
+		AuditRepo:     memory.NewAuditRepository(),
+	})
+
+	ids := make(map[string]string)
+	for _, name := range []string{"owner", "admin", "editor", "outsider"} {
+		user := &entity.User{Email: name + "@example.com", PasswordHash: "hash"}
+		if err := users.Create(ctx, user); err != nil {
+			t.Fatal(err)
+		}
+		ids[name] = user.ID
+	}
+
+	workspace, err := uc.CreateWorkspace(ctx, ids["owner"], entity.CreateWorkspaceRequest{Name: "Marketing"})
+	if err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+
+	for name, role := range map[string]entity.WorkspaceRole{"admin": entity.RoleAdmin, "editor": entity.RoleEditor} {
+		req := entity.AddWorkspaceMemberRequest{Email: name + "@example.com", Role: role}
+		if _, err := uc.AddMember(ctx, workspace.ID, ids["owner"], req); err != nil {
+			t.Fatalf("AddMember(%s) error = %v", name, err)
+		}
+	}
+
+	return uc, workspace.ID, ids
+}
+
+func TestAddMember(t *testing.T) {
+	ctx := context.Background()
+	uc, id, ids := newWorkspaceFixture(t)
+
+	tests := []struct {
+		name  string
+		actor string
+		req   entity.AddWorkspaceMemberRequest
+		want  error
+	}{
+		{"editor cannot invite", "editor", entity.AddWorkspaceMemberRequest{Email: "outsider@example.com", Role: entity.RoleViewer}, ErrUnauthorized},
+		{"admin cannot add owners", "admin", entity.AddWorkspaceMemberRequest{Email: "outsider@example.com", Role: entity.RoleOwner}, ErrUnauthorized},
+		{"unknown email", "admin", entity.AddWorkspaceMemberRequest{Email: "nobody@example.com", Role: entity.RoleViewer}, ErrUserNotFound},
+		{"existing member", "admin", entity.AddWorkspaceMemberRequest{Email: "editor@example.com", Role: entity.RoleViewer}, ErrMemberExists},
+		{"email is normalized", "admin", entity.AddWorkspaceMemberRequest{Email: " Outsider@Example.com ", Role: entity.RoleViewer}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := uc.AddMember(ctx, id, ids[tt.actor], tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("AddMember() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	members, err := uc.GetMembers(ctx, id, ids["outsider"])
+	if err != nil || len(members) != 4 {
+		t.Errorf("GetMembers() = %v, %v, want 4 members", members, err)
+	}
+}
+
+func TestLastOwner(t *testing.T) {
+	ctx := context.Background()
+	uc, id, ids := newWorkspaceFixture(t)
+
+	demote := entity.UpdateWorkspaceMemberRequest{Role: entity.RoleAdmin}
+	if _, err := uc.UpdateMemberRole(ctx, id, ids["owner"], ids["owner"], demote); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("demoting the last owner error = %v, want ErrLastOwner", err)
+	}
+	if err := uc.RemoveMember(ctx, id, ids["owner"], ids["owner"]); !errors.Is(err, ErrLastOwner) {
+		t.Errorf("last owner leaving error = %v, want ErrLastOwner", err)
+	}
+
+	if _, err := uc.UpdateMemberRole(ctx, id, ids["owner"], ids["admin"], entity.UpdateWorkspaceMemberRequest{Role: entity.RoleOwner}); err != nil {
+		t.Fatalf("promoting a second owner error = %v", err)
+	}
+	if err := uc.RemoveMember(ctx, id, ids["owner"], ids["owner"]); err != nil {
+		t.Errorf("owner leaving with another owner error = %v", err)
+	}
+}
+
+func TestRemoveMember(t *testing.T) {
+	ctx := context.Background()
+	uc, id, ids := newWorkspaceFixture(t)
+
+	if err := uc.RemoveMember(ctx, id, ids["editor"], ids["admin"]); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("editor removing admin error = %v, want ErrUnauthorized", err)
+	}
+	if err := uc.RemoveMember(ctx, id, ids["admin"], ids["owner"]); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("admin removing owner error = %v, want ErrUnauthorized", err)
+	}
+	if err := uc.RemoveMember(ctx, id, ids["admin"], ids["outsider"]); !errors.Is(err, ErrMemberNotFound) {
+		t.Errorf("removing a non-member error = %v, want ErrMemberNotFound", err)
+	}
+
+	// Anyone may leave
+	if err := uc.RemoveMember(ctx, id, ids["editor"], ids["editor"]); err != nil {
+		t.Errorf("editor leaving error = %v", err)
+	}
+	if _, err := uc.GetWorkspace(ctx, id, ids["editor"]); !errors.Is(err, ErrUnauthorized) {
+		t.Errorf("GetWorkspace() after leaving error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestGetUserWorkspacesWithWorkspaceKey(t *testing.T) {
+	ctx := context.Background()
+	uc, id, ids := newWorkspaceFixture(t)
+
+	other, err := uc.CreateWorkspace(ctx, ids["owner"], entity.CreateWorkspaceRequest{Name: "Sales"})
+	if err != nil {
+		t.Fatalf("CreateWorkspace() error = %v", err)
+	}
+
+	if workspaces, err := uc.GetUserWorkspaces(ctx, ids["owner"]); err != nil || len(workspaces) != 2 {
+		t.Fatalf("GetUserWorkspaces() = %v, %v, want 2 workspaces", workspaces, err)
+	}
+
+	keyCtx := WithActor(ctx, Actor{UserID: ids["owner"], APIKeyID: "key-1", WorkspaceID: other.ID})
+	workspaces, err := uc.GetUserWorkspaces(keyCtx, ids["owner"])
+	if err != nil {
+		t.Fatalf("GetUserWorkspaces() with a workspace key error = %v", err)
+	}
+	if len(workspaces) != 1 || workspaces[0].ID != other.ID {
+		t.Errorf("GetUserWorkspaces() with a workspace key = %v, want only %s and not %s", workspaces, other.ID, id)
+	}
+}
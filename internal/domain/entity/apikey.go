@@ -1,39 +1,76 @@
 package entity
 
 import (
+	"slices"
 	"time"
 )
 
 type APIKey struct {
-	ID        string     `json:"id"`
-	Key       string     `json:"key"`
-	Name      string     `json:"name"`
-	UserID    string     `json:"user_id"`
-	Scopes    []string   `json:"scopes"`
-	RateLimit int        `json:"rate_limit"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	LastUsed  *time.Time `json:"last_used,omitempty"`
-	IsActive  bool       `json:"is_active"`
+	ID          string     `json:"id"`
+	KeyHash     string     `json:"-"`
+	KeyPrefix   string     `json:"key_prefix"`
+	Name        string     `json:"name"`
+	UserID      string     `json:"user_id"`
+	WorkspaceID string     `json:"workspace_id,omitempty"`
+	Scopes      []string   `json:"scopes"`
+	RateLimit   int        `json:"rate_limit"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+	IsActive    bool       `json:"is_active"`
+	ReplacedBy  string     `json:"replaced_by,omitempty"`
+}
+
+func (k *APIKey) IsExpired() bool {
+	if k.ExpiresAt == nil {
+		return false
+	}
+	return time.Now().After(*k.ExpiresAt)
 }
 
 type CreateAPIKeyRequest struct {
-	Name      string   `json:"name" validate:"required,min=1,max=100"`
-	Scopes    []string `json:"scopes,omitempty"`
-	RateLimit int      `json:"rate_limit,omitempty"`
-	ExpiresIn *int     `json:"expires_in,omitempty"` // in days
+	Name        string   `json:"name" validate:"required,min=1,max=100"`
+	Scopes      []string `json:"scopes,omitempty" validate:"omitempty,dive,oneof=urls:read urls:write stats:read keys:manage workspaces:manage audit:read"`
+	RateLimit   int      `json:"rate_limit,omitempty"`
+	ExpiresIn   *int     `json:"expires_in,omitempty"` // in days
+	WorkspaceID string   `json:"workspace_id,omitempty"`
+}
+
+type RotateAPIKeyRequest struct {
+	GracePeriod *int `json:"grace_period,omitempty" validate:"omitempty,min=0,max=720"` // in hours
 }
 
 type APIKeyResponse struct {
-	ID        string     `json:"id"`
-	Key       string     `json:"key,omitempty"` // Only shown on creation
-	Name      string     `json:"name"`
-	Scopes    []string   `json:"scopes"`
-	RateLimit int        `json:"rate_limit"`
-	ExpiresAt *time.Time `json:"expires_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
-	LastUsed  *time.Time `json:"last_used,omitempty"`
-	IsActive  bool       `json:"is_active"`
+	ID          string          `json:"id"`
+	Key         string          `json:"key,omitempty"` // Only shown on creation
+	KeyPrefix   string          `json:"key_prefix"`
+	Name        string          `json:"name"`
+	WorkspaceID string          `json:"workspace_id,omitempty"`
+	Scopes      []string        `json:"scopes"`
+	RateLimit   int             `json:"rate_limit"`
+	ExpiresAt   *time.Time      `json:"expires_at,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	LastUsed    *time.Time      `json:"last_used,omitempty"`
+	IsActive    bool            `json:"is_active"`
+	ReplacedBy  string          `json:"replaced_by,omitempty"`
+	PreviousKey *APIKeyResponse `json:"previous_key,omitempty"` // Only shown on rotation
+}
+
+const (
+	ScopeURLsRead         = "urls:read"
+	ScopeURLsWrite        = "urls:write"
+	ScopeStatsRead        = "stats:read"
+	ScopeKeysManage       = "keys:manage"
+	ScopeWorkspacesManage = "workspaces:manage"
+	ScopeAuditRead        = "audit:read"
+)
+
+var DefaultScopes = []string{ScopeURLsRead, ScopeURLsWrite, ScopeStatsRead}
+
+func (k *APIKey) HasScope(scope string) bool {
+	return HasScope(k.Scopes, scope)
 }
 
-var DefaultScopes = []string{"urls:read", "urls:write", "stats:read"}
+func HasScope(scopes []string, scope string) bool {
+	return slices.Contains(scopes, scope)
+}
@@ -0,0 +1,62 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAPIKey_HasScope(t *testing.T) {
+	key := &APIKey{Scopes: []string{ScopeURLsRead, ScopeStatsRead}}
+
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{ScopeURLsRead, true},
+		{ScopeStatsRead, true},
+		{ScopeURLsWrite, false},
+		{ScopeKeysManage, false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			if got := key.HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDefaultScopes(t *testing.T) {
+	if HasScope(DefaultScopes, ScopeKeysManage) {
+		t.Error("DefaultScopes should not include keys:manage")
+	}
+	if len(DefaultScopes) != 3 {
+		t.Errorf("len(DefaultScopes) = %d, want 3", len(DefaultScopes))
+	}
+}
+
+func TestAPIKey_IsExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		want      bool
+	}{
+		{"nil expiry", nil, false},
+		{"expired", &past, true},
+		{"not expired", &future, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k := &APIKey{ExpiresAt: tt.expiresAt}
+			if got := k.IsExpired(); got != tt.want {
+				t.Errorf("IsExpired() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
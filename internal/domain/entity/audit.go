@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	AuditTargetURL       = "url"
+	AuditTargetAPIKey    = "api_key"
+	AuditTargetUser      = "user"
+	AuditTargetWorkspace = "workspace"
+)
+
+const (
+	AuditActionURLCreate             = "url.create"
+	AuditActionURLUpdate             = "url.update"
+	AuditActionURLRestore            = "url.restore"
+	AuditActionURLDelete             = "url.delete"
+	AuditActionURLPasswordLockout    = "url.password_lockout"
+	AuditActionAPIKeyCreate          = "api_key.create"
+	AuditActionAPIKeyRotate          = "api_key.rotate"
+	AuditActionAPIKeyRevoke          = "api_key.revoke"
+	AuditActionAPIKeyDelete          = "api_key.delete"
+	AuditActionUserSignup            = "user.signup"
+	AuditActionWorkspaceCreate       = "workspace.create"
+	AuditActionWorkspaceMemberAdd    = "workspace.member_add"
+	AuditActionWorkspaceMemberUpdate = "workspace.member_update"
+	AuditActionWorkspaceMemberRemove = "workspace.member_remove"
+)
+
+type AuditEvent struct {
+	ID            int64           `json:"id"`
+	ActorUserID   string          `json:"actor_user_id,omitempty"`
+	ActorAPIKeyID string          `json:"actor_api_key_id,omitempty"`
+	Action        string          `json:"action"`
+	TargetType    string          `json:"target_type"`
+	TargetID      string          `json:"target_id"`
+	WorkspaceID   string          `json:"workspace_id,omitempty"`
+	IPAddress     string          `json:"ip_address,omitempty"`
+	Changes       json.RawMessage `json:"changes,omitempty"` // field -> {old, new}
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows down an audit log query. Empty fields match anything.
+// Events are returned newest first, starting below Cursor when it is set.
+type AuditFilter struct {
+	ActorUserID   string
+	ActorAPIKeyID string
+	Action        string
+	TargetType    string
+	TargetID      string
+	WorkspaceID   string
+	From          *time.Time
+	To            *time.Time
+	Cursor        int64
+	Limit         int
+}
+
+type AuditEventPage struct {
+	Events     []*AuditEvent `json:"events"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
@@ -17,16 +17,27 @@ type Click struct {
 	Browser   string    `json:"browser,omitempty"`
 	OS        string    `json:"os,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// Resolved while redirecting: the visitor's ISO country code, if the
+	// local country database knows it, and the targeting rule that chose
+	// the destination
+	CountryCode     string `json:"country_code,omitempty"`
+	TargetingRuleID string `json:"targeting_rule_id,omitempty"`
 }
 
 type ClickStats struct {
-	TotalClicks   int64            `json:"total_clicks"`
-	UniqueClicks  int64            `json:"unique_clicks"`
-	ClicksByDate  map[string]int64 `json:"clicks_by_date"`
-	TopReferrers  []ReferrerStat   `json:"top_referrers"`
-	TopCountries  []CountryStat    `json:"top_countries"`
-	TopBrowsers   []BrowserStat    `json:"top_browsers"`
-	TopDevices    []DeviceStat     `json:"top_devices"`
+	TotalClicks  int64            `json:"total_clicks"`
+	UniqueClicks int64            `json:"unique_clicks"`
+	ClicksByDate map[string]int64 `json:"clicks_by_date"`
+	TopReferrers []ReferrerStat   `json:"top_referrers"`
+	TopCountries []CountryStat    `json:"top_countries"`
+	TopBrowsers  []BrowserStat    `json:"top_browsers"`
+	TopDevices   []DeviceStat     `json:"top_devices"`
+
+	// Lockouts after wrong passwords in the period, so owners can tell
+	// someone is guessing at their link
+	PasswordLockouts    int64      `json:"password_lockouts"`
+	LastPasswordLockout *time.Time `json:"last_password_lockout,omitempty"`
 }
 
 type ReferrerStat struct {
@@ -0,0 +1,45 @@
+package entity
+
+import (
+	"strings"
+)
+
+// TargetingRule sends the visitors of a link that match all of its
+// conditions to URL instead of the link's OriginalURL. An empty condition
+// matches anything. Rules are tried in order and the first match wins.
+type TargetingRule struct {
+	ID      string `json:"id"`
+	Device  string `json:"device,omitempty"`  // as classified for clicks: Mobile, Tablet or Desktop
+	OS      string `json:"os,omitempty"`      // iOS, Android, Windows, macOS or Linux
+	Country string `json:"country,omitempty"` // ISO 3166-1 alpha-2 code, such as DE
+	URL     string `json:"url"`
+}
+
+// Matches reports whether the visit described by click meets the rule's
+// conditions.
+func (r *TargetingRule) Matches(click *Click) bool {
+	return matchesCondition(r.Device, click.Device) && matchesCondition(r.OS, click.OS) &&
+		matchesCondition(r.Country, click.CountryCode)
+}
+
+func matchesCondition(want, got string) bool {
+	return want == "" || strings.EqualFold(want, got)
+}
+
+// MatchTargetingRule returns the first targeting rule of u that click
+// matches, or nil when the visit goes to OriginalURL.
+func (u *URL) MatchTargetingRule(click *Click) *TargetingRule {
+	for i := range u.TargetingRules {
+		if u.TargetingRules[i].Matches(click) {
+			return &u.TargetingRules[i]
+		}
+	}
+	return nil
+}
+
+type TargetingRuleRequest struct {
+	Device  string `json:"device,omitempty" validate:"omitempty,oneof=Mobile Tablet Desktop"`
+	OS      string `json:"os,omitempty" validate:"omitempty,oneof=iOS Android Windows macOS Linux"`
+	Country string `json:"country,omitempty" validate:"omitempty,iso3166_1_alpha2"`
+	URL     string `json:"url" validate:"required,url"`
+}
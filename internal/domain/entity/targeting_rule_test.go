@@ -0,0 +1,41 @@
+package entity
+
+import "testing"
+
+func TestURL_MatchTargetingRule(t *testing.T) {
+	u := &URL{
+		OriginalURL: "https://example.com",
+		TargetingRules: []TargetingRule{
+			{ID: "germany", Country: "DE", URL: "https://example.com/de"},
+			{ID: "ipad", Device: "Tablet", OS: "iOS", URL: "https://example.com/ipad"},
+			{ID: "ios", OS: "iOS", URL: "https://example.com/ios"},
+			{ID: "mobile", Device: "Mobile", URL: "https://example.com/mobile"},
+		},
+	}
+
+	tests := []struct {
+		name  string
+		click Click
+		want  string // rule ID, empty for none
+	}{
+		{"all conditions", Click{Device: "Tablet", OS: "iOS"}, "ipad"},
+		{"first match wins", Click{Device: "Mobile", OS: "iOS"}, "ios"},
+		{"any os", Click{Device: "Mobile", OS: "Android"}, "mobile"},
+		{"case insensitive", Click{Device: "mobile", OS: "ios"}, "ios"},
+		{"country", Click{Device: "Mobile", OS: "iOS", CountryCode: "DE"}, "germany"},
+		{"unknown country", Click{Device: "Desktop", OS: "macOS"}, ""},
+		{"no match", Click{Device: "Desktop", OS: "macOS", CountryCode: "FR"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			if rule := u.MatchTargetingRule(&tt.click); rule != nil {
+				got = rule.ID
+			}
+			if got != tt.want {
+				t.Errorf("MatchTargetingRule() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,18 +4,31 @@ import (
 	"time"
 )
 
+// Redirect types are the HTTP statuses a link can redirect with. Permanent
+// ones are cached by browsers.
+const (
+	RedirectMovedPermanently  = 301
+	RedirectFound             = 302
+	RedirectTemporaryRedirect = 307
+	RedirectPermanentRedirect = 308
+)
+
 type URL struct {
 	ID           string     `json:"id"`
 	ShortCode    string     `json:"short_code"`
 	OriginalURL  string     `json:"original_url"`
 	CustomAlias  string     `json:"custom_alias,omitempty"`
 	UserID       string     `json:"user_id,omitempty"`
+	WorkspaceID  string     `json:"workspace_id,omitempty"`
 	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
 	ClickCount   int64      `json:"click_count"`
 	IsActive     bool       `json:"is_active"`
 	PasswordHash string     `json:"-"`
+	RedirectType int        `json:"redirect_type,omitempty"` // HTTP status, 0 for the server default
+	// TargetingRules send some visitors elsewhere, see MatchTargetingRule
+	TargetingRules []TargetingRule `json:"targeting_rules,omitempty"`
 }
 
 func (u *URL) IsExpired() bool {
@@ -30,14 +43,27 @@ func (u *URL) CanRedirect() bool {
 }
 
 type CreateURLRequest struct {
-	OriginalURL string  `json:"original_url" validate:"required,url"`
-	CustomAlias string  `json:"custom_alias,omitempty" validate:"omitempty,min=3,max=20,alphanum"`
-	ExpiresIn   *int    `json:"expires_in,omitempty"` // in hours
-	Password    string  `json:"password,omitempty" validate:"omitempty,min=4,max=50"`
-	UserID      string  `json:"-"`
+	OriginalURL  string `json:"original_url" validate:"required,url"`
+	CustomAlias  string `json:"custom_alias,omitempty" validate:"omitempty,min=3,max=20,alphanum"`
+	ExpiresIn    *int   `json:"expires_in,omitempty"` // in hours
+	Password     string `json:"password,omitempty" validate:"omitempty,min=8,max=50"`
+	WorkspaceID  string `json:"workspace_id,omitempty"`
+	RedirectType int    `json:"redirect_type,omitempty" validate:"omitempty,oneof=301 302 307 308"`
+	UserID       string `json:"-"`
+}
+
+type UpdateURLRequest struct {
+	OriginalURL    *string `json:"original_url,omitempty" validate:"omitempty,url"`
+	CustomAlias    *string `json:"custom_alias,omitempty" validate:"omitempty,min=3,max=20,alphanum"`
+	ExpiresIn      *int    `json:"expires_in,omitempty"` // in hours, 0 removes expiry
+	IsActive       *bool   `json:"is_active,omitempty"`
+	Password       *string `json:"password,omitempty" validate:"omitempty,min=8,max=50"`
+	RemovePassword bool    `json:"remove_password,omitempty"`
+	RedirectType   *int    `json:"redirect_type,omitempty" validate:"omitempty,oneof=0 301 302 307 308"` // 0 restores the server default
 }
 
 type URLResponse struct {
+	ID                string     `json:"id,omitempty"`
 	ShortCode         string     `json:"short_code"`
 	ShortURL          string     `json:"short_url"`
 	OriginalURL       string     `json:"original_url"`
@@ -46,6 +72,9 @@ type URLResponse struct {
 	ClickCount        int64      `json:"click_count"`
 	QRCodeURL         string     `json:"qr_code_url,omitempty"`
 	PasswordProtected bool       `json:"password_protected"`
+	IsActive          bool       `json:"is_active"`
+	WorkspaceID       string     `json:"workspace_id,omitempty"`
+	RedirectType      int        `json:"redirect_type"`
 }
 
 type VerifyPasswordRequest struct {
@@ -64,10 +93,10 @@ type BulkURLResult struct {
 }
 
 type BulkCreateURLResponse struct {
-	Total      int              `json:"total"`
-	Successful int              `json:"successful"`
-	Failed     int              `json:"failed"`
-	Results    []BulkURLResult  `json:"results"`
+	Total      int             `json:"total"`
+	Successful int             `json:"successful"`
+	Failed     int             `json:"failed"`
+	Results    []BulkURLResult `json:"results"`
 }
 
 type LinkPreview struct {
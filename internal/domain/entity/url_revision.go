@@ -0,0 +1,63 @@
+package entity
+
+import (
+	"time"
+)
+
+const (
+	RevisionActionCreate  = "create"
+	RevisionActionUpdate  = "update"
+	RevisionActionRestore = "restore"
+	// RevisionActionBaseline is the state of a link from before revisions
+	// were kept, recorded ahead of its next change.
+	RevisionActionBaseline = "baseline"
+)
+
+type URLRevision struct {
+	ID           string     `json:"id"`
+	URLID        string     `json:"url_id"`
+	Revision     int        `json:"revision"`
+	Action       string     `json:"action"`
+	ShortCode    string     `json:"short_code"`
+	OriginalURL  string     `json:"original_url"`
+	CustomAlias  string     `json:"custom_alias,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	IsActive     bool       `json:"is_active"`
+	PasswordHash string     `json:"-"`
+	RedirectType int        `json:"redirect_type,omitempty"`
+	ChangedBy    string     `json:"changed_by,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	// TargetingRules are restored with the rest of the link
+	TargetingRules []TargetingRule `json:"targeting_rules,omitempty"`
+}
+
+// NewURLRevision snapshots the editable state of a URL.
+func NewURLRevision(url *URL, action, changedBy string) *URLRevision {
+	return &URLRevision{
+		URLID:        url.ID,
+		Action:       action,
+		ShortCode:    url.ShortCode,
+		OriginalURL:  url.OriginalURL,
+		CustomAlias:  url.CustomAlias,
+		ExpiresAt:    url.ExpiresAt,
+		IsActive:     url.IsActive,
+		PasswordHash: url.PasswordHash,
+		RedirectType: url.RedirectType,
+		ChangedBy:    changedBy,
+
+		TargetingRules: url.TargetingRules,
+	}
+}
+
+type URLRevisionResponse struct {
+	Revision          int        `json:"revision"`
+	Action            string     `json:"action"`
+	ShortCode         string     `json:"short_code"`
+	OriginalURL       string     `json:"original_url"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	IsActive          bool       `json:"is_active"`
+	PasswordProtected bool       `json:"password_protected"`
+	RedirectType      int        `json:"redirect_type,omitempty"`
+	ChangedBy         string     `json:"changed_by,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
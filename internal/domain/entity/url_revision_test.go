@@ -0,0 +1,50 @@
+package entity
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewURLRevision(t *testing.T) {
+	expiry := time.Now().Add(24 * time.Hour)
+	url := &URL{
+		ID:           "123",
+		ShortCode:    "promo",
+		OriginalURL:  "https://example.com",
+		CustomAlias:  "promo",
+		ExpiresAt:    &expiry,
+		IsActive:     true,
+		PasswordHash: "hash",
+		ClickCount:   42,
+	}
+
+	rev := NewURLRevision(url, RevisionActionUpdate, "user123")
+
+	if rev.URLID != "123" {
+		t.Errorf("URLID = %q, want 123", rev.URLID)
+	}
+	if rev.Action != RevisionActionUpdate {
+		t.Errorf("Action = %q, want %q", rev.Action, RevisionActionUpdate)
+	}
+	if rev.ShortCode != "promo" || rev.CustomAlias != "promo" {
+		t.Errorf("ShortCode/CustomAlias = %q/%q, want promo/promo", rev.ShortCode, rev.CustomAlias)
+	}
+	if rev.OriginalURL != "https://example.com" {
+		t.Errorf("OriginalURL = %q, want https://example.com", rev.OriginalURL)
+	}
+	if rev.ExpiresAt != &expiry {
+		t.Error("ExpiresAt should be copied")
+	}
+	if !rev.IsActive {
+		t.Error("IsActive should be true")
+	}
+	if rev.PasswordHash != "hash" {
+		t.Errorf("PasswordHash = %q, want hash", rev.PasswordHash)
+	}
+	if rev.ChangedBy != "user123" {
+		t.Errorf("ChangedBy = %q, want user123", rev.ChangedBy)
+	}
+	if rev.Revision != 0 {
+		t.Errorf("Revision = %d, want 0 (assigned by repository)", rev.Revision)
+	}
+}
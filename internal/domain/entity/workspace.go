@@ -0,0 +1,67 @@
+package entity
+
+import (
+	"time"
+)
+
+type WorkspaceRole string
+
+const (
+	RoleOwner  WorkspaceRole = "owner"
+	RoleAdmin  WorkspaceRole = "admin"
+	RoleEditor WorkspaceRole = "editor"
+	RoleViewer WorkspaceRole = "viewer"
+)
+
+var roleRanks = map[WorkspaceRole]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+	RoleOwner:  4,
+}
+
+func (r WorkspaceRole) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// AtLeast reports whether r grants everything min does.
+func (r WorkspaceRole) AtLeast(min WorkspaceRole) bool {
+	return roleRanks[r] >= roleRanks[min] && r.IsValid()
+}
+
+type Workspace struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WorkspaceMember struct {
+	WorkspaceID string        `json:"workspace_id"`
+	UserID      string        `json:"user_id"`
+	Email       string        `json:"email,omitempty"`
+	Role        WorkspaceRole `json:"role"`
+	CreatedAt   time.Time     `json:"created_at"`
+}
+
+type CreateWorkspaceRequest struct {
+	Name string `json:"name" validate:"required,min=1,max=100"`
+}
+
+type AddWorkspaceMemberRequest struct {
+	Email string        `json:"email" validate:"required,email"`
+	Role  WorkspaceRole `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+type UpdateWorkspaceMemberRequest struct {
+	Role WorkspaceRole `json:"role" validate:"required,oneof=owner admin editor viewer"`
+}
+
+type WorkspaceResponse struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	Role      WorkspaceRole `json:"role,omitempty"` // Caller's role
+	CreatedAt time.Time     `json:"created_at"`
+}
@@ -0,0 +1,40 @@
+package entity
+
+import (
+	"testing"
+)
+
+func TestWorkspaceRole_AtLeast(t *testing.T) {
+	tests := []struct {
+		role WorkspaceRole
+		min  WorkspaceRole
+		want bool
+	}{
+		{RoleOwner, RoleAdmin, true},
+		{RoleAdmin, RoleAdmin, true},
+		{RoleEditor, RoleAdmin, false},
+		{RoleEditor, RoleViewer, true},
+		{RoleViewer, RoleEditor, false},
+		{WorkspaceRole(""), RoleViewer, false},
+		{WorkspaceRole("superuser"), RoleViewer, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.role)+">="+string(tt.min), func(t *testing.T) {
+			if got := tt.role.AtLeast(tt.min); got != tt.want {
+				t.Errorf("%q.AtLeast(%q) = %v, want %v", tt.role, tt.min, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorkspaceRole_IsValid(t *testing.T) {
+	for _, role := range []WorkspaceRole{RoleOwner, RoleAdmin, RoleEditor, RoleViewer} {
+		if !role.IsValid() {
+			t.Errorf("%q should be valid", role)
+		}
+	}
+	if WorkspaceRole("guest").IsValid() {
+		t.Error("guest should not be valid")
+	}
+}
@@ -2,16 +2,27 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/bimakw/url-shortener/internal/domain/entity"
 )
 
+// ErrAPIKeyReplaced is returned by APIKeyRepository.Rotate for a key that is
+// gone or was replaced already.
+var ErrAPIKeyReplaced = errors.New("api key replaced already")
+
 type APIKeyRepository interface {
 	Create(ctx context.Context, key *entity.APIKey) error
-	GetByKey(ctx context.Context, key string) (*entity.APIKey, error)
+	GetByKeyHash(ctx context.Context, keyHash string) (*entity.APIKey, error)
 	GetByID(ctx context.Context, id string) (*entity.APIKey, error)
 	GetByUserID(ctx context.Context, userID string) ([]*entity.APIKey, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID string) ([]*entity.APIKey, error)
 	UpdateLastUsed(ctx context.Context, id string) error
 	Delete(ctx context.Context, id string) error
 	Deactivate(ctx context.Context, id string) error
+	// Rotate creates newKey and links the key id to it, ending its life at
+	// expiresAt, the end of the rotation grace period, all at once. Of
+	// concurrent rotations of a key only one succeeds.
+	Rotate(ctx context.Context, id string, newKey *entity.APIKey, expiresAt time.Time) error
 }
@@ -0,0 +1,12 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+type AuditRepository interface {
+	Create(ctx context.Context, event *entity.AuditEvent) error
+	List(ctx context.Context, filter entity.AuditFilter) ([]*entity.AuditEvent, error)
+}
@@ -9,6 +9,9 @@ import (
 
 type ClickRepository interface {
 	Create(ctx context.Context, click *entity.Click) error
+	// CreateBatch stores several clicks at once. Clicks keep a CreatedAt that
+	// is already set, so queued clicks are stored with the time they happened.
+	CreateBatch(ctx context.Context, clicks []*entity.Click) error
 	GetByURLID(ctx context.Context, urlID string, limit, offset int) ([]*entity.Click, error)
 	GetStatsByURLID(ctx context.Context, urlID string, from, to time.Time) (*entity.ClickStats, error)
 	CountByURLID(ctx context.Context, urlID string) (int64, error)
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// PasswordAttemptRepository counts password attempts and keeps the lockouts
+// they lead to. Keys name what is being limited, such as a link or a client
+// IP.
+type PasswordAttemptRepository interface {
+	// Reserve counts an attempt for key, unless key is locked out. An attempt
+	// past the free ones locks key out in the same step, so concurrent
+	// attempts cannot slip in before the lockout.
+	Reserve(ctx context.Context, key string, policy AttemptPolicy) (AttemptReservation, error)
+	// Count counts an attempt for key like Reserve, but also while key is
+	// locked out, for attempts that are let through regardless.
+	Count(ctx context.Context, key string, policy AttemptPolicy) (AttemptReservation, error)
+	// Release gives back an attempt Reserve let through, for one that turned
+	// out right, and lifts the lockout of key unless its other attempts are
+	// past the free ones.
+	Release(ctx context.Context, key string, policy AttemptPolicy) error
+	// Reset forgets the attempts of key and lifts its lockout.
+	Reset(ctx context.Context, key string) error
+}
+
+// AttemptPolicy sets how many attempts a key gets and how long the lockouts
+// past them last.
+type AttemptPolicy struct {
+	Free int64
+	// Window is how long attempts are counted, from the first one.
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// Lockout returns how long the nth attempt past the free ones locks out for:
+// twice as long as the one before, from BaseLockout up to MaxLockout.
+func (p AttemptPolicy) Lockout(n int64) time.Duration {
+	d := p.BaseLockout
+	for ; n > 1 && d < p.MaxLockout; n-- {
+		d *= 2
+	}
+	return min(d, p.MaxLockout)
+}
+
+// AttemptReservation is the outcome of PasswordAttemptRepository.Reserve.
+type AttemptReservation struct {
+	// Allowed is false while the key is locked out, for LockedFor more.
+	Allowed bool
+	// Attempts counted so far, this one included.
+	Attempts int64
+	// LockedFor is how long the key is locked out after this attempt, zero
+	// while it has free attempts left.
+	LockedFor time.Duration
+}
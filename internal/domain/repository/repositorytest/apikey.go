@@ -0,0 +1,157 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+func testAPIKeyRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.APIKeys != nil }
+
+	create := func(t *testing.T, repo repository.APIKeyRepository, key *entity.APIKey) *entity.APIKey {
+		t.Helper()
+		if err := repo.Create(ctx, key); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return key
+	}
+
+	subtest(t, open, "CreateAndGet", needs, func(t *testing.T, r Repositories) {
+		key := create(t, r.APIKeys, &entity.APIKey{
+			KeyHash:   "hash-1",
+			KeyPrefix: "sk_00000001",
+			Name:      "ci",
+			UserID:    "user-1",
+			Scopes:    []string{entity.ScopeURLsRead, entity.ScopeStatsRead},
+			RateLimit: 50,
+		})
+		if key.ID == "" || key.CreatedAt.IsZero() || !key.IsActive {
+			t.Errorf("Create() left ID %q, CreatedAt %v, IsActive %v", key.ID, key.CreatedAt, key.IsActive)
+		}
+
+		for name, get := range map[string]func() (*entity.APIKey, error){
+			"GetByID":      func() (*entity.APIKey, error) { return r.APIKeys.GetByID(ctx, key.ID) },
+			"GetByKeyHash": func() (*entity.APIKey, error) { return r.APIKeys.GetByKeyHash(ctx, "hash-1") },
+		} {
+			got, err := get()
+			if err != nil || got == nil {
+				t.Fatalf("%s() = %v, %v", name, got, err)
+			}
+			if got.ID != key.ID || got.Name != "ci" || got.KeyPrefix != "sk_00000001" || got.RateLimit != 50 ||
+				!slices.Equal(got.Scopes, key.Scopes) || !got.IsActive || got.LastUsed != nil {
+				t.Errorf("%s() = %+v", name, got)
+			}
+		}
+
+		if got, err := r.APIKeys.GetByKeyHash(ctx, "missing"); got != nil || err != nil {
+			t.Errorf("GetByKeyHash(missing) = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	subtest(t, open, "DuplicateHash", needs, func(t *testing.T, r Repositories) {
+		create(t, r.APIKeys, &entity.APIKey{KeyHash: "same", Name: "a", UserID: "user-1", Scopes: entity.DefaultScopes})
+		if err := r.APIKeys.Create(ctx, &entity.APIKey{KeyHash: "same", Name: "b", UserID: "user-1", Scopes: entity.DefaultScopes}); err == nil {
+			t.Error("Create() with a taken key hash succeeded")
+		}
+	})
+
+	subtest(t, open, "ListByOwner", needs, func(t *testing.T, r Repositories) {
+		create(t, r.APIKeys, &entity.APIKey{KeyHash: "h1", Name: "first", UserID: "user-1", Scopes: entity.DefaultScopes})
+		create(t, r.APIKeys, &entity.APIKey{KeyHash: "h2", Name: "second", UserID: "user-1", WorkspaceID: "ws-1", Scopes: entity.DefaultScopes})
+		create(t, r.APIKeys, &entity.APIKey{KeyHash: "h3", Name: "other", UserID: "user-2", WorkspaceID: "ws-1", Scopes: entity.DefaultScopes})
+
+		keys, err := r.APIKeys.GetByUserID(ctx, "user-1")
+		if err != nil || len(keys) != 2 || keys[0].Name != "second" {
+			t.Errorf("GetByUserID() = %v, %v, want newest of two first", keys, err)
+		}
+
+		keys, err = r.APIKeys.GetByWorkspaceID(ctx, "ws-1")
+		if err != nil || len(keys) != 2 {
+			t.Errorf("GetByWorkspaceID() = %v, %v, want two keys", keys, err)
+		}
+	})
+
+	subtest(t, open, "Lifecycle", needs, func(t *testing.T, r Repositories) {
+		key := create(t, r.APIKeys, &entity.APIKey{KeyHash: "h1", Name: "ci", UserID: "user-1", Scopes: entity.DefaultScopes})
+
+		if err := r.APIKeys.UpdateLastUsed(ctx, key.ID); err != nil {
+			t.Fatalf("UpdateLastUsed() error = %v", err)
+		}
+		got, _ := r.APIKeys.GetByID(ctx, key.ID)
+		if got.LastUsed == nil {
+			t.Error("LastUsed not set by UpdateLastUsed()")
+		}
+
+		graceEnd := time.Now().Add(time.Hour)
+		newKey := &entity.APIKey{KeyHash: "h2", Name: "ci", UserID: "user-1", Scopes: entity.DefaultScopes}
+		if err := r.APIKeys.Rotate(ctx, key.ID, newKey, graceEnd); err != nil {
+			t.Fatalf("Rotate() error = %v", err)
+		}
+		got, _ = r.APIKeys.GetByID(ctx, key.ID)
+		if got.ReplacedBy != newKey.ID || got.ExpiresAt == nil || !sameTime(*got.ExpiresAt, graceEnd) {
+			t.Errorf("after Rotate() ReplacedBy = %q, ExpiresAt = %v", got.ReplacedBy, got.ExpiresAt)
+		}
+		if got, _ := r.APIKeys.GetByKeyHash(ctx, "h2"); got == nil || got.ID != newKey.ID || !got.IsActive {
+			t.Errorf("new key after Rotate() = %+v", got)
+		}
+
+		if err := r.APIKeys.Deactivate(ctx, key.ID); err != nil {
+			t.Fatalf("Deactivate() error = %v", err)
+		}
+		got, _ = r.APIKeys.GetByID(ctx, key.ID)
+		if got.IsActive {
+			t.Error("key still active after Deactivate()")
+		}
+
+		if err := r.APIKeys.Delete(ctx, key.ID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if got, _ := r.APIKeys.GetByID(ctx, key.ID); got != nil {
+			t.Error("key still exists after Delete()")
+		}
+	})
+
+	subtest(t, open, "ConcurrentRotate", needs, func(t *testing.T, r Repositories) {
+		key := create(t, r.APIKeys, &entity.APIKey{KeyHash: "h1", Name: "ci", UserID: "user-1", Scopes: entity.DefaultScopes})
+
+		var mu sync.Mutex
+		var rotated, replaced int
+		var wg sync.WaitGroup
+		for i := range 5 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				newKey := &entity.APIKey{KeyHash: fmt.Sprintf("h%d", i+2), Name: "ci", UserID: "user-1", Scopes: entity.DefaultScopes}
+				err := r.APIKeys.Rotate(ctx, key.ID, newKey, time.Now().Add(time.Hour))
+
+				mu.Lock()
+				defer mu.Unlock()
+				switch {
+				case err == nil:
+					rotated++
+				case errors.Is(err, repository.ErrAPIKeyReplaced):
+					replaced++
+				default:
+					t.Errorf("Rotate() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if rotated != 1 || replaced != 4 {
+			t.Errorf("%d rotations succeeded and %d found the key replaced, want 1 and 4", rotated, replaced)
+		}
+		if keys, _ := r.APIKeys.GetByUserID(ctx, "user-1"); len(keys) != 2 {
+			t.Errorf("%d keys after concurrent rotations, want the old and one new", len(keys))
+		}
+	})
+}
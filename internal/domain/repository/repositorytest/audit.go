@@ -0,0 +1,132 @@
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func testAuditRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.Audit != nil }
+
+	record := func(t *testing.T, r Repositories, event entity.AuditEvent) *entity.AuditEvent {
+		t.Helper()
+		if err := r.Audit.Create(ctx, &event); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return &event
+	}
+
+	subtest(t, open, "CreateAndList", needs, func(t *testing.T, r Repositories) {
+		changes := json.RawMessage(`{"original_url":{"old":"https://example.com","new":"https://example.org"}}`)
+		first := record(t, r, entity.AuditEvent{ActorUserID: "user-1", Action: entity.AuditActionURLCreate, TargetType: entity.AuditTargetURL, TargetID: "url-1"})
+		second := record(t, r, entity.AuditEvent{
+			ActorUserID:   "user-1",
+			ActorAPIKeyID: "key-1",
+			Action:        entity.AuditActionURLUpdate,
+			TargetType:    entity.AuditTargetURL,
+			TargetID:      "url-1",
+			WorkspaceID:   "ws-1",
+			IPAddress:     "10.0.0.1",
+			Changes:       changes,
+		})
+
+		if first.ID == 0 || second.ID <= first.ID || second.CreatedAt.IsZero() {
+			t.Errorf("Create() assigned IDs %d, %d, want increasing", first.ID, second.ID)
+		}
+
+		events, err := r.Audit.List(ctx, entity.AuditFilter{Limit: 10})
+		if err != nil || len(events) != 2 {
+			t.Fatalf("List() = %v, %v, want 2 events", events, err)
+		}
+
+		got := events[0]
+		if got.ID != second.ID || got.ActorAPIKeyID != "key-1" || got.WorkspaceID != "ws-1" || got.IPAddress != "10.0.0.1" {
+			t.Errorf("List()[0] = %+v, want the newest event", got)
+		}
+		var want, have any
+		_ = json.Unmarshal(changes, &want)
+		if err := json.Unmarshal(got.Changes, &have); err != nil || !jsonEqual(want, have) {
+			t.Errorf("Changes = %s, want %s", got.Changes, changes)
+		}
+		if len(events[1].Changes) != 0 {
+			t.Errorf("event without changes came back with %s", events[1].Changes)
+		}
+	})
+
+	subtest(t, open, "Filters", needs, func(t *testing.T, r Repositories) {
+		record(t, r, entity.AuditEvent{ActorUserID: "user-1", Action: entity.AuditActionURLCreate, TargetType: entity.AuditTargetURL, TargetID: "url-1"})
+		record(t, r, entity.AuditEvent{ActorUserID: "user-2", Action: entity.AuditActionURLDelete, TargetType: entity.AuditTargetURL, TargetID: "url-2", WorkspaceID: "ws-1"})
+		record(t, r, entity.AuditEvent{ActorUserID: "user-1", ActorAPIKeyID: "key-1", Action: entity.AuditActionAPIKeyCreate, TargetType: entity.AuditTargetAPIKey, TargetID: "key-2"})
+
+		future := time.Now().Add(time.Hour)
+		past := time.Now().Add(-time.Hour)
+
+		tests := []struct {
+			name   string
+			filter entity.AuditFilter
+			want   int
+		}{
+			{"actor", entity.AuditFilter{ActorUserID: "user-1"}, 2},
+			{"api key", entity.AuditFilter{ActorAPIKeyID: "key-1"}, 1},
+			{"action", entity.AuditFilter{Action: entity.AuditActionURLDelete}, 1},
+			{"target type", entity.AuditFilter{TargetType: entity.AuditTargetURL}, 2},
+			{"target", entity.AuditFilter{TargetType: entity.AuditTargetURL, TargetID: "url-1"}, 1},
+			{"workspace", entity.AuditFilter{WorkspaceID: "ws-1"}, 1},
+			{"from", entity.AuditFilter{From: &future}, 0},
+			{"to", entity.AuditFilter{To: &past}, 0},
+			{"range", entity.AuditFilter{From: &past, To: &future}, 3},
+			{"combined", entity.AuditFilter{ActorUserID: "user-1", TargetType: entity.AuditTargetURL}, 1},
+		}
+
+		for _, tt := range tests {
+			tt.filter.Limit = 10
+			events, err := r.Audit.List(ctx, tt.filter)
+			if err != nil || len(events) != tt.want {
+				t.Errorf("List(%s) = %d events, %v, want %d", tt.name, len(events), err, tt.want)
+			}
+		}
+	})
+
+	subtest(t, open, "Cursor", needs, func(t *testing.T, r Repositories) {
+		for i := 0; i < 5; i++ {
+			record(t, r, entity.AuditEvent{ActorUserID: "user-1", Action: entity.AuditActionURLUpdate, TargetType: entity.AuditTargetURL, TargetID: "url-1"})
+		}
+
+		var seen []int64
+		var cursor int64
+		for range 3 {
+			events, err := r.Audit.List(ctx, entity.AuditFilter{Cursor: cursor, Limit: 2})
+			if err != nil {
+				t.Fatalf("List() error = %v", err)
+			}
+			for _, event := range events {
+				seen = append(seen, event.ID)
+			}
+			if len(events) == 0 {
+				break
+			}
+			cursor = events[len(events)-1].ID
+		}
+
+		if len(seen) != 5 {
+			t.Fatalf("paged through %d events, want 5", len(seen))
+		}
+		for i := 1; i < len(seen); i++ {
+			if seen[i] >= seen[i-1] {
+				t.Errorf("events not in descending ID order: %v", seen)
+				break
+			}
+		}
+	})
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return string(x) == string(y)
+}
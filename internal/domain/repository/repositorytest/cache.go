@@ -0,0 +1,234 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+// Advance lets d pass for a cache under test, by sleeping or by moving the
+// clock of a fake server forward.
+type Advance func(d time.Duration)
+
+// RunURLCache runs the contract tests for a URL cache. open is called once
+// per test and must return an empty cache and how to let its entries expire.
+func RunURLCache(t *testing.T, open func(t *testing.T) (repository.URLCacheRepository, Advance)) {
+	ctx := context.Background()
+
+	t.Run("SetGetDelete", func(t *testing.T) {
+		cache, _ := open(t)
+
+		if got, err := cache.Get(ctx, "abc123"); got != nil || err != nil {
+			t.Errorf("Get(missing) = %v, %v, want nil, nil", got, err)
+		}
+
+		expiresAt := time.Now().Add(time.Hour)
+		url := &entity.URL{
+			ID:          "url-1",
+			ShortCode:   "abc123",
+			OriginalURL: "https://example.com",
+			ExpiresAt:   &expiresAt,
+			IsActive:    true,
+		}
+		if err := cache.Set(ctx, url); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+
+		got, err := cache.Get(ctx, "abc123")
+		if err != nil || got == nil {
+			t.Fatalf("Get() = %v, %v", got, err)
+		}
+		if got.ID != url.ID || got.OriginalURL != url.OriginalURL || !got.IsActive ||
+			got.ExpiresAt == nil || !sameTime(*got.ExpiresAt, expiresAt) {
+			t.Errorf("Get() = %+v, want %+v", got, url)
+		}
+
+		if err := cache.Delete(ctx, "abc123"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if got, _ := cache.Get(ctx, "abc123"); got != nil {
+			t.Error("Get() after Delete() found the link")
+		}
+	})
+
+	// A cache hit decides a redirect on its own, so everything the decision
+	// depends on must survive the round trip
+	t.Run("KeepsAccessFields", func(t *testing.T) {
+		cache, _ := open(t)
+
+		url := &entity.URL{
+			ID:             "url-1",
+			ShortCode:      "secret",
+			OriginalURL:    "https://example.com",
+			PasswordHash:   "$2a$10$hash",
+			RedirectType:   307,
+			TargetingRules: []entity.TargetingRule{{ID: "rule-1", OS: "iOS", URL: "https://example.com/ios"}},
+			IsActive:       false,
+		}
+		if err := cache.Set(ctx, url); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+
+		got, err := cache.Get(ctx, "secret")
+		if err != nil || got == nil {
+			t.Fatalf("Get() = %v, %v", got, err)
+		}
+		if got.PasswordHash != url.PasswordHash {
+			t.Errorf("PasswordHash = %q, want %q", got.PasswordHash, url.PasswordHash)
+		}
+		if got.RedirectType != url.RedirectType {
+			t.Errorf("RedirectType = %d, want %d", got.RedirectType, url.RedirectType)
+		}
+		if !slices.Equal(got.TargetingRules, url.TargetingRules) {
+			t.Errorf("TargetingRules = %+v, want %+v", got.TargetingRules, url.TargetingRules)
+		}
+		if got.IsActive {
+			t.Error("IsActive = true, want false")
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		cache, advance := open(t)
+
+		if err := cache.SetNotFound(ctx, "wp-admin", time.Hour); err != nil {
+			t.Fatalf("SetNotFound() error = %v", err)
+		}
+		if got, err := cache.Get(ctx, "wp-admin"); got != nil || !errors.Is(err, repository.ErrCachedNotFound) {
+			t.Errorf("Get() = %v, %v, want ErrCachedNotFound", got, err)
+		}
+
+		// Creating the link replaces the entry
+		if err := cache.Set(ctx, &entity.URL{ID: "url-1", ShortCode: "wp-admin", OriginalURL: "https://example.com"}); err != nil {
+			t.Fatalf("Set() error = %v", err)
+		}
+		if got, err := cache.Get(ctx, "wp-admin"); got == nil || err != nil {
+			t.Errorf("Get() after Set() = %v, %v, want the link", got, err)
+		}
+
+		// but a cached link is not replaced by a stale miss
+		if err := cache.SetNotFound(ctx, "wp-admin", time.Hour); err != nil {
+			t.Fatalf("SetNotFound() error = %v", err)
+		}
+		if got, err := cache.Get(ctx, "wp-admin"); got == nil || err != nil {
+			t.Errorf("Get() after SetNotFound() on a link = %v, %v, want the link", got, err)
+		}
+
+		if err := cache.SetNotFound(ctx, "gone", 50*time.Millisecond); err != nil {
+			t.Fatalf("SetNotFound() error = %v", err)
+		}
+		advance(100 * time.Millisecond)
+		if got, err := cache.Get(ctx, "gone"); got != nil || err != nil {
+			t.Errorf("Get() after the TTL = %v, %v, want nil, nil", got, err)
+		}
+
+		if err := cache.SetNotFound(ctx, "gone", time.Hour); err != nil {
+			t.Fatalf("SetNotFound() error = %v", err)
+		}
+		if err := cache.Delete(ctx, "gone"); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if got, err := cache.Get(ctx, "gone"); got != nil || err != nil {
+			t.Errorf("Get() after Delete() = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	t.Run("PendingClicks", func(t *testing.T) {
+		cache, _ := open(t)
+
+		if err := cache.AddPendingClicks(ctx, map[string]int64{"a": 2, "b": 1}); err != nil {
+			t.Fatalf("AddPendingClicks() error = %v", err)
+		}
+		if err := cache.AddPendingClicks(ctx, map[string]int64{"a": 1}); err != nil {
+			t.Fatalf("AddPendingClicks() error = %v", err)
+		}
+
+		want := map[string]int64{"a": 3, "b": 1}
+		got, err := cache.GetPendingClicks(ctx, []string{"a", "b", "c"})
+		if err != nil || !maps.Equal(got, want) {
+			t.Errorf("GetPendingClicks() = %v, %v, want %v", got, err, want)
+		}
+
+		batch, err := cache.TakePendingClicks(ctx)
+		if err != nil || batch == nil || !maps.Equal(batch.Counts, want) {
+			t.Fatalf("TakePendingClicks() = %v, %v, want %v", batch, err, want)
+		}
+		if got, err := cache.TakePendingClicks(ctx); got != nil || err != nil {
+			t.Errorf("TakePendingClicks() after take = %v, %v, want nil, nil", got, err)
+		}
+
+		// A taken batch still counts until it is done
+		if got, _ := cache.GetPendingClicks(ctx, []string{"a", "b"}); !maps.Equal(got, want) {
+			t.Errorf("GetPendingClicks() after take = %v, want %v", got, want)
+		}
+		if err := cache.DonePendingClicks(ctx, batch.ID); err != nil {
+			t.Fatalf("DonePendingClicks() error = %v", err)
+		}
+		if got, _ := cache.GetPendingClicks(ctx, []string{"a", "b"}); len(got) != 0 {
+			t.Errorf("GetPendingClicks() after done = %v, want none", got)
+		}
+	})
+
+	t.Run("ReturnPendingClicks", func(t *testing.T) {
+		cache, _ := open(t)
+
+		_ = cache.AddPendingClicks(ctx, map[string]int64{"a": 2})
+		batch, err := cache.TakePendingClicks(ctx)
+		if err != nil || batch == nil {
+			t.Fatalf("TakePendingClicks() = %v, %v", batch, err)
+		}
+
+		// Clicks counted meanwhile are kept alongside the returned ones
+		_ = cache.AddPendingClicks(ctx, map[string]int64{"a": 1, "b": 1})
+		if got, _ := cache.GetPendingClicks(ctx, []string{"a", "b"}); got["a"] != 3 || got["b"] != 1 {
+			t.Errorf("GetPendingClicks() = %v, want a:3 b:1", got)
+		}
+		if err := cache.ReturnPendingClicks(ctx, batch.ID); err != nil {
+			t.Fatalf("ReturnPendingClicks() error = %v", err)
+		}
+
+		want := map[string]int64{"a": 3, "b": 1}
+		batch, err = cache.TakePendingClicks(ctx)
+		if err != nil || batch == nil || !maps.Equal(batch.Counts, want) {
+			t.Errorf("TakePendingClicks() after return = %v, %v, want %v", batch, err, want)
+		}
+	})
+
+	t.Run("ClaimPendingClicks", func(t *testing.T) {
+		cache, _ := open(t)
+
+		_ = cache.AddPendingClicks(ctx, map[string]int64{"a": 2})
+		batch, err := cache.TakePendingClicks(ctx)
+		if err != nil || batch == nil {
+			t.Fatalf("TakePendingClicks() = %v, %v", batch, err)
+		}
+
+		if got, err := cache.ClaimPendingClicks(ctx, time.Hour); len(got) != 0 || err != nil {
+			t.Errorf("ClaimPendingClicks() of a recent batch = %v, %v, want none", got, err)
+		}
+
+		time.Sleep(5 * time.Millisecond)
+		got, err := cache.ClaimPendingClicks(ctx, time.Millisecond)
+		if err != nil || len(got) != 1 || got[0].ID != batch.ID || !maps.Equal(got[0].Counts, batch.Counts) {
+			t.Fatalf("ClaimPendingClicks() = %v, %v, want the taken batch", got, err)
+		}
+
+		// Claiming marks the batch as taken again
+		if got, err := cache.ClaimPendingClicks(ctx, time.Second); len(got) != 0 || err != nil {
+			t.Errorf("ClaimPendingClicks() after claim = %v, %v, want none", got, err)
+		}
+
+		if err := cache.DonePendingClicks(ctx, batch.ID); err != nil {
+			t.Fatalf("DonePendingClicks() error = %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+		if got, err := cache.ClaimPendingClicks(ctx, time.Millisecond); len(got) != 0 || err != nil {
+			t.Errorf("ClaimPendingClicks() after done = %v, %v, want none", got, err)
+		}
+	})
+}
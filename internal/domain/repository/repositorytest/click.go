@@ -0,0 +1,137 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func testClickRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.URLs != nil && r.Clicks != nil }
+
+	// seed records clicks on a new link, one per IP, and returns the link
+	seed := func(t *testing.T, r Repositories, clicks ...entity.Click) *entity.URL {
+		t.Helper()
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "clicked", OriginalURL: "https://example.com"})
+		for _, click := range clicks {
+			click.URLID = url.ID
+			click.ShortCode = url.ShortCode
+			if err := r.Clicks.Create(ctx, &click); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+		}
+		return url
+	}
+
+	subtest(t, open, "CreateAndList", needs, func(t *testing.T, r Repositories) {
+		url := seed(t, r,
+			entity.Click{IPAddress: "10.0.0.1", UserAgent: "first"},
+			entity.Click{IPAddress: "10.0.0.2", UserAgent: "second"},
+			entity.Click{IPAddress: "10.0.0.1", UserAgent: "third", CountryCode: "DE", TargetingRuleID: "rule-1"},
+		)
+
+		clicks, err := r.Clicks.GetByURLID(ctx, url.ID, 2, 0)
+		if err != nil {
+			t.Fatalf("GetByURLID() error = %v", err)
+		}
+		if len(clicks) != 2 || clicks[0].UserAgent != "third" || clicks[1].UserAgent != "second" {
+			t.Errorf("GetByURLID(limit 2) = %v, want newest two", clicks)
+		}
+		if clicks[0].ID == "" || clicks[0].CreatedAt.IsZero() || clicks[0].URLID != url.ID ||
+			clicks[0].CountryCode != "DE" || clicks[0].TargetingRuleID != "rule-1" {
+			t.Errorf("stored click = %+v", clicks[0])
+		}
+
+		if count, err := r.Clicks.CountByURLID(ctx, url.ID); err != nil || count != 3 {
+			t.Errorf("CountByURLID() = %d, %v, want 3", count, err)
+		}
+		if count, err := r.Clicks.CountUniqueByURLID(ctx, url.ID); err != nil || count != 2 {
+			t.Errorf("CountUniqueByURLID() = %d, %v, want 2", count, err)
+		}
+	})
+
+	subtest(t, open, "CreateBatch", needs, func(t *testing.T, r Repositories) {
+		url := seed(t, r)
+		happened := time.Now().Add(-time.Hour)
+
+		clicks := make([]*entity.Click, 1500) // more than one INSERT holds
+		for i := range clicks {
+			clicks[i] = &entity.Click{URLID: url.ID, ShortCode: url.ShortCode, IPAddress: "10.0.0.1"}
+		}
+		clicks[0].CreatedAt = happened
+
+		if err := r.Clicks.CreateBatch(ctx, clicks); err != nil {
+			t.Fatalf("CreateBatch() error = %v", err)
+		}
+		if err := r.Clicks.CreateBatch(ctx, nil); err != nil {
+			t.Errorf("CreateBatch(nil) error = %v", err)
+		}
+
+		if count, err := r.Clicks.CountByURLID(ctx, url.ID); err != nil || count != int64(len(clicks)) {
+			t.Errorf("CountByURLID() = %d, %v, want %d", count, err, len(clicks))
+		}
+		if clicks[1].ID == "" || clicks[1].CreatedAt.IsZero() {
+			t.Errorf("CreateBatch() left ID %q, CreatedAt %v", clicks[1].ID, clicks[1].CreatedAt)
+		}
+
+		// A preset time is kept, so the oldest click is the first one
+		oldest, _ := r.Clicks.GetByURLID(ctx, url.ID, 1, len(clicks)-1)
+		if len(oldest) != 1 || oldest[0].ID != clicks[0].ID || !sameTime(oldest[0].CreatedAt, happened) {
+			t.Errorf("oldest click = %v, want %s at %v", oldest, clicks[0].ID, happened)
+		}
+	})
+
+	subtest(t, open, "Stats", needs, func(t *testing.T, r Repositories) {
+		url := seed(t, r,
+			entity.Click{IPAddress: "10.0.0.1", Browser: "Firefox", Device: "Desktop", Country: "ID", Referrer: "https://news.example"},
+			entity.Click{IPAddress: "10.0.0.1", Browser: "Firefox", Device: "Mobile", Country: "ID"},
+			entity.Click{IPAddress: "10.0.0.2", Browser: "Chrome", Device: "Mobile"},
+		)
+
+		stats, err := r.Clicks.GetStatsByURLID(ctx, url.ID, time.Now().Add(-time.Hour), time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatalf("GetStatsByURLID() error = %v", err)
+		}
+
+		if stats.TotalClicks != 3 || stats.UniqueClicks != 2 {
+			t.Errorf("clicks = %d total, %d unique, want 3 and 2", stats.TotalClicks, stats.UniqueClicks)
+		}
+
+		var byDate int64
+		for _, count := range stats.ClicksByDate {
+			byDate += count
+		}
+		if byDate != 3 {
+			t.Errorf("ClicksByDate = %v, want 3 clicks in total", stats.ClicksByDate)
+		}
+
+		if len(stats.TopBrowsers) != 2 || stats.TopBrowsers[0] != (entity.BrowserStat{Browser: "Firefox", Count: 2}) {
+			t.Errorf("TopBrowsers = %v", stats.TopBrowsers)
+		}
+		if len(stats.TopDevices) != 2 || stats.TopDevices[0] != (entity.DeviceStat{Device: "Mobile", Count: 2}) {
+			t.Errorf("TopDevices = %v", stats.TopDevices)
+		}
+		if len(stats.TopCountries) != 2 || stats.TopCountries[0] != (entity.CountryStat{Country: "ID", Count: 2}) ||
+			stats.TopCountries[1] != (entity.CountryStat{Country: "Unknown", Count: 1}) {
+			t.Errorf("TopCountries = %v", stats.TopCountries)
+		}
+		if len(stats.TopReferrers) != 2 || stats.TopReferrers[0] != (entity.ReferrerStat{Referrer: "Direct", Count: 2}) {
+			t.Errorf("TopReferrers = %v", stats.TopReferrers)
+		}
+	})
+
+	subtest(t, open, "StatsRange", needs, func(t *testing.T, r Repositories) {
+		url := seed(t, r, entity.Click{IPAddress: "10.0.0.1"})
+
+		stats, err := r.Clicks.GetStatsByURLID(ctx, url.ID, time.Now().Add(time.Hour), time.Now().Add(2*time.Hour))
+		if err != nil {
+			t.Fatalf("GetStatsByURLID() error = %v", err)
+		}
+		if stats.TotalClicks != 0 || len(stats.ClicksByDate) != 0 {
+			t.Errorf("stats outside the range = %+v, want none", stats)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package repositorytest
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+// RunPasswordAttempts runs the contract tests for a password attempt store.
+// open is called once per test and must return an empty store.
+func RunPasswordAttempts(t *testing.T, open func(t *testing.T) repository.PasswordAttemptRepository) {
+	ctx := context.Background()
+	policy := repository.AttemptPolicy{Free: 3, Window: time.Hour, BaseLockout: time.Minute, MaxLockout: time.Hour}
+
+	t.Run("ReserveReset", func(t *testing.T) {
+		attempts := open(t)
+
+		for want := int64(1); want <= 3; want++ {
+			got, err := attempts.Reserve(ctx, "code:url-1", policy)
+			if err != nil || !got.Allowed || got.Attempts != want || got.LockedFor != 0 {
+				t.Fatalf("Reserve() = %+v, %v, want attempt %d allowed", got, err, want)
+			}
+		}
+		if got, err := attempts.Reserve(ctx, "ip:192.0.2.1", policy); err != nil || got.Attempts != 1 {
+			t.Errorf("Reserve(other key) = %+v, %v, want attempt 1", got, err)
+		}
+
+		if err := attempts.Reset(ctx, "code:url-1"); err != nil {
+			t.Fatalf("Reset() error = %v", err)
+		}
+		if got, err := attempts.Reserve(ctx, "code:url-1", policy); err != nil || got.Attempts != 1 {
+			t.Errorf("Reserve() after Reset() = %+v, %v, want attempt 1", got, err)
+		}
+		if got, err := attempts.Reserve(ctx, "ip:192.0.2.1", policy); err != nil || got.Attempts != 2 {
+			t.Errorf("Reserve(other key) after Reset() = %+v, %v, want attempt 2", got, err)
+		}
+	})
+
+	t.Run("Lockout", func(t *testing.T) {
+		attempts := open(t)
+
+		for range policy.Free {
+			_, _ = attempts.Reserve(ctx, "code:url-1", policy)
+		}
+
+		// The attempt past the free ones is let through and locks the key out
+		got, err := attempts.Reserve(ctx, "code:url-1", policy)
+		if err != nil || !got.Allowed || got.Attempts != 4 || got.LockedFor != time.Minute {
+			t.Fatalf("Reserve() past the free attempts = %+v, %v, want allowed with a minute lockout", got, err)
+		}
+
+		got, err = attempts.Reserve(ctx, "code:url-1", policy)
+		if err != nil || got.Allowed || got.LockedFor <= 0 || got.LockedFor > time.Minute {
+			t.Errorf("Reserve() while locked out = %+v, %v, want denied for up to a minute", got, err)
+		}
+		if got, err := attempts.Reserve(ctx, "code:url-2", policy); err != nil || !got.Allowed {
+			t.Errorf("Reserve(other key) = %+v, %v, want allowed", got, err)
+		}
+
+		// Reset lifts the lockout
+		if err := attempts.Reset(ctx, "code:url-1"); err != nil {
+			t.Fatalf("Reset() error = %v", err)
+		}
+		if got, err := attempts.Reserve(ctx, "code:url-1", policy); err != nil || !got.Allowed || got.Attempts != 1 {
+			t.Errorf("Reserve() after Reset() = %+v, %v, want attempt 1 allowed", got, err)
+		}
+	})
+
+	t.Run("Count", func(t *testing.T) {
+		attempts := open(t)
+
+		for range policy.Free + 1 {
+			_, _ = attempts.Reserve(ctx, "code:url-1", policy)
+		}
+
+		// Counted while locked out, which locks the key out for longer
+		got, err := attempts.Count(ctx, "code:url-1", policy)
+		if err != nil || !got.Allowed || got.Attempts != 5 || got.LockedFor != 2*time.Minute {
+			t.Errorf("Count() while locked out = %+v, %v, want attempt 5 allowed with a two minute lockout", got, err)
+		}
+		if got, _ := attempts.Reserve(ctx, "code:url-1", policy); got.Allowed || got.LockedFor <= time.Minute {
+			t.Errorf("Reserve() after Count() = %+v, want denied for more than a minute", got)
+		}
+	})
+
+	t.Run("Release", func(t *testing.T) {
+		attempts := open(t)
+
+		for range policy.Free {
+			_, _ = attempts.Reserve(ctx, "ip:192.0.2.1", policy)
+		}
+		if got, _ := attempts.Reserve(ctx, "ip:192.0.2.1", policy); got.LockedFor != time.Minute {
+			t.Fatalf("Reserve() past the free attempts = %+v, want a minute lockout", got)
+		}
+
+		// Giving back the attempt that locked the key out lifts the lockout,
+		// but the attempts before it still count
+		if err := attempts.Release(ctx, "ip:192.0.2.1", policy); err != nil {
+			t.Fatalf("Release() error = %v", err)
+		}
+		got, err := attempts.Reserve(ctx, "ip:192.0.2.1", policy)
+		if err != nil || !got.Allowed || got.Attempts != 4 || got.LockedFor != time.Minute {
+			t.Errorf("Reserve() after Release() = %+v, %v, want attempt 4 allowed with a minute lockout", got, err)
+		}
+
+		if err := attempts.Release(ctx, "missing", policy); err != nil {
+			t.Errorf("Release(unknown key) error = %v", err)
+		}
+	})
+
+	t.Run("ConcurrentReserve", func(t *testing.T) {
+		attempts := open(t)
+
+		var mu sync.Mutex
+		var allowed int
+		var wg sync.WaitGroup
+		for range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				got, err := attempts.Reserve(ctx, "code:url-1", policy)
+				if err != nil {
+					t.Errorf("Reserve() error = %v", err)
+					return
+				}
+				if got.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+
+		if want := int(policy.Free) + 1; allowed != want {
+			t.Errorf("%d concurrent attempts allowed, want %d", allowed, want)
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Package repositorytest is a contract test suite for implementations of
+// the interfaces in package repository. Every adapter runs it from its own
+// tests, so they all behave the same way behind the interfaces:
+//
+//	func TestContract(t *testing.T) {
+//		repositorytest.Run(t, func(t *testing.T) repositorytest.Repositories {
+//			db := openEmptyDatabase(t)
+//			return repositorytest.Repositories{URLs: NewURLRepository(db), ...}
+//		})
+//	}
+package repositorytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+// Repositories are the implementations under test. They must share one
+// store, as some tests create rows through one repository that another one
+// refers to. Tests for nil repositories are skipped.
+type Repositories struct {
+	URLs       repository.URLRepository
+	Clicks     repository.ClickRepository
+	APIKeys    repository.APIKeyRepository
+	Users      repository.UserRepository
+	Revisions  repository.URLRevisionRepository
+	Workspaces repository.WorkspaceRepository
+	Audit      repository.AuditRepository
+}
+
+// Open returns repositories backed by an empty store. It is called once per
+// test.
+type Open func(t *testing.T) Repositories
+
+// Run runs the contract tests for every repository open provides.
+func Run(t *testing.T, open Open) {
+	t.Run("URLRepository", func(t *testing.T) { testURLRepository(t, open) })
+	t.Run("ClickRepository", func(t *testing.T) { testClickRepository(t, open) })
+	t.Run("APIKeyRepository", func(t *testing.T) { testAPIKeyRepository(t, open) })
+	t.Run("UserRepository", func(t *testing.T) { testUserRepository(t, open) })
+	t.Run("URLRevisionRepository", func(t *testing.T) { testURLRevisionRepository(t, open) })
+	t.Run("WorkspaceRepository", func(t *testing.T) { testWorkspaceRepository(t, open) })
+	t.Run("AuditRepository", func(t *testing.T) { testAuditRepository(t, open) })
+}
+
+// subtest runs fn on fresh repositories, or skips it if open does not
+// provide everything it needs.
+func subtest(t *testing.T, open Open, name string, needs func(Repositories) bool, fn func(t *testing.T, repos Repositories)) {
+	t.Run(name, func(t *testing.T) {
+		repos := open(t)
+		if !needs(repos) {
+			t.Skip("repository not provided")
+		}
+		fn(t, repos)
+	})
+}
+
+// sameTime compares timestamps at millisecond precision, as databases store
+// them with less precision than time.Time has.
+func sameTime(a, b time.Time) bool {
+	d := a.Sub(b)
+	return d > -time.Millisecond && d < time.Millisecond
+}
+
+func mustCreateURL(t *testing.T, repo repository.URLRepository, url *entity.URL) *entity.URL {
+	t.Helper()
+	if err := repo.Create(context.Background(), url); err != nil {
+		t.Fatalf("URLRepository.Create(%s) error = %v", url.ShortCode, err)
+	}
+	return url
+}
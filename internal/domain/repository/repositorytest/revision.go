@@ -0,0 +1,171 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func testURLRevisionRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.URLs != nil && r.Revisions != nil }
+
+	subtest(t, open, "SequentialRevisions", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "revised", OriginalURL: "https://example.com"})
+		other := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "other", OriginalURL: "https://example.com"})
+
+		for i, target := range []*entity.URL{url, url, other, url} {
+			rev := entity.NewURLRevision(target, entity.RevisionActionUpdate, "user-1")
+			if err := r.Revisions.Create(ctx, rev); err != nil {
+				t.Fatalf("Create() #%d error = %v", i, err)
+			}
+		}
+
+		revisions, err := r.Revisions.GetByURLID(ctx, url.ID)
+		if err != nil {
+			t.Fatalf("GetByURLID() error = %v", err)
+		}
+		if len(revisions) != 3 {
+			t.Fatalf("GetByURLID() returned %d revisions, want 3", len(revisions))
+		}
+		for i, rev := range revisions {
+			if want := 3 - i; rev.Revision != want {
+				t.Errorf("revisions[%d].Revision = %d, want %d", i, rev.Revision, want)
+			}
+		}
+
+		otherRevisions, _ := r.Revisions.GetByURLID(ctx, other.ID)
+		if len(otherRevisions) != 1 || otherRevisions[0].Revision != 1 {
+			t.Errorf("revisions of another link = %v, want numbering from 1", otherRevisions)
+		}
+	})
+
+	subtest(t, open, "ConcurrentRevisions", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "revised", OriginalURL: "https://example.com"})
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := r.Revisions.Create(ctx, entity.NewURLRevision(url, entity.RevisionActionUpdate, "user-1")); err != nil {
+					t.Errorf("Create() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		revisions, err := r.Revisions.GetByURLID(ctx, url.ID)
+		if err != nil {
+			t.Fatalf("GetByURLID() error = %v", err)
+		}
+		if len(revisions) != 10 {
+			t.Fatalf("GetByURLID() returned %d revisions, want 10", len(revisions))
+		}
+		if revisions[0].Revision != 10 {
+			t.Errorf("latest revision = %d, want 10", revisions[0].Revision)
+		}
+	})
+
+	subtest(t, open, "Edit", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "revised", OriginalURL: "https://example.com"})
+
+		edited, err := r.Revisions.Edit(ctx, url.ID, entity.RevisionActionUpdate, "user-1", func(url *entity.URL) error {
+			url.OriginalURL = "https://example.com/new"
+			return nil
+		})
+		if err != nil || edited == nil || edited.OriginalURL != "https://example.com/new" {
+			t.Fatalf("Edit() = %v, %v", edited, err)
+		}
+		if got, _ := r.URLs.GetByID(ctx, url.ID); got.OriginalURL != "https://example.com/new" {
+			t.Errorf("stored OriginalURL = %q after Edit()", got.OriginalURL)
+		}
+		revisions, _ := r.Revisions.GetByURLID(ctx, url.ID)
+		if len(revisions) != 1 || revisions[0].OriginalURL != "https://example.com/new" || revisions[0].ChangedBy != "user-1" {
+			t.Errorf("revisions after Edit() = %v, want one of the change", revisions)
+		}
+
+		// A failing edit changes nothing
+		failed := errors.New("failed")
+		_, err = r.Revisions.Edit(ctx, url.ID, entity.RevisionActionUpdate, "user-1", func(url *entity.URL) error {
+			url.OriginalURL = "https://example.com/failed"
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("Edit() error = %v, want the edit's", err)
+		}
+		if got, _ := r.URLs.GetByID(ctx, url.ID); got.OriginalURL != "https://example.com/new" {
+			t.Errorf("stored OriginalURL = %q after a failed Edit()", got.OriginalURL)
+		}
+		if revisions, _ := r.Revisions.GetByURLID(ctx, url.ID); len(revisions) != 1 {
+			t.Errorf("%d revisions after a failed Edit(), want 1", len(revisions))
+		}
+
+		if got, err := r.Revisions.Edit(ctx, "00000000-0000-0000-0000-000000000000", entity.RevisionActionUpdate, "", func(*entity.URL) error { return nil }); got != nil || err != nil {
+			t.Errorf("Edit(missing) = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	// Each edit sees the one before, and the latest revision is the stored
+	// link
+	subtest(t, open, "ConcurrentEdits", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "revised", OriginalURL: "https://example.com/"})
+
+		var wg sync.WaitGroup
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := r.Revisions.Edit(ctx, url.ID, entity.RevisionActionUpdate, "user-1", func(url *entity.URL) error {
+					url.OriginalURL += "x"
+					return nil
+				})
+				if err != nil {
+					t.Errorf("Edit() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, _ := r.URLs.GetByID(ctx, url.ID)
+		if want := "https://example.com/" + strings.Repeat("x", 10); got.OriginalURL != want {
+			t.Errorf("OriginalURL = %q, want %q", got.OriginalURL, want)
+		}
+		revisions, err := r.Revisions.GetByURLID(ctx, url.ID)
+		if err != nil || len(revisions) != 10 {
+			t.Fatalf("GetByURLID() = %d revisions, %v, want 10", len(revisions), err)
+		}
+		if revisions[0].OriginalURL != got.OriginalURL {
+			t.Errorf("latest revision has %q, want the stored %q", revisions[0].OriginalURL, got.OriginalURL)
+		}
+	})
+
+	subtest(t, open, "GetByRevision", needs, func(t *testing.T, r Repositories) {
+		rules := []entity.TargetingRule{{ID: "rule-1", Device: "Tablet", URL: "https://example.com/tablet"}}
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "revised", OriginalURL: "https://example.com", PasswordHash: "hash", RedirectType: 308, TargetingRules: rules})
+		if err := r.Revisions.Create(ctx, entity.NewURLRevision(url, entity.RevisionActionCreate, "user-1")); err != nil {
+			t.Fatal(err)
+		}
+
+		rev, err := r.Revisions.GetByRevision(ctx, url.ID, 1)
+		if err != nil || rev == nil {
+			t.Fatalf("GetByRevision(1) = %v, %v", rev, err)
+		}
+		if rev.Action != entity.RevisionActionCreate || rev.ShortCode != "revised" || rev.PasswordHash != "hash" ||
+			rev.RedirectType != 308 || rev.ChangedBy != "user-1" || !rev.IsActive {
+			t.Errorf("GetByRevision(1) = %+v", rev)
+		}
+		if !slices.Equal(rev.TargetingRules, rules) {
+			t.Errorf("TargetingRules = %+v, want %+v", rev.TargetingRules, rules)
+		}
+
+		if rev, err := r.Revisions.GetByRevision(ctx, url.ID, 2); rev != nil || err != nil {
+			t.Errorf("GetByRevision(missing) = %v, %v, want nil, nil", rev, err)
+		}
+	})
+}
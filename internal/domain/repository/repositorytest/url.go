@@ -0,0 +1,226 @@
+package repositorytest
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func testURLRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.URLs != nil }
+
+	subtest(t, open, "CreateAndGet", needs, func(t *testing.T, r Repositories) {
+		expiresAt := time.Now().Add(time.Hour)
+		url := mustCreateURL(t, r.URLs, &entity.URL{
+			ShortCode:    "abc123",
+			OriginalURL:  "https://example.com",
+			CustomAlias:  "example",
+			UserID:       "user-1",
+			ExpiresAt:    &expiresAt,
+			PasswordHash: "hash",
+			RedirectType: 307,
+		})
+
+		if url.ID == "" || url.CreatedAt.IsZero() || !url.IsActive {
+			t.Errorf("Create() left ID %q, CreatedAt %v, IsActive %v", url.ID, url.CreatedAt, url.IsActive)
+		}
+
+		got, err := r.URLs.GetByID(ctx, url.ID)
+		if err != nil || got == nil {
+			t.Fatalf("GetByID() = %v, %v", got, err)
+		}
+		if got.ShortCode != "abc123" || got.OriginalURL != "https://example.com" || got.CustomAlias != "example" ||
+			got.UserID != "user-1" || got.PasswordHash != "hash" || got.RedirectType != 307 || !got.IsActive {
+			t.Errorf("GetByID() = %+v", got)
+		}
+		if got.ExpiresAt == nil || !sameTime(*got.ExpiresAt, expiresAt) {
+			t.Errorf("ExpiresAt = %v, want %v", got.ExpiresAt, expiresAt)
+		}
+
+		for _, code := range []string{"abc123", "example"} {
+			got, err := r.URLs.GetByShortCode(ctx, code)
+			if err != nil || got == nil || got.ID != url.ID {
+				t.Errorf("GetByShortCode(%q) = %v, %v", code, got, err)
+			}
+		}
+	})
+
+	subtest(t, open, "Missing", needs, func(t *testing.T, r Repositories) {
+		if got, err := r.URLs.GetByShortCode(ctx, "missing"); got != nil || err != nil {
+			t.Errorf("GetByShortCode(missing) = %v, %v, want nil, nil", got, err)
+		}
+		if got, err := r.URLs.GetByID(ctx, "00000000-0000-0000-0000-000000000000"); got != nil || err != nil {
+			t.Errorf("GetByID(missing) = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	subtest(t, open, "DuplicateShortCode", needs, func(t *testing.T, r Repositories) {
+		mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "taken", OriginalURL: "https://example.com"})
+		if err := r.URLs.Create(ctx, &entity.URL{ShortCode: "taken", OriginalURL: "https://example.org"}); err == nil {
+			t.Error("Create() with a taken short code succeeded")
+		}
+
+		for code, want := range map[string]bool{"taken": true, "free": false} {
+			if exists, err := r.URLs.ShortCodeExists(ctx, code); err != nil || exists != want {
+				t.Errorf("ShortCodeExists(%q) = %v, %v, want %v", code, exists, err, want)
+			}
+		}
+	})
+
+	subtest(t, open, "ListNewestFirst", needs, func(t *testing.T, r Repositories) {
+		for _, code := range []string{"one", "two", "three"} {
+			mustCreateURL(t, r.URLs, &entity.URL{ShortCode: code, OriginalURL: "https://example.com", UserID: "user-1", WorkspaceID: "ws-1"})
+		}
+		mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "other", OriginalURL: "https://example.com", UserID: "user-2"})
+
+		urls, err := r.URLs.GetByUserID(ctx, "user-1", 2, 0)
+		if err != nil {
+			t.Fatalf("GetByUserID() error = %v", err)
+		}
+		if codes := shortCodes(urls); !slices.Equal(codes, []string{"three", "two"}) {
+			t.Errorf("GetByUserID(limit 2) = %v, want [three two]", codes)
+		}
+
+		urls, _ = r.URLs.GetByUserID(ctx, "user-1", 2, 2)
+		if codes := shortCodes(urls); !slices.Equal(codes, []string{"one"}) {
+			t.Errorf("GetByUserID(offset 2) = %v, want [one]", codes)
+		}
+
+		urls, _ = r.URLs.GetByWorkspaceID(ctx, "ws-1", 10, 0)
+		if len(urls) != 3 {
+			t.Errorf("GetByWorkspaceID() returned %d links, want 3", len(urls))
+		}
+
+		urls, _ = r.URLs.List(ctx, 10, 0)
+		if codes := shortCodes(urls); !slices.Equal(codes, []string{"other", "three", "two", "one"}) {
+			t.Errorf("List() = %v", codes)
+		}
+	})
+
+	subtest(t, open, "Update", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "before", OriginalURL: "https://example.com", PasswordHash: "hash"})
+
+		url.ShortCode = "after"
+		url.CustomAlias = "after"
+		url.OriginalURL = "https://example.org"
+		url.IsActive = false
+		url.PasswordHash = ""
+		url.RedirectType = 302
+		url.TargetingRules = []entity.TargetingRule{
+			{ID: "rule-1", Device: "Mobile", OS: "iOS", URL: "https://example.org/ios"},
+			{ID: "rule-2", OS: "Android", URL: "https://example.org/android"},
+		}
+		if err := r.URLs.Update(ctx, url); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+
+		got, _ := r.URLs.GetByID(ctx, url.ID)
+		if got.ShortCode != "after" || got.CustomAlias != "after" || got.OriginalURL != "https://example.org" ||
+			got.IsActive || got.PasswordHash != "" || got.RedirectType != 302 {
+			t.Errorf("after Update() = %+v", got)
+		}
+		if !slices.Equal(got.TargetingRules, url.TargetingRules) {
+			t.Errorf("TargetingRules = %+v, want %+v", got.TargetingRules, url.TargetingRules)
+		}
+
+		url.TargetingRules = nil
+		if err := r.URLs.Update(ctx, url); err != nil {
+			t.Fatalf("Update() error = %v", err)
+		}
+		if got, _ := r.URLs.GetByID(ctx, url.ID); len(got.TargetingRules) != 0 {
+			t.Errorf("TargetingRules after removing them = %+v", got.TargetingRules)
+		}
+		if old, _ := r.URLs.GetByShortCode(ctx, "before"); old != nil {
+			t.Error("old short code still resolves after Update()")
+		}
+	})
+
+	subtest(t, open, "Delete", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "gone", OriginalURL: "https://example.com"})
+		if err := r.URLs.Delete(ctx, url.ID); err != nil {
+			t.Fatalf("Delete() error = %v", err)
+		}
+		if got, _ := r.URLs.GetByID(ctx, url.ID); got != nil {
+			t.Error("link still exists after Delete()")
+		}
+	})
+
+	subtest(t, open, "AddClickCountsConcurrently", needs, func(t *testing.T, r Repositories) {
+		url := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "busy", OriginalURL: "https://example.com"})
+
+		const clicks = 20
+		var wg sync.WaitGroup
+		for i := 0; i < clicks; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := r.URLs.AddClickCounts(ctx, map[string]int64{url.ID: 1}); err != nil {
+					t.Errorf("AddClickCounts() error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		got, _ := r.URLs.GetByID(ctx, url.ID)
+		if got.ClickCount != clicks {
+			t.Errorf("ClickCount = %d, want %d", got.ClickCount, clicks)
+		}
+	})
+
+	subtest(t, open, "AddClickCounts", needs, func(t *testing.T, r Repositories) {
+		a := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "a", OriginalURL: "https://example.com"})
+		b := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "b", OriginalURL: "https://example.com"})
+
+		if err := r.URLs.AddClickCounts(ctx, map[string]int64{a.ID: 2, b.ID: 5}); err != nil {
+			t.Fatalf("AddClickCounts() error = %v", err)
+		}
+		if err := r.URLs.AddClickCounts(ctx, map[string]int64{a.ID: 1}); err != nil {
+			t.Fatalf("AddClickCounts() error = %v", err)
+		}
+
+		for url, want := range map[*entity.URL]int64{a: 3, b: 5} {
+			got, _ := r.URLs.GetByID(ctx, url.ID)
+			if got.ClickCount != want {
+				t.Errorf("%s ClickCount = %d, want %d", url.ShortCode, got.ClickCount, want)
+			}
+		}
+	})
+
+	subtest(t, open, "GetMostClicked", needs, func(t *testing.T, r Repositories) {
+		past := time.Now().Add(-time.Hour)
+		popular := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "popular", OriginalURL: "https://example.com"})
+		quiet := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "quiet", OriginalURL: "https://example.com"})
+		expired := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "expired", OriginalURL: "https://example.com", ExpiresAt: &past})
+		disabled := mustCreateURL(t, r.URLs, &entity.URL{ShortCode: "disabled", OriginalURL: "https://example.com"})
+		disabled.IsActive = false
+		if err := r.URLs.Update(ctx, disabled); err != nil {
+			t.Fatal(err)
+		}
+
+		clicks := map[string]int64{popular.ID: 3, quiet.ID: 1, expired.ID: 5, disabled.ID: 5}
+		if err := r.URLs.AddClickCounts(ctx, clicks); err != nil {
+			t.Fatal(err)
+		}
+
+		urls, err := r.URLs.GetMostClicked(ctx, 10)
+		if err != nil {
+			t.Fatalf("GetMostClicked() error = %v", err)
+		}
+		if codes := shortCodes(urls); !slices.Equal(codes, []string{"popular", "quiet"}) {
+			t.Errorf("GetMostClicked() = %v, want [popular quiet]", codes)
+		}
+	})
+}
+
+func shortCodes(urls []*entity.URL) []string {
+	codes := make([]string, len(urls))
+	for i, url := range urls {
+		codes[i] = url.ShortCode
+	}
+	return codes
+}
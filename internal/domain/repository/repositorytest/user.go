@@ -0,0 +1,55 @@
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+)
+
+func testUserRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.Users != nil }
+
+	subtest(t, open, "CreateAndGet", needs, func(t *testing.T, r Repositories) {
+		user := &entity.User{Email: "ana@example.com", Name: "Ana", PasswordHash: "hash"}
+		if err := r.Users.Create(ctx, user); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		if user.ID == "" || user.CreatedAt.IsZero() {
+			t.Errorf("Create() left ID %q, CreatedAt %v", user.ID, user.CreatedAt)
+		}
+
+		byID, err := r.Users.GetByID(ctx, user.ID)
+		if err != nil || byID == nil || byID.Email != "ana@example.com" || byID.Name != "Ana" || byID.PasswordHash != "hash" {
+			t.Errorf("GetByID() = %+v, %v", byID, err)
+		}
+
+		byEmail, err := r.Users.GetByEmail(ctx, "ana@example.com")
+		if err != nil || byEmail == nil || byEmail.ID != user.ID {
+			t.Errorf("GetByEmail() = %+v, %v", byEmail, err)
+		}
+
+		if got, err := r.Users.GetByEmail(ctx, "bob@example.com"); got != nil || err != nil {
+			t.Errorf("GetByEmail(missing) = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	subtest(t, open, "EmailUnique", needs, func(t *testing.T, r Repositories) {
+		if err := r.Users.Create(ctx, &entity.User{Email: "ana@example.com", PasswordHash: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+
+		for email, want := range map[string]bool{"ana@example.com": true, "bob@example.com": false} {
+			if exists, err := r.Users.EmailExists(ctx, email); err != nil || exists != want {
+				t.Errorf("EmailExists(%q) = %v, %v, want %v", email, exists, err, want)
+			}
+		}
+
+		if err := r.Users.Create(ctx, &entity.User{Email: "ana@example.com", PasswordHash: "hash"}); !errors.Is(err, repository.ErrEmailTaken) {
+			t.Errorf("Create() with a taken email error = %v, want ErrEmailTaken", err)
+		}
+	})
+}
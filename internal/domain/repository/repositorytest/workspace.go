@@ -0,0 +1,108 @@
+package repositorytest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+func testWorkspaceRepository(t *testing.T, open Open) {
+	ctx := context.Background()
+	needs := func(r Repositories) bool { return r.Workspaces != nil && r.Users != nil }
+
+	// setup creates a workspace owned by a new user and returns both
+	setup := func(t *testing.T, r Repositories) (*entity.Workspace, *entity.User) {
+		t.Helper()
+		owner := &entity.User{Email: "owner@example.com", PasswordHash: "hash"}
+		if err := r.Users.Create(ctx, owner); err != nil {
+			t.Fatal(err)
+		}
+		workspace := &entity.Workspace{Name: "Marketing", CreatedBy: owner.ID}
+		if err := r.Workspaces.Create(ctx, workspace); err != nil {
+			t.Fatalf("Create() error = %v", err)
+		}
+		return workspace, owner
+	}
+
+	subtest(t, open, "CreatorIsOwner", needs, func(t *testing.T, r Repositories) {
+		workspace, owner := setup(t, r)
+		if workspace.ID == "" || workspace.CreatedAt.IsZero() {
+			t.Errorf("Create() left ID %q, CreatedAt %v", workspace.ID, workspace.CreatedAt)
+		}
+
+		got, err := r.Workspaces.GetByID(ctx, workspace.ID)
+		if err != nil || got == nil || got.Name != "Marketing" || got.CreatedBy != owner.ID {
+			t.Errorf("GetByID() = %+v, %v", got, err)
+		}
+
+		member, err := r.Workspaces.GetMember(ctx, workspace.ID, owner.ID)
+		if err != nil || member == nil {
+			t.Fatalf("GetMember(owner) = %v, %v", member, err)
+		}
+		if member.Role != entity.RoleOwner || member.Email != "owner@example.com" {
+			t.Errorf("GetMember(owner) = %+v", member)
+		}
+
+		if count, err := r.Workspaces.CountOwners(ctx, workspace.ID); err != nil || count != 1 {
+			t.Errorf("CountOwners() = %d, %v, want 1", count, err)
+		}
+
+		workspaces, err := r.Workspaces.GetByUserID(ctx, owner.ID)
+		if err != nil || len(workspaces) != 1 || workspaces[0].ID != workspace.ID {
+			t.Errorf("GetByUserID() = %v, %v", workspaces, err)
+		}
+	})
+
+	subtest(t, open, "Missing", needs, func(t *testing.T, r Repositories) {
+		workspace, _ := setup(t, r)
+
+		if got, err := r.Workspaces.GetByID(ctx, "00000000-0000-0000-0000-000000000000"); got != nil || err != nil {
+			t.Errorf("GetByID(missing) = %v, %v, want nil, nil", got, err)
+		}
+		if got, err := r.Workspaces.GetMember(ctx, workspace.ID, "stranger"); got != nil || err != nil {
+			t.Errorf("GetMember(missing) = %v, %v, want nil, nil", got, err)
+		}
+	})
+
+	subtest(t, open, "Members", needs, func(t *testing.T, r Repositories) {
+		workspace, owner := setup(t, r)
+		editor := &entity.User{Email: "editor@example.com", PasswordHash: "hash"}
+		if err := r.Users.Create(ctx, editor); err != nil {
+			t.Fatal(err)
+		}
+
+		member := &entity.WorkspaceMember{WorkspaceID: workspace.ID, UserID: editor.ID, Role: entity.RoleEditor}
+		if err := r.Workspaces.AddMember(ctx, member); err != nil {
+			t.Fatalf("AddMember() error = %v", err)
+		}
+		if err := r.Workspaces.AddMember(ctx, &entity.WorkspaceMember{WorkspaceID: workspace.ID, UserID: editor.ID, Role: entity.RoleViewer}); err == nil {
+			t.Error("AddMember() of an existing member succeeded")
+		}
+
+		members, err := r.Workspaces.GetMembers(ctx, workspace.ID)
+		if err != nil || len(members) != 2 {
+			t.Fatalf("GetMembers() = %v, %v, want 2 members", members, err)
+		}
+		if members[0].UserID != owner.ID || members[1].UserID != editor.ID || members[1].Email != "editor@example.com" {
+			t.Errorf("GetMembers() = %+v, %+v, want owner then editor", members[0], members[1])
+		}
+
+		if err := r.Workspaces.UpdateMemberRole(ctx, workspace.ID, editor.ID, entity.RoleOwner); err != nil {
+			t.Fatalf("UpdateMemberRole() error = %v", err)
+		}
+		if count, _ := r.Workspaces.CountOwners(ctx, workspace.ID); count != 2 {
+			t.Errorf("CountOwners() after promotion = %d, want 2", count)
+		}
+
+		if err := r.Workspaces.RemoveMember(ctx, workspace.ID, editor.ID); err != nil {
+			t.Fatalf("RemoveMember() error = %v", err)
+		}
+		if got, _ := r.Workspaces.GetMember(ctx, workspace.ID, editor.ID); got != nil {
+			t.Error("member still present after RemoveMember()")
+		}
+		if workspaces, _ := r.Workspaces.GetByUserID(ctx, editor.ID); len(workspaces) != 0 {
+			t.Errorf("GetByUserID() of removed member = %v, want none", workspaces)
+		}
+	})
+}
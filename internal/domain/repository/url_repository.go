@@ -2,24 +2,67 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/bimakw/url-shortener/internal/domain/entity"
 )
 
+// ErrCachedNotFound is returned by URLCacheRepository.Get for a short code
+// that was looked up recently and did not exist.
+var ErrCachedNotFound = errors.New("short code cached as not found")
+
 type URLRepository interface {
 	Create(ctx context.Context, url *entity.URL) error
 	GetByShortCode(ctx context.Context, shortCode string) (*entity.URL, error)
 	GetByID(ctx context.Context, id string) (*entity.URL, error)
 	GetByUserID(ctx context.Context, userID string, limit, offset int) ([]*entity.URL, error)
+	GetByWorkspaceID(ctx context.Context, workspaceID string, limit, offset int) ([]*entity.URL, error)
+	List(ctx context.Context, limit, offset int) ([]*entity.URL, error)
+	GetMostClicked(ctx context.Context, limit int) ([]*entity.URL, error)
 	Update(ctx context.Context, url *entity.URL) error
 	Delete(ctx context.Context, id string) error
-	IncrementClickCount(ctx context.Context, id string) error
+	// AddClickCounts adds each delta to the click count of the link with that
+	// ID, all in one transaction.
+	AddClickCounts(ctx context.Context, counts map[string]int64) error
 	ShortCodeExists(ctx context.Context, shortCode string) (bool, error)
 }
 
 type URLCacheRepository interface {
+	// Get returns nil, nil when the code is not cached and ErrCachedNotFound
+	// when it is cached as missing.
 	Get(ctx context.Context, shortCode string) (*entity.URL, error)
 	Set(ctx context.Context, url *entity.URL) error
+	// SetNotFound caches for ttl that shortCode does not exist, unless the
+	// code is cached already. Set and Delete for the same code replace it.
+	SetNotFound(ctx context.Context, shortCode string, ttl time.Duration) error
 	Delete(ctx context.Context, shortCode string) error
-	IncrementClickCount(ctx context.Context, shortCode string) (int64, error)
+
+	// Pending clicks are counted in the cache first and moved to the
+	// database in batches, all keyed by URL ID.
+	AddPendingClicks(ctx context.Context, counts map[string]int64) error
+	// GetPendingClicks counts the clicks of taken batches too, until they
+	// are done.
+	GetPendingClicks(ctx context.Context, urlIDs []string) (map[string]int64, error)
+	// TakePendingClicks moves every pending count into a new batch at once,
+	// so clicks counted meanwhile are kept for the next call. It returns nil
+	// when nothing is pending. The batch is kept until DonePendingClicks or
+	// ReturnPendingClicks, so a flush that stops halfway loses nothing.
+	TakePendingClicks(ctx context.Context) (*PendingClickBatch, error)
+	// DonePendingClicks drops a batch once its counts are in the database.
+	DonePendingClicks(ctx context.Context, batchID string) error
+	// ReturnPendingClicks puts the counts of a batch back with the pending
+	// ones.
+	ReturnPendingClicks(ctx context.Context, batchID string) error
+	// ClaimPendingClicks returns the batches taken more than olderThan ago
+	// and neither done nor returned, and marks them taken now so no one
+	// else claims them meanwhile.
+	ClaimPendingClicks(ctx context.Context, olderThan time.Duration) ([]*PendingClickBatch, error)
+}
+
+// PendingClickBatch holds pending click counts taken out to be written to
+// the database, keyed by URL ID.
+type PendingClickBatch struct {
+	ID     string
+	Counts map[string]int64
 }
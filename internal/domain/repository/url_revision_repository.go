@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+type URLRevisionRepository interface {
+	Create(ctx context.Context, revision *entity.URLRevision) error
+	// Edit loads the link with the given ID, locked against other edits
+	// until Edit returns, and passes it to edit to change in place. Unless
+	// edit returns an error, which Edit passes on, the changed link and a
+	// revision of it recording action by changedBy are saved together. It
+	// returns the changed link, or nil when there is no link with the ID.
+	Edit(ctx context.Context, urlID, action, changedBy string, edit func(url *entity.URL) error) (*entity.URL, error)
+	GetByURLID(ctx context.Context, urlID string) ([]*entity.URLRevision, error)
+	GetByRevision(ctx context.Context, urlID string, revision int) (*entity.URLRevision, error)
+}
@@ -0,0 +1,19 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+// ErrEmailTaken is returned by UserRepository.Create for an email that is
+// registered already.
+var ErrEmailTaken = errors.New("email taken")
+
+type UserRepository interface {
+	Create(ctx context.Context, user *entity.User) error
+	GetByID(ctx context.Context, id string) (*entity.User, error)
+	GetByEmail(ctx context.Context, email string) (*entity.User, error)
+	EmailExists(ctx context.Context, email string) (bool, error)
+}
@@ -0,0 +1,20 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/bimakw/url-shortener/internal/domain/entity"
+)
+
+type WorkspaceRepository interface {
+	// Create stores the workspace and makes its creator the owner
+	Create(ctx context.Context, workspace *entity.Workspace) error
+	GetByID(ctx context.Context, id string) (*entity.Workspace, error)
+	GetByUserID(ctx context.Context, userID string) ([]*entity.Workspace, error)
+	GetMember(ctx context.Context, workspaceID, userID string) (*entity.WorkspaceMember, error)
+	GetMembers(ctx context.Context, workspaceID string) ([]*entity.WorkspaceMember, error)
+	AddMember(ctx context.Context, member *entity.WorkspaceMember) error
+	UpdateMemberRole(ctx context.Context, workspaceID, userID string, role entity.WorkspaceRole) error
+	RemoveMember(ctx context.Context, workspaceID, userID string) error
+	CountOwners(ctx context.Context, workspaceID string) (int, error)
+}
@@ -10,7 +10,9 @@ type Config struct {
 	Server   ServerConfig
 	Database DatabaseConfig
 	Redis    RedisConfig
+	Auth     AuthConfig
 	App      AppConfig
+	Clicks   ClickConfig
 }
 
 type ServerConfig struct {
@@ -20,12 +22,15 @@ type ServerConfig struct {
 }
 
 type DatabaseConfig struct {
-	Host     string
-	Port     string
-	User     string
-	Password string
-	DBName   string
-	SSLMode  string
+	Driver      string // postgres or sqlite
+	Path        string // database file, sqlite only
+	Host        string
+	Port        string
+	User        string
+	Password    string
+	DBName      string
+	SSLMode     string
+	AutoMigrate bool // apply pending migrations on startup
 }
 
 type RedisConfig struct {
@@ -35,12 +40,56 @@ type RedisConfig struct {
 	DB       int
 }
 
+type AuthConfig struct {
+	Secret     string
+	SessionTTL time.Duration
+	UnlockTTL  time.Duration // how long an entered link password is remembered
+	// Wrong link passwords lock out the link and the client after their
+	// free attempts, for twice as long each time up to the maximum
+	PasswordFreeAttemptsPerLink int
+	PasswordFreeAttemptsPerIP   int
+	PasswordLockoutBase         time.Duration
+	PasswordLockoutMax          time.Duration
+	PasswordAttemptWindow       time.Duration // how long failures are counted
+}
+
+// ClickConfig sizes the background click ingestion.
+type ClickConfig struct {
+	Workers        int
+	QueueSize      int
+	BatchSize      int
+	FlushInterval  time.Duration
+	EnqueueTimeout time.Duration // wait for room in a full queue, 0 drops at once
+	// CountFlushInterval is how often click counts pending in Redis are
+	// moved into the database.
+	CountFlushInterval time.Duration
+	// GeoLookupTimeout is how long a batch waits for city lookups.
+	GeoLookupTimeout time.Duration
+}
+
 type AppConfig struct {
-	BaseURL         string
-	ShortCodeLength int
-	DefaultExpiry   time.Duration
-	RateLimit       int
-	CacheTTL        time.Duration
+	BaseURL          string
+	ShortCodeLength  int
+	DefaultExpiry    time.Duration
+	RateLimit        int
+	RateLimitStore   string // memory or redis
+	CacheTTL         time.Duration
+	CacheNotFoundTTL time.Duration // how long unknown short codes are remembered
+	LocalCacheSize   int           // links kept in process in front of Redis, 0 turns it off
+	LocalCacheTTL    time.Duration
+	KeyRotationGrace time.Duration
+	KeyMaxRateLimit  int // highest requests per second an API key may be given
+	// DefaultRedirectType is the status of links that do not set one: 301,
+	// 302, 307 or 308. Permanent redirects are cached by browsers for
+	// RedirectMaxAge.
+	DefaultRedirectType int
+	RedirectMaxAge      time.Duration
+	// GeoIPCountryDB is a CSV file of IP ranges and country codes that
+	// country targeting rules are resolved with; empty turns them off.
+	GeoIPCountryDB string
+	// TrustedProxies lists the addresses and CIDR ranges of the proxies in
+	// front of the server, whose X-Forwarded-For headers are believed.
+	TrustedProxies string
 }
 
 func LoadConfig() *Config {
@@ -51,12 +100,15 @@ func LoadConfig() *Config {
 			WriteTimeout: getDurationEnv("WRITE_TIMEOUT", 10*time.Second),
 		},
 		Database: DatabaseConfig{
-			Host:     getEnv("DB_HOST", "localhost"),
-			Port:     getEnv("DB_PORT", "5432"),
-			User:     getEnv("DB_USER", "postgres"),
-			Password: getEnv("DB_PASSWORD", "postgres"),
-			DBName:   getEnv("DB_NAME", "url_shortener"),
-			SSLMode:  getEnv("DB_SSLMODE", "disable"),
+			Driver:      getEnv("DB_DRIVER", "postgres"),
+			Path:        getEnv("DB_PATH", "url_shortener.db"),
+			Host:        getEnv("DB_HOST", "localhost"),
+			Port:        getEnv("DB_PORT", "5432"),
+			User:        getEnv("DB_USER", "postgres"),
+			Password:    getEnv("DB_PASSWORD", "postgres"),
+			DBName:      getEnv("DB_NAME", "url_shortener"),
+			SSLMode:     getEnv("DB_SSLMODE", "disable"),
+			AutoMigrate: getBoolEnv("DB_AUTO_MIGRATE", true),
 		},
 		Redis: RedisConfig{
 			Host:     getEnv("REDIS_HOST", "localhost"),
@@ -64,12 +116,43 @@ func LoadConfig() *Config {
 			Password: getEnv("REDIS_PASSWORD", ""),
 			DB:       getIntEnv("REDIS_DB", 0),
 		},
+		Auth: AuthConfig{
+			Secret:     getEnv("AUTH_SECRET", ""),
+			SessionTTL: getDurationEnv("SESSION_TTL", 24*time.Hour),
+			UnlockTTL:  getDurationEnv("UNLOCK_TTL", 10*time.Minute),
+
+			PasswordFreeAttemptsPerLink: getIntEnv("PASSWORD_FREE_ATTEMPTS_PER_LINK", 20),
+			PasswordFreeAttemptsPerIP:   getIntEnv("PASSWORD_FREE_ATTEMPTS_PER_IP", 5),
+			PasswordLockoutBase:         getDurationEnv("PASSWORD_LOCKOUT_BASE", 30*time.Second),
+			PasswordLockoutMax:          getDurationEnv("PASSWORD_LOCKOUT_MAX", time.Hour),
+			PasswordAttemptWindow:       getDurationEnv("PASSWORD_ATTEMPT_WINDOW", 24*time.Hour),
+		},
 		App: AppConfig{
-			BaseURL:         getEnv("BASE_URL", "http://localhost:8080"),
-			ShortCodeLength: getIntEnv("SHORT_CODE_LENGTH", 8),
-			DefaultExpiry:   getDurationEnv("DEFAULT_EXPIRY", 0), // 0 means no expiry
-			RateLimit:       getIntEnv("RATE_LIMIT", 100),
-			CacheTTL:        getDurationEnv("CACHE_TTL", 1*time.Hour),
+			BaseURL:          getEnv("BASE_URL", "http://localhost:8080"),
+			ShortCodeLength:  getIntEnv("SHORT_CODE_LENGTH", 8),
+			DefaultExpiry:    getDurationEnv("DEFAULT_EXPIRY", 0), // 0 means no expiry
+			RateLimit:        getIntEnv("RATE_LIMIT", 100),
+			RateLimitStore:   getEnv("RATE_LIMIT_STORE", "memory"),
+			CacheTTL:         getDurationEnv("CACHE_TTL", 1*time.Hour),
+			CacheNotFoundTTL: getDurationEnv("CACHE_NOT_FOUND_TTL", 30*time.Second),
+			LocalCacheSize:   getIntEnv("LOCAL_CACHE_SIZE", 10000),
+			LocalCacheTTL:    getDurationEnv("LOCAL_CACHE_TTL", 30*time.Second),
+			KeyRotationGrace: getDurationEnv("API_KEY_ROTATION_GRACE", 24*time.Hour),
+			KeyMaxRateLimit:  getIntEnv("API_KEY_MAX_RATE_LIMIT", 1000),
+
+			DefaultRedirectType: getIntEnv("DEFAULT_REDIRECT_TYPE", 301),
+			RedirectMaxAge:      getDurationEnv("REDIRECT_MAX_AGE", time.Hour),
+			GeoIPCountryDB:      getEnv("GEOIP_COUNTRY_DB", ""),
+			TrustedProxies:      getEnv("TRUSTED_PROXIES", ""),
+		},
+		Clicks: ClickConfig{
+			Workers:            getIntEnv("CLICK_WORKERS", 4),
+			QueueSize:          getIntEnv("CLICK_QUEUE_SIZE", 10000),
+			BatchSize:          getIntEnv("CLICK_BATCH_SIZE", 100),
+			FlushInterval:      getDurationEnv("CLICK_FLUSH_INTERVAL", time.Second),
+			EnqueueTimeout:     getDurationEnv("CLICK_ENQUEUE_TIMEOUT", 0),
+			CountFlushInterval: getDurationEnv("CLICK_COUNT_FLUSH_INTERVAL", 10*time.Second),
+			GeoLookupTimeout:   getDurationEnv("CLICK_GEO_LOOKUP_TIMEOUT", 2*time.Second),
 		},
 	}
 }
@@ -99,6 +182,15 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if duration, err := time.ParseDuration(value); err == nil {
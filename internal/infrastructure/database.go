@@ -0,0 +1,91 @@
+package infrastructure
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/lib/pq"
+
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/postgres"
+	"github.com/bimakw/url-shortener/internal/adapter/outbound/sqlite"
+	"github.com/bimakw/url-shortener/internal/domain/repository"
+	"github.com/bimakw/url-shortener/pkg/migrate"
+)
+
+// Database is an open connection to the configured driver together with the
+// repositories backed by it.
+type Database struct {
+	DB *sql.DB
+
+	URLs       repository.URLRepository
+	Clicks     repository.ClickRepository
+	APIKeys    repository.APIKeyRepository
+	Users      repository.UserRepository
+	Revisions  repository.URLRevisionRepository
+	Workspaces repository.WorkspaceRepository
+	Audit      repository.AuditRepository
+
+	newMigrator func(db *sql.DB) (*migrate.Migrator, error)
+}
+
+// OpenDatabase connects to the database selected by cfg.Driver and checks
+// that it is reachable.
+func OpenDatabase(ctx context.Context, cfg DatabaseConfig) (*Database, error) {
+	var d *Database
+
+	switch cfg.Driver {
+	case "postgres":
+		db, err := sql.Open("postgres", cfg.DSN())
+		if err != nil {
+			return nil, err
+		}
+		d = &Database{
+			DB:          db,
+			URLs:        postgres.NewURLRepository(db),
+			Clicks:      postgres.NewClickRepository(db),
+			APIKeys:     postgres.NewAPIKeyRepository(db),
+			Users:       postgres.NewUserRepository(db),
+			Revisions:   postgres.NewURLRevisionRepository(db),
+			Workspaces:  postgres.NewWorkspaceRepository(db),
+			Audit:       postgres.NewAuditRepository(db),
+			newMigrator: postgres.NewMigrator,
+		}
+
+	case "sqlite":
+		db, err := sqlite.Open(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		d = &Database{
+			DB:          db,
+			URLs:        sqlite.NewURLRepository(db),
+			Clicks:      sqlite.NewClickRepository(db),
+			APIKeys:     sqlite.NewAPIKeyRepository(db),
+			Users:       sqlite.NewUserRepository(db),
+			Revisions:   sqlite.NewURLRevisionRepository(db),
+			Workspaces:  sqlite.NewWorkspaceRepository(db),
+			Audit:       sqlite.NewAuditRepository(db),
+			newMigrator: sqlite.NewMigrator,
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.Driver)
+	}
+
+	if err := d.DB.PingContext(ctx); err != nil {
+		d.DB.Close()
+		return nil, err
+	}
+
+	return d, nil
+}
+
+// Migrator returns the schema migrator for the database's driver.
+func (d *Database) Migrator() (*migrate.Migrator, error) {
+	return d.newMigrator(d.DB)
+}
+
+func (d *Database) Close() error {
+	return d.DB.Close()
+}
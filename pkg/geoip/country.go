@@ -0,0 +1,115 @@
+package geoip
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"slices"
+	"strings"
+)
+
+// CountryDB resolves IP addresses to countries from a local table of address
+// ranges, fast enough to use while serving a redirect.
+type CountryDB struct {
+	ranges []countryRange // sorted by start, not overlapping
+}
+
+type countryRange struct {
+	start, end netip.Addr
+	country    string
+}
+
+// OpenCountryDB loads a CountryDB from a CSV file, see ReadCountryDB.
+func OpenCountryDB(path string) (*CountryDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ReadCountryDB(f)
+}
+
+// ReadCountryDB loads a CountryDB from CSV rows of first address, last
+// address and ISO 3166-1 alpha-2 country code, IPv4 or IPv6, such as the
+// free "IP to Country Lite" database of db-ip.com. Columns past the third
+// are ignored.
+func ReadCountryDB(r io.Reader) (*CountryDB, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	var ranges []countryRange
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line, _ := reader.FieldPos(0)
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: want first address, last address and country", line)
+		}
+		start, err := netip.ParseAddr(strings.TrimSpace(record[0]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		end, err := netip.ParseAddr(strings.TrimSpace(record[1]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		start, end = start.Unmap(), end.Unmap()
+		if start.Is4() != end.Is4() || end.Less(start) {
+			return nil, fmt.Errorf("line %d: invalid range %s-%s", line, start, end)
+		}
+
+		ranges = append(ranges, countryRange{
+			start:   start,
+			end:     end,
+			country: strings.ToUpper(strings.TrimSpace(record[2])),
+		})
+	}
+
+	slices.SortFunc(ranges, func(a, b countryRange) int { return a.start.Compare(b.start) })
+	for i := 1; i < len(ranges); i++ {
+		if !ranges[i-1].end.Less(ranges[i].start) {
+			return nil, fmt.Errorf("overlapping ranges %s-%s and %s-%s", ranges[i-1].start, ranges[i-1].end, ranges[i].start, ranges[i].end)
+		}
+	}
+
+	return &CountryDB{ranges: ranges}, nil
+}
+
+// Country returns the country code of ip, or "" when ip is not valid or in
+// no range.
+func (db *CountryDB) Country(ip string) string {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+
+	// The last range starting at or before addr is the only one that can
+	// hold it
+	i, found := slices.BinarySearchFunc(db.ranges, addr, func(r countryRange, addr netip.Addr) int {
+		return r.start.Compare(addr)
+	})
+	if !found {
+		i--
+	}
+	if i < 0 || db.ranges[i].end.Less(addr) {
+		return ""
+	}
+	return db.ranges[i].country
+}
+
+// Len returns the number of address ranges in the database.
+func (db *CountryDB) Len() int {
+	return len(db.ranges)
+}
@@ -0,0 +1,64 @@
+package geoip
+
+import (
+	"strings"
+	"testing"
+)
+
+const testCountries = `# first,last,country
+1.0.0.0,1.0.0.255,AU
+5.1.0.0,5.1.255.255,de
+36.64.0.0,36.95.255.255,ID
+2001:db8::,2001:db8::ffff,NL
+`
+
+func TestCountry(t *testing.T) {
+	db, err := ReadCountryDB(strings.NewReader(testCountries))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.Len() != 4 {
+		t.Errorf("Len() = %d, want 4", db.Len())
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"1.0.0.0", "AU"},
+		{"1.0.0.255", "AU"},
+		{"5.1.42.7", "DE"},
+		{"36.80.1.1", "ID"},
+		{"::ffff:36.80.1.1", "ID"},
+		{"2001:db8::1", "NL"},
+		{"0.255.255.255", ""},
+		{"1.0.1.0", ""},
+		{"200.0.0.1", ""},
+		{"2001:db8::1:0", ""},
+		{"::1", ""},
+		{"not an ip", ""},
+	}
+	for _, tt := range tests {
+		if got := db.Country(tt.ip); got != tt.want {
+			t.Errorf("Country(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestReadCountryDBErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+	}{
+		{"missing country", "1.0.0.0,1.0.0.255\n"},
+		{"bad address", "1.0.0,1.0.0.255,AU\n"},
+		{"reversed range", "1.0.0.255,1.0.0.0,AU\n"},
+		{"mixed families", "1.0.0.0,2001:db8::,AU\n"},
+		{"overlap", "1.0.0.0,1.0.0.255,AU\n1.0.0.128,1.0.1.0,CN\n"},
+	}
+	for _, tt := range tests {
+		if _, err := ReadCountryDB(strings.NewReader(tt.csv)); err == nil {
+			t.Errorf("%s: ReadCountryDB() error = nil", tt.name)
+		}
+	}
+}
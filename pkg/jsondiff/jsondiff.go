@@ -0,0 +1,59 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Change holds the old and new value of a field. A missing side means the
+// field did not exist before or after.
+type Change struct {
+	Old any `json:"old,omitempty"`
+	New any `json:"new,omitempty"`
+}
+
+// Diff compares the JSON encodings of before and after and returns the top
+// level fields that differ. Either side may be nil, which makes every field of
+// the other side a change.
+func Diff(before, after any) (map[string]Change, error) {
+	oldFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make(map[string]Change)
+	for key, oldValue := range oldFields {
+		newValue, ok := newFields[key]
+		if !ok || !reflect.DeepEqual(oldValue, newValue) {
+			changes[key] = Change{Old: oldValue, New: newValue}
+		}
+	}
+	for key, newValue := range newFields {
+		if _, ok := oldFields[key]; !ok {
+			changes[key] = Change{New: newValue}
+		}
+	}
+
+	return changes, nil
+}
+
+func fields(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,83 @@
+package jsondiff
+
+import (
+	"testing"
+)
+
+type link struct {
+	URL      string `json:"url"`
+	Alias    string `json:"alias,omitempty"`
+	IsActive bool   `json:"is_active"`
+	Secret   string `json:"-"`
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		name   string
+		before any
+		after  any
+		want   map[string]Change
+	}{
+		{
+			name:   "no changes",
+			before: link{URL: "https://a.com", IsActive: true},
+			after:  link{URL: "https://a.com", IsActive: true},
+			want:   map[string]Change{},
+		},
+		{
+			name:   "changed field",
+			before: link{URL: "https://a.com", IsActive: true},
+			after:  link{URL: "https://a.com", IsActive: false},
+			want:   map[string]Change{"is_active": {Old: true, New: false}},
+		},
+		{
+			name:   "added and removed fields",
+			before: map[string]any{"url": "https://a.com", "alias": "old"},
+			after:  link{URL: "https://a.com"},
+			want: map[string]Change{
+				"alias":     {Old: "old"},
+				"is_active": {New: false},
+			},
+		},
+		{
+			name:  "created",
+			after: &link{URL: "https://a.com"},
+			want: map[string]Change{
+				"url":       {New: "https://a.com"},
+				"is_active": {New: false},
+			},
+		},
+		{
+			name:   "deleted",
+			before: &link{URL: "https://a.com", IsActive: true},
+			want: map[string]Change{
+				"url":       {Old: "https://a.com"},
+				"is_active": {Old: true},
+			},
+		},
+		{
+			name:   "ignored fields",
+			before: link{Secret: "a"},
+			after:  link{Secret: "b"},
+			want:   map[string]Change{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Diff(tt.before, tt.after)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("Diff() = %v, want %v", got, tt.want)
+			}
+			for key, want := range tt.want {
+				if got[key] != want {
+					t.Errorf("Diff()[%q] = %v, want %v", key, got[key], want)
+				}
+			}
+		})
+	}
+}
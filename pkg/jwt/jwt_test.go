@@ -0,0 +1,76 @@
+package jwt
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var secret = []byte("test-secret")
+
+func TestSignAndParse(t *testing.T) {
+	now := time.Now()
+	token, err := Sign(Claims{
+		Subject:   "user123",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(time.Hour).Unix(),
+	}, secret)
+	if err != nil {
+		t.Fatalf("Sign returned error: %v", err)
+	}
+
+	if strings.Count(token, ".") != 2 {
+		t.Errorf("token %q should have three segments", token)
+	}
+
+	claims, err := Parse(token, secret)
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if claims.Subject != "user123" {
+		t.Errorf("Subject = %q, want user123", claims.Subject)
+	}
+}
+
+func TestParseWrongSecret(t *testing.T) {
+	token, _ := Sign(Claims{Subject: "user123"}, secret)
+
+	if _, err := Parse(token, []byte("other-secret")); err != ErrInvalidToken {
+		t.Errorf("Parse with wrong secret error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestParseExpired(t *testing.T) {
+	token, _ := Sign(Claims{
+		Subject:   "user123",
+		ExpiresAt: time.Now().Add(-time.Minute).Unix(),
+	}, secret)
+
+	if _, err := Parse(token, secret); err != ErrExpiredToken {
+		t.Errorf("Parse expired token error = %v, want ErrExpiredToken", err)
+	}
+}
+
+func TestParseTampered(t *testing.T) {
+	token, _ := Sign(Claims{Subject: "user123"}, secret)
+	other, _ := Sign(Claims{Subject: "admin"}, []byte("attacker"))
+
+	// Swap in another payload while keeping the original signature
+	parts := strings.Split(token, ".")
+	otherParts := strings.Split(other, ".")
+	tampered := parts[0] + "." + otherParts[1] + "." + parts[2]
+
+	if _, err := Parse(tampered, secret); err != ErrInvalidToken {
+		t.Errorf("Parse tampered token error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	tests := []string{"", "abc", "a.b", "a.b.c", "a.b.c.d"}
+
+	for _, token := range tests {
+		if _, err := Parse(token, secret); err != ErrInvalidToken {
+			t.Errorf("Parse(%q) error = %v, want ErrInvalidToken", token, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package lru
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetAdd(t *testing.T) {
+	c := New[string, int](2, time.Hour)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() on an empty cache found a value")
+	}
+
+	c.Add("a", 1)
+	c.Add("a", 2)
+	if got, ok := c.Get("a"); !ok || got != 2 {
+		t.Errorf("Get() = %d, %v, want 2, true", got, ok)
+	}
+	if c.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", c.Len())
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	c := New[string, int](2, time.Hour)
+
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a") // b is now the oldest
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("b was not evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+}
+
+func TestExpires(t *testing.T) {
+	now := time.Now()
+	c := New[string, int](2, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.Add("a", 1)
+	now = now.Add(time.Minute + time.Second)
+
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() returned an expired entry")
+	}
+	if c.Len() != 0 {
+		t.Errorf("Len() = %d, want the expired entry removed", c.Len())
+	}
+}
+
+func TestRemovePurge(t *testing.T) {
+	c := New[string, int](3, time.Hour)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Add("c", 3)
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok {
+		t.Error("Get() after Remove() found the entry")
+	}
+
+	c.Purge()
+	if c.Len() != 0 {
+		t.Errorf("Len() after Purge() = %d, want 0", c.Len())
+	}
+	c.Add("d", 4)
+	if _, ok := c.Get("d"); !ok {
+		t.Error("Add() after Purge() did not store the entry")
+	}
+}
@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_add_name.down.sql": {Data: []byte("ALTER TABLE t DROP COLUMN name")},
+		"0002_add_name.up.sql":   {Data: []byte("ALTER TABLE t ADD COLUMN name TEXT")},
+		"0001_init.up.sql":       {Data: []byte("CREATE TABLE t (id INTEGER)")},
+		"0001_init.down.sql":     {Data: []byte("DROP TABLE t")},
+	}
+
+	migrations, err := Load(fsys)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+
+	if len(migrations) != 2 {
+		t.Fatalf("Load() returned %d migrations, want 2", len(migrations))
+	}
+	if migrations[0].Version != 1 || migrations[0].Name != "init" {
+		t.Errorf("migrations[0] = %d_%s, want 1_init", migrations[0].Version, migrations[0].Name)
+	}
+	if migrations[1].Up != "ALTER TABLE t ADD COLUMN name TEXT" {
+		t.Errorf("migrations[1].Up = %q", migrations[1].Up)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+	}{
+		{
+			name: "missing down",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "bad file name",
+			fsys: fstest.MapFS{
+				"init.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+		{
+			name: "conflicting names",
+			fsys: fstest.MapFS{
+				"0001_init.up.sql":    {Data: []byte("SELECT 1")},
+				"0001_other.down.sql": {Data: []byte("SELECT 1")},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Load(tt.fsys); err == nil {
+				t.Error("Load() expected error")
+			}
+		})
+	}
+}